@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// seal-configs encrypts kubeconfig files into the sealed configs embedded in
+// kesctl. The passphrase is read like kesctl does at runtime, from
+// $KESCTL_CONFIGS_KEY, the keyring file or a prompt.
+//
+// Generate a keyring file with a random passphrase:
+//
+//	go run ./hack/seal-configs --generate-key
+//
+// Seal prod.yaml and dev.yaml as the clusters prod and dev into a Go file:
+//
+//	go run ./hack/seal-configs -o pkg/configs/zz_generated.sealed.go prod.yaml dev=./dev.yaml
+//
+// Without -o the sealed configs are printed, to be set at link time:
+//
+//	go build -ldflags "-X github.com/Angus-F/kubectl/pkg/configs.SealedConfigs=$(go run ./hack/seal-configs prod.yaml)"
+//
+// The plain configs.ConfigContent and configs.ClusterName should be emptied
+// once the clusters are sealed.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+const generatedFile = `// Code generated by hack/seal-configs. DO NOT EDIT.
+
+package configs
+
+func init() {
+	SealedConfigs = %q
+}
+`
+
+func main() {
+	output := flag.String("o", "", "write the sealed configs to this Go file of the configs package instead of printing them")
+	policy := flag.String("policy", "", "an access policy file to seal along with the clusters")
+	generateKey := flag.Bool("generate-key", false, "write a random passphrase to the keyring file and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o FILE] [-policy FILE] [NAME=]KUBECONFIG...\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	if *generateKey {
+		err = writeKey()
+	} else {
+		err = seal(flag.Args(), *policy, *output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeKey writes a random passphrase to the keyring file, unless it exists
+func writeKey() error {
+	path := os.Getenv(configs.KeyFileEnv)
+	if len(path) == 0 {
+		path = configs.RecommendedKeyFile
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key)); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote a new passphrase to %s\n", path)
+	return nil
+}
+
+// seal seals the kubeconfig files of args, each named by its base name or
+// given as NAME=FILE, and the policy file if set
+func seal(args []string, policyFile, output string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("at least one kubeconfig file is required")
+	}
+	bundle := &configs.Bundle{}
+	seen := map[string]bool{}
+	for _, arg := range args {
+		name, path := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg)), arg
+		if i := strings.Index(arg, "="); i > 0 {
+			name, path = arg[:i], arg[i+1:]
+		}
+		if seen[name] {
+			return fmt.Errorf("the cluster %q is given twice", name)
+		}
+		seen[name] = true
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		bundle.Clusters = append(bundle.Clusters, configs.BundleCluster{Name: name, Kubeconfig: string(content)})
+	}
+	if len(policyFile) > 0 {
+		content, err := ioutil.ReadFile(policyFile)
+		if err != nil {
+			return err
+		}
+		if _, err := configs.ParsePolicy(content, policyFile); err != nil {
+			return err
+		}
+		bundle.Policy = string(content)
+	}
+
+	passphrase, err := configs.LoadPassphrase()
+	if err != nil {
+		return err
+	}
+	sealed, err := configs.Seal(bundle, passphrase)
+	if err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		fmt.Println(sealed)
+		return nil
+	}
+	return ioutil.WriteFile(output, []byte(fmt.Sprintf(generatedFile, sealed)), 0644)
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit keeps a local, append-only trail of the commands that reach
+// a cluster, one JSON object per line.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	uexec "github.com/Angus-F/client-go/util/exec"
+	"k8s.io/klog/v2"
+
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+const (
+	// PathEnv overrides the file the audit log is written to.
+	PathEnv = "KESCTL_AUDIT_LOG"
+)
+
+// RecommendedPath is the default file of the audit log.
+var RecommendedPath = filepath.Join(configs.RecommendedConfigDir, "audit.log")
+
+// Entry is a single command run against a cluster.
+type Entry struct {
+	User   string `json:"user"`
+	Action string `json:"action"`
+	// ClusterName is a comma separated list when a single entry covers several clusters
+	ClusterName string `json:"clusterName"`
+	Namespace   string `json:"namespace,omitempty"`
+	Pod         string `json:"pod,omitempty"`
+	Container   string `json:"container,omitempty"`
+	// Command is the command run in the container
+	Command []string `json:"command,omitempty"`
+	// Ports are the ports forwarded by port-forward
+	Ports []string `json:"ports,omitempty"`
+	// Args is the command line kesctl was invoked with
+	Args     []string  `json:"args,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exitCode"`
+	Error    string    `json:"error,omitempty"`
+	// BytesIn is the number of bytes sent to the cluster, BytesOut the number received
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+}
+
+// NewEntry starts an entry for action in a namespace of a cluster.
+func NewEntry(action, clusterName, namespace string) *Entry {
+	return &Entry{
+		Action:      action,
+		ClusterName: clusterName,
+		Namespace:   namespace,
+		Start:       time.Now(),
+	}
+}
+
+// Finish records the end time and the outcome of the command. The exit code
+// is the remote one if err carries it, -1 for other errors.
+func (e *Entry) Finish(err error) {
+	e.End = time.Now()
+	if err == nil {
+		return
+	}
+	e.Error = err.Error()
+	if exitErr, ok := err.(uexec.ExitError); ok {
+		e.ExitCode = exitErr.ExitStatus()
+	} else {
+		e.ExitCode = -1
+	}
+}
+
+// Clusters returns the clusters of the entry.
+func (e *Entry) Clusters() []string {
+	if len(e.ClusterName) == 0 {
+		return nil
+	}
+	return strings.Split(e.ClusterName, ",")
+}
+
+// Logger writes entries to the audit log.
+type Logger interface {
+	Log(entry *Entry) error
+}
+
+// FileLogger appends entries to a file.
+type FileLogger struct {
+	Path string
+	// User and Args are recorded in entries that don't have them
+	User string
+	Args []string
+}
+
+// NewDefaultLogger returns the logger of the file named by $KESCTL_AUDIT_LOG,
+// or ~/.kesctl/audit.log when it is unset, recording the current user and
+// command line.
+func NewDefaultLogger() *FileLogger {
+	return &FileLogger{
+		Path: DefaultPath(),
+		User: currentUser(),
+		Args: os.Args,
+	}
+}
+
+// DefaultPath returns the file named by $KESCTL_AUDIT_LOG, or ~/.kesctl/audit.log.
+func DefaultPath() string {
+	if path := os.Getenv(PathEnv); len(path) > 0 {
+		return path
+	}
+	return RecommendedPath
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Log appends entry to the file as a single line. The file is opened for every
+// entry, in append mode, so that concurrent invocations don't overwrite each
+// other. Only the owner can read it.
+func (l *FileLogger) Log(entry *Entry) error {
+	if len(entry.User) == 0 {
+		entry.User = l.User
+	}
+	if entry.Args == nil {
+		entry.Args = l.Args
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Record logs entry with logger, warning on errOut, or in the log when errOut
+// is nil, if it can't be written. A broken audit log doesn't fail the command.
+func Record(logger Logger, errOut io.Writer, entry *Entry) {
+	err := logger.Log(entry)
+	if err == nil {
+		return
+	}
+	if errOut == nil {
+		klog.Warningf("unable to write the audit log: %v", err)
+		return
+	}
+	fmt.Fprintf(errOut, "warning: unable to write the audit log: %v\n", err)
+}
+
+// Filter selects entries of the audit log. Empty fields match every entry.
+type Filter struct {
+	ClusterName string
+	// Since and Until bound the start time of the entries
+	Since time.Time
+	Until time.Time
+}
+
+// Matches returns true if entry is selected by the filter.
+func (f Filter) Matches(entry *Entry) bool {
+	if !f.Since.IsZero() && entry.Start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Start.After(f.Until) {
+		return false
+	}
+	if len(f.ClusterName) == 0 {
+		return true
+	}
+	for _, clusterName := range entry.Clusters() {
+		if clusterName == f.ClusterName {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns the entries of the audit log read from r that match filter.
+func Read(r io.Reader, filter Filter) ([]*Entry, error) {
+	entries := []*Entry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d of the audit log: %v", number, err)
+		}
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// CountingReader counts the bytes read through it.
+type CountingReader struct {
+	Reader io.Reader
+	n      int64
+}
+
+func (r *CountingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes read so far.
+func (r *CountingReader) Count() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.n)
+}
+
+// CountingWriter counts the bytes written through it.
+type CountingWriter struct {
+	Writer io.Writer
+	n      int64
+}
+
+func (w *CountingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	atomic.AddInt64(&w.n, int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes written so far.
+func (w *CountingWriter) Count() int64 {
+	if w == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&w.n)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	uexec "github.com/Angus-F/client-go/util/exec"
+)
+
+func TestFileLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := &FileLogger{Path: filepath.Join(dir, "state", "audit.log"), User: "alice", Args: []string{"kesctl", "exec"}}
+	first := NewEntry("exec", "dev", "default")
+	first.Finish(uexec.CodeExitError{Err: errors.New("command terminated with exit code 3"), Code: 3})
+	second := NewEntry("logs", "prod", "default")
+	second.User = "bob"
+	second.Finish(nil)
+	for _, entry := range []*Entry{first, second} {
+		if err := logger.Log(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(logger.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the audit log to be readable by its owner only, got %v", info.Mode())
+	}
+	file, err := os.Open(logger.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	entries, err := Read(file, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected the entries to be appended, got %d", len(entries))
+	}
+	if entries[0].User != "alice" || entries[0].ExitCode != 3 || strings.Join(entries[0].Args, " ") != "kesctl exec" {
+		t.Errorf("unexpected first entry %#v", entries[0])
+	}
+	if entries[1].User != "bob" || entries[1].ExitCode != 0 || len(entries[1].Error) > 0 {
+		t.Errorf("unexpected second entry %#v", entries[1])
+	}
+}
+
+func TestEntryFinish(t *testing.T) {
+	entry := NewEntry("cp", "dev", "default")
+	entry.Finish(errors.New("connection refused"))
+	if entry.ExitCode != -1 || entry.Error != "connection refused" || entry.End.Before(entry.Start) {
+		t.Errorf("unexpected entry %#v", entry)
+	}
+}
+
+func TestRead(t *testing.T) {
+	log := `{"action":"exec","clusterName":"dev","start":"2021-03-01T10:00:00Z"}
+{"action":"logs","clusterName":"dev,prod","start":"2021-03-02T10:00:00Z"}
+
+{"action":"cp","clusterName":"prod","start":"2021-03-03T10:00:00Z"}
+`
+	day := func(d int) time.Time { return time.Date(2021, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected string
+	}{
+		{
+			name:     "everything",
+			expected: "exec,logs,cp",
+		},
+		{
+			name:     "cluster",
+			filter:   Filter{ClusterName: "prod"},
+			expected: "logs,cp",
+		},
+		{
+			name:     "time range",
+			filter:   Filter{Since: day(2), Until: day(3)},
+			expected: "logs",
+		},
+		{
+			name:     "cluster and time range",
+			filter:   Filter{ClusterName: "dev", Since: day(2)},
+			expected: "logs",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := Read(strings.NewReader(log), test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions := []string{}
+			for _, entry := range entries {
+				actions = append(actions, entry.Action)
+			}
+			if strings.Join(actions, ",") != test.expected {
+				t.Errorf("expected %s, got %v", test.expected, actions)
+			}
+		})
+	}
+
+	if _, err := Read(strings.NewReader("{}\nnot json\n"), Filter{}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an invalid line to be reported, got %v", err)
+	}
+}
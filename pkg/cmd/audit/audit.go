@@ -0,0 +1,222 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/cli-runtime/pkg/printers"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var (
+	auditLong = templates.LongDesc(i18n.T(`
+		Query the local audit log of the exec, cp, logs and port-forward commands.
+
+		Every command that reaches a cluster is appended to the audit log with
+		the user, cluster, namespace, pod, container, command line, start and
+		end time, exit code and the bytes sent and received. The log is
+		~/.kesctl/audit.log, or the file named by $KESCTL_AUDIT_LOG.`))
+
+	auditExample = templates.Examples(i18n.T(`
+		# List every command run against the clusters
+		kubectl audit
+
+		# List the commands run against the prod cluster during the last day
+		kubectl audit -C prod --since=24h
+
+		# List the commands run on the 1st of March 2021 as JSON lines
+		kubectl audit --since=2021-03-01T00:00:00Z --until=2021-03-02T00:00:00Z -o json`))
+)
+
+// AuditOptions are the options of the audit command
+type AuditOptions struct {
+	ClusterName string
+	Since       string
+	Until       string
+	Output      string
+	// Path is the audit log, audit.DefaultPath() by default
+	Path string
+
+	filter audit.Filter
+	now    func() time.Time
+
+	genericclioptions.IOStreams
+}
+
+// NewAuditOptions returns the default options of the audit command
+func NewAuditOptions(ioStreams genericclioptions.IOStreams) *AuditOptions {
+	return &AuditOptions{
+		now:       time.Now,
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdAudit returns the audit command
+func NewCmdAudit(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewAuditOptions(ioStreams)
+	cmd := &cobra.Command{
+		Use:                   "audit [-C CLUSTER] [--since=TIME] [--until=TIME]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Query the audit log of the commands run against the clusters"),
+		Long:                  auditLong,
+		Example:               auditExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.ClusterName, "clusterName", "C", o.ClusterName, "Only list the commands run against this cluster")
+	cmd.Flags().StringVar(&o.Since, "since", o.Since, "Only list the commands started after this time, either a relative duration like 5s, 2m or 3h, or a date (RFC3339)")
+	cmd.Flags().StringVar(&o.Until, "until", o.Until, "Only list the commands started before this time, either a relative duration like 5s, 2m or 3h, or a date (RFC3339)")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format. One of: json. json prints the entries as they are in the audit log, one JSON object per line.")
+	return cmd
+}
+
+// Complete parses the time range of the query
+func (o *AuditOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return cmdutil.UsageErrorf(cmd, "unexpected arguments: %v", args)
+	}
+	if len(o.Path) == 0 {
+		o.Path = audit.DefaultPath()
+	}
+
+	o.filter.ClusterName = o.ClusterName
+	var err error
+	if o.filter.Since, err = o.parseTime(o.Since); err != nil {
+		return fmt.Errorf("invalid --since: %v", err)
+	}
+	if o.filter.Until, err = o.parseTime(o.Until); err != nil {
+		return fmt.Errorf("invalid --until: %v", err)
+	}
+	return nil
+}
+
+// parseTime parses a duration before now or an RFC3339 date, the zero time
+// if value is empty
+func (o *AuditOptions) parseTime(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return o.now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a duration nor a date in the RFC3339 format", value)
+	}
+	return t, nil
+}
+
+// Validate checks the query options
+func (o *AuditOptions) Validate() error {
+	if len(o.Output) > 0 && o.Output != "json" {
+		return fmt.Errorf("unexpected -o output mode: %s, the flag 'output' must be json", o.Output)
+	}
+	if !o.filter.Since.IsZero() && !o.filter.Until.IsZero() && o.filter.Until.Before(o.filter.Since) {
+		return fmt.Errorf("--until must not be before --since")
+	}
+	return nil
+}
+
+// Run prints the entries of the audit log matching the query
+func (o *AuditOptions) Run() error {
+	file, err := os.Open(o.Path)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(o.ErrOut, "No audit entries found in %s.\n", o.Path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries, err := audit.Read(file, o.filter)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(o.ErrOut, "No audit entries found in %s.\n", o.Path)
+		return nil
+	}
+	if o.Output == "json" {
+		return o.printJSON(entries)
+	}
+	return o.printTable(entries)
+}
+
+func (o *AuditOptions) printJSON(entries []*audit.Entry) error {
+	encoder := json.NewEncoder(o.Out)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *AuditOptions) printTable(entries []*audit.Entry) error {
+	w := printers.GetNewTabWriter(o.Out)
+	fmt.Fprintln(w, "START\tUSER\tCLUSTER\tACTION\tNAMESPACE\tPOD\tEXIT CODE\tDURATION\tSENT\tRECEIVED\tCOMMAND")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%d\t%d\t%s\n",
+			entry.Start.Local().Format(time.RFC3339),
+			valueOrNone(entry.User),
+			valueOrNone(entry.ClusterName),
+			entry.Action,
+			valueOrNone(entry.Namespace),
+			valueOrNone(entry.Pod),
+			entry.ExitCode,
+			duration.HumanDuration(entry.End.Sub(entry.Start)),
+			entry.BytesIn,
+			entry.BytesOut,
+			commandLine(entry))
+	}
+	return w.Flush()
+}
+
+// commandLine returns the command run in the container, or the command line
+// of kesctl for the actions that don't run one
+func commandLine(entry *audit.Entry) string {
+	if len(entry.Command) > 0 {
+		return strings.Join(entry.Command, " ")
+	}
+	if len(entry.Args) > 1 {
+		return strings.Join(entry.Args[1:], " ")
+	}
+	return "<none>"
+}
+
+func valueOrNone(value string) string {
+	if len(value) == 0 {
+		return "<none>"
+	}
+	return value
+}
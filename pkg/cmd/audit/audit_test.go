@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+)
+
+const auditLog = `{"user":"alice","action":"exec","clusterName":"dev","namespace":"default","pod":"web-1","container":"app","command":["date"],"start":"2021-03-01T10:00:00Z","end":"2021-03-01T10:00:02Z","exitCode":0,"bytesIn":0,"bytesOut":29}
+{"user":"bob","action":"cp","clusterName":"prod","namespace":"default","pod":"web-2","args":["kesctl","cp","web-2:/etc/hosts","hosts","-C","prod"],"start":"2021-03-02T10:00:00Z","end":"2021-03-02T10:01:00Z","exitCode":-1,"error":"connection refused","bytesIn":0,"bytesOut":0}
+`
+
+func TestAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	if err := ioutil.WriteFile(path, []byte(auditLog), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		options     AuditOptions
+		expected    []string
+		notExpected []string
+		expectedErr string
+	}{
+		{
+			name:     "table",
+			expected: []string{"START", "alice", "dev", "exec", "web-1", "date", "bob", "prod", "cp", "web-2:/etc/hosts hosts -C prod"},
+		},
+		{
+			name:        "cluster",
+			options:     AuditOptions{ClusterName: "prod"},
+			expected:    []string{"bob"},
+			notExpected: []string{"alice"},
+		},
+		{
+			name:        "relative time",
+			options:     AuditOptions{Since: "24h"},
+			expected:    []string{"bob"},
+			notExpected: []string{"alice"},
+		},
+		{
+			name:        "time range",
+			options:     AuditOptions{Since: "2021-03-01T00:00:00Z", Until: "2021-03-01T23:59:59Z"},
+			expected:    []string{"alice"},
+			notExpected: []string{"bob"},
+		},
+		{
+			name:        "json",
+			options:     AuditOptions{ClusterName: "dev", Output: "json"},
+			expected:    []string{`"user":"alice"`, `"bytesOut":29`},
+			notExpected: []string{"START", "bob"},
+		},
+		{
+			name:        "invalid time",
+			options:     AuditOptions{Since: "yesterday"},
+			expectedErr: "invalid --since",
+		},
+		{
+			name:        "inverted time range",
+			options:     AuditOptions{Since: "1h", Until: "2h"},
+			expectedErr: "--until must not be before --since",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			o := NewAuditOptions(streams)
+			o.Path = path
+			o.now = func() time.Time { return now }
+			o.ClusterName, o.Since, o.Until, o.Output = test.options.ClusterName, test.options.Since, test.options.Until, test.options.Output
+
+			err := o.Complete(NewCmdAudit(streams), nil)
+			if err == nil {
+				err = o.Validate()
+			}
+			if err == nil {
+				err = o.Run()
+			}
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, s := range test.expected {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("expected %q in output:\n%s", s, out.String())
+				}
+			}
+			for _, s := range test.notExpected {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("unexpected %q in output:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAuditMissingLog(t *testing.T) {
+	streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+	o := NewAuditOptions(streams)
+	o.Path = filepath.Join(os.TempDir(), "missing-audit.log")
+	if err := o.Complete(NewCmdAudit(streams), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Len() != 0 || !strings.Contains(errOut.String(), "No audit entries found") {
+		t.Errorf("expected a message on stderr, got %q and %q", out.String(), errOut.String())
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/cli-runtime/pkg/printers"
+	"github.com/Angus-F/client-go/discovery"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/version"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var (
+	checkLong = templates.LongDesc(i18n.T(`
+		Check that the registered clusters are reachable.
+
+		The version of the API server of every cluster is requested
+		concurrently, and printed with the latency of the request. The command
+		fails if any cluster is unreachable.`))
+
+	checkExample = templates.Examples(i18n.T(`
+		# Check every registered cluster
+		kubectl clusters check
+
+		# Check the prod clusters, giving each of them at most 2 seconds
+		kubectl clusters check -C 'prod-*' --timeout=2s`))
+)
+
+const (
+	defaultCheckTimeout     = 5 * time.Second
+	defaultCheckMaxParallel = 10
+)
+
+// CheckOptions are the options of the clusters check command
+type CheckOptions struct {
+	ClusterName string
+	Timeout     time.Duration
+	MaxParallel int
+
+	factory cmdutil.Factory
+	// probe requests the version of the API server of a cluster
+	probe func(client *cmdutil.ClusterClient, timeout time.Duration) (*version.Info, error)
+
+	genericclioptions.IOStreams
+}
+
+// checkResult is the outcome of probing a single cluster
+type checkResult struct {
+	name    string
+	version *version.Info
+	latency time.Duration
+	err     error
+}
+
+// NewCheckOptions returns the default options of the clusters check command
+func NewCheckOptions(streams genericclioptions.IOStreams) *CheckOptions {
+	return &CheckOptions{
+		Timeout:     defaultCheckTimeout,
+		MaxParallel: defaultCheckMaxParallel,
+		probe:       probeServerVersion,
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdClustersCheck returns the clusters check command
+func NewCmdClustersCheck(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewCheckOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "check [-C CLUSTERS] [--timeout=DURATION]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Check the connectivity to the registered clusters"),
+		Long:                  checkLong,
+		Example:               checkExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.ClusterName, "clusterName", "C", o.ClusterName, "Only check these clusters, as a comma separated list of cluster names or glob patterns like 'prod-*'")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "The time to wait for every cluster to answer")
+	cmd.Flags().IntVar(&o.MaxParallel, "max-parallel", o.MaxParallel, "Maximum number of clusters to check concurrently")
+	return cmd
+}
+
+// Complete binds the options to the factory
+func (o *CheckOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return cmdutil.UsageErrorf(cmd, "unexpected arguments: %v", args)
+	}
+	o.factory = f
+	return nil
+}
+
+// Validate checks the limits of the check
+func (o *CheckOptions) Validate() error {
+	if o.Timeout <= 0 {
+		return fmt.Errorf("--timeout must be greater than 0")
+	}
+	if o.MaxParallel < 1 {
+		return fmt.Errorf("--max-parallel must be greater than 0")
+	}
+	return nil
+}
+
+// Run probes the selected clusters and prints a table of the results
+func (o *CheckOptions) Run() error {
+	names, err := resolveNames(o.factory, o.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	results := make([]checkResult, len(names))
+	sem := make(chan struct{}, o.MaxParallel)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = o.check(name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	failed := 0
+	w := printers.GetNewTabWriter(o.Out)
+	fmt.Fprintln(w, "NAME\tSTATUS\tVERSION\tLATENCY\tMESSAGE")
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\tUnreachable\t<unknown>\t%s\t%v\n", result.name, formatLatency(result.latency), result.err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tOk\t%s\t%s\t\n", result.name, result.version.GitVersion, formatLatency(result.latency))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d clusters are unreachable", failed, len(results))
+	}
+	return nil
+}
+
+func (o *CheckOptions) check(name string) checkResult {
+	result := checkResult{name: name}
+	client, err := o.factory.ClientForCluster(name)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	start := time.Now()
+	result.version, result.err = o.probe(client, o.Timeout)
+	result.latency = time.Since(start)
+	return result
+}
+
+// probeServerVersion requests the version of the API server of the cluster,
+// bypassing the cached discovery client
+func probeServerVersion(client *cmdutil.ClusterClient, timeout time.Duration) (*version.Info, error) {
+	config, err := client.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	config = restclient.CopyConfig(config)
+	config.Timeout = timeout
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return discoveryClient.ServerVersion()
+}
+
+func formatLatency(latency time.Duration) string {
+	if latency == 0 {
+		return "<none>"
+	}
+	return latency.Round(time.Millisecond).String()
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"k8s.io/apimachinery/pkg/version"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+)
+
+func TestClustersCheck(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+	tf.ClusterNamesVal = []string{"dev", "prod-east", "prod-west"}
+
+	var lock sync.Mutex
+	probed := map[string]time.Duration{}
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewCheckOptions(streams)
+	o.Timeout = time.Second
+	o.probe = func(client *cmdutil.ClusterClient, timeout time.Duration) (*version.Info, error) {
+		lock.Lock()
+		probed[client.Name] = timeout
+		lock.Unlock()
+		if client.Name == "prod-west" {
+			return nil, errors.New("connection refused")
+		}
+		return &version.Info{GitVersion: "v1.21.1"}, nil
+	}
+	if err := o.Complete(tf, NewCmdClustersCheck(tf, streams), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := o.Run()
+	if err == nil || err.Error() != "1 of 3 clusters are unreachable" {
+		t.Errorf("expected one unreachable cluster, got %v", err)
+	}
+	if len(probed) != 3 || probed["dev"] != time.Second {
+		t.Errorf("expected every cluster to be probed with the timeout, got %v", probed)
+	}
+	expected := regexp.MustCompile(`^NAME +STATUS +VERSION +LATENCY +MESSAGE\n` +
+		`dev +Ok +v1\.21\.1 +\S+ *\n` +
+		`prod-east +Ok +v1\.21\.1 +\S+ *\n` +
+		`prod-west +Unreachable +<unknown> +\S+ +connection refused\n$`)
+	if !expected.MatchString(out.String()) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestClustersCheckValidate(t *testing.T) {
+	o := NewCheckOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.MaxParallel = 0
+	if err := o.Validate(); err == nil {
+		t.Errorf("expected --max-parallel=0 to be rejected")
+	}
+	o = NewCheckOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.Timeout = 0
+	if err := o.Validate(); err == nil {
+		t.Errorf("expected --timeout=0 to be rejected")
+	}
+}
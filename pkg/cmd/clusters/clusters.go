@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"fmt"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var clustersLong = templates.LongDesc(i18n.T(`
+	List, inspect and health-check the clusters --clusterName can refer to.
+
+	Clusters are registered from the kubeconfig files in ~/.kesctl/clusters, or
+	the directory named by $KESCTL_CLUSTERS_DIR, and from the configs embedded
+	in kesctl.`))
+
+// NewCmdClusters returns the clusters command and its subcommands
+func NewCmdClusters(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "clusters SUBCOMMAND",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("List, inspect and health-check the registered clusters"),
+		Long:                  clustersLong,
+		Run:                   cmdutil.DefaultSubCommandRun(streams.ErrOut),
+	}
+	cmd.AddCommand(NewCmdClustersList(f, streams))
+	cmd.AddCommand(NewCmdClustersCheck(f, streams))
+	cmd.AddCommand(NewCmdClustersCurrent(f, streams))
+	return cmd
+}
+
+// resolveNames returns the clusters selected by a --clusterName value, every
+// registered cluster if it is empty
+func resolveNames(f cmdutil.Factory, clusterName string) ([]string, error) {
+	return cmdutil.ResolveClusterNames(f, clusterName, len(clusterName) == 0)
+}
+
+// authType describes how a client authenticates to the cluster
+func authType(config *restclient.Config) string {
+	switch {
+	case config.ExecProvider != nil:
+		return fmt.Sprintf("exec (%s)", config.ExecProvider.Command)
+	case config.AuthProvider != nil:
+		return fmt.Sprintf("auth-provider (%s)", config.AuthProvider.Name)
+	case len(config.BearerToken) > 0 || len(config.BearerTokenFile) > 0:
+		return "token"
+	case len(config.CertData) > 0 || len(config.CertFile) > 0:
+		return "client-certificate"
+	case len(config.Username) > 0:
+		return "basic"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"fmt"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/cli-runtime/pkg/printers"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var (
+	currentLong = templates.LongDesc(i18n.T(`
+		Print the cluster the commands run in, and its namespace.
+
+		The cluster is selected by --clusterName, or else is the default cluster
+		set by 'kubectl use' or $KESCTL_CLUSTER, and must be registered.`))
+
+	currentExample = templates.Examples(i18n.T(`
+		# Print the default cluster
+		kubectl clusters current
+
+		# Print the cluster selected by -C
+		kubectl clusters current -C prod`))
+)
+
+// CurrentOptions are the options of the clusters current command
+type CurrentOptions struct {
+	ClusterName string
+
+	factory cmdutil.Factory
+
+	genericclioptions.IOStreams
+}
+
+// NewCmdClustersCurrent returns the clusters current command
+func NewCmdClustersCurrent(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := &CurrentOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:                   "current [-C CLUSTER]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Print the selected cluster"),
+		Long:                  currentLong,
+		Example:               currentExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmdutil.AddClusterVarFlags(cmd, &o.ClusterName, o.ClusterName)
+	return cmd
+}
+
+// Complete binds the options to the factory
+func (o *CurrentOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return cmdutil.UsageErrorf(cmd, "unexpected arguments: %v", args)
+	}
+	o.factory = f
+	return nil
+}
+
+// Run prints the selected cluster and its namespace
+func (o *CurrentOptions) Run() error {
+	clusterName := o.ClusterName
+	if len(clusterName) == 0 {
+		defaultClusterName, err := o.factory.DefaultClusterName()
+		if err != nil {
+			return err
+		}
+		if len(defaultClusterName) == 0 {
+			return fmt.Errorf("Please set the clusterName")
+		}
+		clusterName = defaultClusterName
+	}
+	client, err := o.factory.ClientForCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	w := printers.GetNewTabWriter(o.Out)
+	fmt.Fprintln(w, "NAME\tNAMESPACE")
+	fmt.Fprintf(w, "%s\t%s\n", client.Name, client.Namespace)
+	return w.Flush()
+}
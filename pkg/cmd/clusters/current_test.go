@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+)
+
+func TestClustersCurrent(t *testing.T) {
+	tests := []struct {
+		name               string
+		clusterName        string
+		defaultClusterName string
+		expectedOut        string
+		expectedErr        string
+	}{
+		{
+			name:        "selected",
+			clusterName: "prod",
+			expectedOut: "NAME   NAMESPACE\nprod   test\n",
+		},
+		{
+			name:               "default",
+			defaultClusterName: "dev",
+			expectedOut:        "NAME   NAMESPACE\ndev    test\n",
+		},
+		{
+			name:               "selected over the default",
+			clusterName:        "prod",
+			defaultClusterName: "dev",
+			expectedOut:        "NAME   NAMESPACE\nprod   test\n",
+		},
+		{
+			name:        "nothing selected",
+			expectedErr: "Please set the clusterName",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.DefaultClusterNameVal = test.defaultClusterName
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			o := &CurrentOptions{ClusterName: test.clusterName, IOStreams: streams}
+			if err := o.Complete(tf, NewCmdClustersCurrent(tf, streams), nil); err != nil {
+				t.Fatal(err)
+			}
+			err := o.Run()
+			if len(test.expectedErr) > 0 {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != test.expectedOut {
+				t.Errorf("expected output %q, got %q", test.expectedOut, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"fmt"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/cli-runtime/pkg/printers"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var (
+	listLong = templates.LongDesc(i18n.T(`
+		List the registered clusters with their API server, default namespace
+		and the way kesctl authenticates to them.`))
+
+	listExample = templates.Examples(i18n.T(`
+		# List every registered cluster
+		kubectl clusters list
+
+		# List the clusters whose names start with prod-
+		kubectl clusters list -C 'prod-*'
+
+		# Print only the names of the clusters
+		kubectl clusters list -o name`))
+)
+
+// ListOptions are the options of the clusters list command
+type ListOptions struct {
+	ClusterName string
+	Output      string
+
+	factory cmdutil.Factory
+
+	genericclioptions.IOStreams
+}
+
+// NewCmdClustersList returns the clusters list command
+func NewCmdClustersList(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := &ListOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:                   "list [-C CLUSTERS] [-o name]",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"ls"},
+		Short:                 i18n.T("List the registered clusters"),
+		Long:                  listLong,
+		Example:               listExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.ClusterName, "clusterName", "C", o.ClusterName, "Only list these clusters, as a comma separated list of cluster names or glob patterns like 'prod-*'")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format. One of: name.")
+	return cmd
+}
+
+// Complete binds the options to the factory
+func (o *ListOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return cmdutil.UsageErrorf(cmd, "unexpected arguments: %v", args)
+	}
+	o.factory = f
+	return nil
+}
+
+// Validate checks the output format
+func (o *ListOptions) Validate() error {
+	if len(o.Output) > 0 && o.Output != "name" {
+		return fmt.Errorf("unexpected -o output mode: %s, the flag 'output' must be name", o.Output)
+	}
+	return nil
+}
+
+// Run prints the selected clusters
+func (o *ListOptions) Run() error {
+	names, err := resolveNames(o.factory, o.ClusterName)
+	if err != nil {
+		return err
+	}
+	if o.Output == "name" {
+		for _, name := range names {
+			fmt.Fprintln(o.Out, name)
+		}
+		return nil
+	}
+
+	w := printers.GetNewTabWriter(o.Out)
+	fmt.Fprintln(w, "NAME\tSERVER\tNAMESPACE\tAUTH")
+	for _, name := range names {
+		server, namespace, auth, err := o.describe(name)
+		if err != nil {
+			// a broken kubeconfig must not hide the other clusters
+			fmt.Fprintf(o.ErrOut, "warning: cluster %s: %v\n", name, err)
+			server, namespace, auth = "<invalid>", "<unknown>", "<unknown>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, server, namespace, auth)
+	}
+	return w.Flush()
+}
+
+func (o *ListOptions) describe(name string) (server, namespace, auth string, err error) {
+	client, err := o.factory.ClientForCluster(name)
+	if err != nil {
+		return "", "", "", err
+	}
+	config, err := client.ToRESTConfig()
+	if err != nil {
+		return "", "", "", err
+	}
+	return config.Host, client.Namespace, authType(config), nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusters
+
+import (
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	clientcmdapi "github.com/Angus-F/client-go/tools/clientcmd/api"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+)
+
+func TestClustersList(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterName string
+		output      string
+		expectedOut string
+	}{
+		{
+			name: "every cluster",
+			expectedOut: "NAME        SERVER                   NAMESPACE   AUTH\n" +
+				"dev         https://127.0.0.1:6443   test        token\n" +
+				"prod-east   https://127.0.0.1:6443   test        token\n" +
+				"prod-west   https://127.0.0.1:6443   test        token\n",
+		},
+		{
+			name:        "pattern",
+			clusterName: "prod-*",
+			output:      "name",
+			expectedOut: "prod-east\nprod-west\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.ClusterNamesVal = []string{"dev", "prod-east", "prod-west"}
+			tf.ClientConfigVal = &restclient.Config{Host: "https://127.0.0.1:6443", BearerToken: "secret"}
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			cmd := NewCmdClustersList(tf, streams)
+			o := &ListOptions{ClusterName: test.clusterName, Output: test.output, IOStreams: streams}
+			if err := o.Complete(tf, cmd, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Run(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != test.expectedOut {
+				t.Errorf("expected output\n%s\ngot\n%s", test.expectedOut, out.String())
+			}
+		})
+	}
+}
+
+func TestAuthType(t *testing.T) {
+	tests := []struct {
+		config   *restclient.Config
+		expected string
+	}{
+		{config: &restclient.Config{ExecProvider: &clientcmdapi.ExecConfig{Command: "aws"}, BearerToken: "ignored"}, expected: "exec (aws)"},
+		{config: &restclient.Config{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "oidc"}}, expected: "auth-provider (oidc)"},
+		{config: &restclient.Config{BearerTokenFile: "/var/run/token"}, expected: "token"},
+		{config: &restclient.Config{TLSClientConfig: restclient.TLSClientConfig{CertFile: "client.crt"}}, expected: "client-certificate"},
+		{config: &restclient.Config{Username: "admin"}, expected: "basic"},
+		{config: &restclient.Config{}, expected: "none"},
+	}
+	for _, test := range tests {
+		if actual := authType(test.config); actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
@@ -30,11 +30,18 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/kubectl/pkg/cmd/audit"
+	"github.com/Angus-F/kubectl/pkg/cmd/clusters"
+	"github.com/Angus-F/kubectl/pkg/cmd/completion"
 	"github.com/Angus-F/kubectl/pkg/cmd/cp"
 	cmdexec "github.com/Angus-F/kubectl/pkg/cmd/exec"
 	"github.com/Angus-F/kubectl/pkg/cmd/logs"
 	"github.com/Angus-F/kubectl/pkg/cmd/plugin"
+	"github.com/Angus-F/kubectl/pkg/cmd/portforward"
+	"github.com/Angus-F/kubectl/pkg/cmd/replay"
+	"github.com/Angus-F/kubectl/pkg/cmd/use"
 	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	utilcompletion "github.com/Angus-F/kubectl/pkg/util/completion"
 	"github.com/Angus-F/kubectl/pkg/util/i18n"
 	"github.com/Angus-F/kubectl/pkg/util/templates"
 	"github.com/Angus-F/kubectl/pkg/util/term"
@@ -291,10 +298,9 @@ const kubectlCmdHeaders = "KUBECTL_COMMAND_HEADERS"
 
 var (
 	bashCompletionFlags = map[string]string{
-		"namespace": "__kubectl_get_resource_namespace",
-		"context":   "__kubectl_config_get_contexts",
-		"cluster":   "__kubectl_config_get_clusters",
-		"user":      "__kubectl_config_get_users",
+		"context": "__kubectl_config_get_contexts",
+		"cluster": "__kubectl_config_get_clusters",
+		"user":    "__kubectl_config_get_users",
 	}
 )
 
@@ -316,10 +322,15 @@ func NewDefaultKubectlCommandWithArgs(pluginHandler PluginHandler, args []string
 
 		// only look for suitable extension executables if
 		// the specified command does not already exist
-		if _, _, err := cmd.Find(cmdPathPieces); err != nil {
-			if err := HandlePluginCommand(pluginHandler, cmdPathPieces); err != nil {
-				fmt.Fprintf(errout, "Error: %v\n", err)
-				os.Exit(1)
+		switch cmdPathPieces[0] {
+		case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			// the completion commands are added by cobra on execution
+		default:
+			if _, _, err := cmd.Find(cmdPathPieces); err != nil {
+				if err := HandlePluginCommand(pluginHandler, cmdPathPieces); err != nil {
+					fmt.Fprintf(errout, "Error: %v\n", err)
+					os.Exit(1)
+				}
 			}
 		}
 	}
@@ -443,7 +454,7 @@ func NewKubectlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 		Long: templates.LongDesc(`
       kesctl controls the Kubernetes cluster manager only for 'exec', 'cp' and 'logs', 
       and this version need user to choose the specific cluster by --clusterName|-C, 
-      otherwise it may cause error.`),
+      or to set a default cluster by 'kesctl use', otherwise it may cause error.`),
 		Run: runHelp,
 		// Hook before and after Run initialize and write profiles to disk,
 		// respectively.
@@ -553,6 +564,13 @@ func NewKubectlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 				taint.NewCmdTaint(f, ioStreams),
 			},
 		},*/
+		{
+			Message: "Cluster Management Commands:",
+			Commands: []*cobra.Command{
+				clusters.NewCmdClusters(f, ioStreams),
+				use.NewCmdUse(f, ioStreams),
+			},
+		},
 		{
 			Message: "Troubleshooting and Debugging Commands:",
 			Commands: []*cobra.Command{
@@ -560,13 +578,21 @@ func NewKubectlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 				logs.NewCmdLogs(f, ioStreams),
 				//attach.NewCmdAttach(f, ioStreams),
 				cmdexec.NewCmdExec(f, ioStreams),
-				//portforward.NewCmdPortForward(f, ioStreams),
+				replay.NewCmdReplay(ioStreams),
+				portforward.NewCmdPortForward(f, ioStreams),
 				//proxyCmd,
 				cp.NewCmdCp(f, ioStreams),
+				audit.NewCmdAudit(ioStreams),
 				//auth.NewCmdAuth(f, ioStreams),
 				//debug.NewCmdDebug(f, ioStreams),
 			},
 		},
+		{
+			Message: "Settings Commands:",
+			Commands: []*cobra.Command{
+				completion.NewCmdCompletion(ioStreams.Out, ""),
+			},
+		},
 		/**
 		{
 			Message: "Advanced Commands:",
@@ -613,6 +639,8 @@ func NewKubectlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 			)
 		}
 	}
+	cmdutil.CheckErr(cmds.RegisterFlagCompletionFunc("namespace", utilcompletion.NamespaceCompletionFunc(f)))
+	registerClusterNameCompletion(cmds, f)
 /**
 	cmds.AddCommand(alpha)
 	cmds.AddCommand(cmdconfig.NewCmdConfig(f, clientcmd.NewDefaultPathOptions(), ioStreams))
@@ -627,6 +655,17 @@ func NewKubectlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 	return cmds
 }
 
+// registerClusterNameCompletion completes the --clusterName flag of cmd and
+// its subcommands from the cluster registry
+func registerClusterNameCompletion(cmd *cobra.Command, f cmdutil.Factory) {
+	if cmd.Flags().Lookup("clusterName") != nil {
+		cmdutil.CheckErr(cmd.RegisterFlagCompletionFunc("clusterName", utilcompletion.ClusterNameCompletionFunc(f)))
+	}
+	for _, child := range cmd.Commands() {
+		registerClusterNameCompletion(child, f)
+	}
+}
+
 // addCmdHeaderHooks performs updates on two hooks:
 //   1) Modifies the passed "cmds" persistent pre-run function to parse command headers.
 //      These headers will be subsequently added as X-headers to every
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/tools/remotecommand"
+	"github.com/spf13/cobra"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+	"github.com/Angus-F/kubectl/pkg/cmd/exec"
+)
+
+// runAudited runs the copy and logs it to the audit log with the bytes sent
+// to and received from the containers by every remote command of the copy
+func (o *CopyOptions) runAudited(cmd *cobra.Command, args []string) error {
+	entry := o.auditEntry(args)
+	counter := &countingExecutor{executor: o.executor()}
+	o.Executor = counter
+
+	err := o.run(cmd, args)
+
+	entry.BytesIn = atomic.LoadInt64(&counter.in)
+	entry.BytesOut = atomic.LoadInt64(&counter.out)
+	entry.Finish(err)
+	audit.Record(o.Auditor, o.ErrOut, entry)
+	return err
+}
+
+// auditEntry starts the entry of a copy between the file specs of args. The
+// pod is the first remote one, the clusters are all the ones copied from or to.
+func (o *CopyOptions) auditEntry(args []string) *audit.Entry {
+	entry := audit.NewEntry("cp", "", o.Namespace)
+	entry.Container = o.Container
+	clusters := []string{}
+	for _, arg := range args {
+		spec, err := extractFileSpec(arg, o.clusterNames)
+		if err != nil || len(spec.PodName) == 0 {
+			continue
+		}
+		clusterName := spec.ClusterName
+		if len(clusterName) == 0 {
+			clusterName = o.ClusterName
+		}
+		if !containsString(clusters, clusterName) {
+			clusters = append(clusters, clusterName)
+		}
+		if len(entry.Pod) == 0 {
+			entry.Pod = spec.PodName
+			if len(spec.PodNamespace) > 0 {
+				entry.Namespace = spec.PodNamespace
+			}
+		}
+	}
+	if len(clusters) == 0 {
+		clusters = append(clusters, o.ClusterName)
+	}
+	entry.ClusterName = strings.Join(clusters, ",")
+	return entry
+}
+
+// countingExecutor counts the bytes streamed to and from the remote commands
+// run by executor
+type countingExecutor struct {
+	executor exec.RemoteExecutor
+	in, out  int64
+}
+
+func (e *countingExecutor) Execute(method string, url *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	var inCount *audit.CountingReader
+	var outCount, errCount *audit.CountingWriter
+	if stdin != nil {
+		inCount = &audit.CountingReader{Reader: stdin}
+		stdin = inCount
+	}
+	if stdout != nil {
+		outCount = &audit.CountingWriter{Writer: stdout}
+		stdout = outCount
+	}
+	if stderr != nil {
+		errCount = &audit.CountingWriter{Writer: stderr}
+		stderr = errCount
+	}
+	err := e.executor.Execute(method, url, config, stdin, stdout, stderr, tty, terminalSizeQueue)
+	atomic.AddInt64(&e.in, inCount.Count())
+	atomic.AddInt64(&e.out, outCount.Count()+errCount.Count())
+	return err
+}
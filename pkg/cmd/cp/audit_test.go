@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+)
+
+type fakeAuditor struct {
+	entries []*audit.Entry
+}
+
+func (a *fakeAuditor) Log(entry *audit.Entry) error {
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+func TestCopyAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := strings.Repeat("0123456789", 250)
+	remoteFile := filepath.Join(dir, "remote")
+	createTmpFile(t, remoteFile, content)
+	localFile := filepath.Join(dir, "local")
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedPod  string
+		expectedExit int
+	}{
+		{
+			name:        "from a pod",
+			args:        []string{"web-1:" + remoteFile, localFile},
+			expectedPod: "web-1",
+		},
+		{
+			name:         "a missing file to a pod",
+			args:         []string{filepath.Join(dir, "missing"), "web-1:" + filepath.Join(dir, "copy")},
+			expectedPod:  "web-1",
+			expectedExit: -1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auditor := &fakeAuditor{}
+			o, _ := newTransferTestOptions(t, &localExecutor{})
+			o.ClusterName = "dev"
+			o.Auditor = auditor
+
+			err := o.Run(nil, test.args)
+			if test.expectedExit == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(auditor.entries) != 1 {
+				t.Fatalf("expected one audit entry, got %d", len(auditor.entries))
+			}
+			entry := auditor.entries[0]
+			if entry.Action != "cp" || entry.ClusterName != "dev" || entry.Namespace != "test" || entry.Pod != test.expectedPod {
+				t.Errorf("unexpected entry %#v", entry)
+			}
+			if test.expectedExit == 0 && entry.BytesOut < int64(len(content)) {
+				t.Errorf("expected at least the %d bytes of the file to be counted, got %d", len(content), entry.BytesOut)
+			}
+			if entry.ExitCode != test.expectedExit {
+				t.Errorf("expected exit code %d, got %d", test.expectedExit, entry.ExitCode)
+			}
+		})
+	}
+}
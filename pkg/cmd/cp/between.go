@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/kubectl/pkg/cmd/exec"
+)
+
+// copyBetweenPods streams the tar of src out of one exec session into
+// another one extracting it at dest. The pods may be in different clusters,
+// nothing is staged on the local disk.
+func (o *CopyOptions) copyBetweenPods(src, dest fileSpec) error {
+	if len(src.File) == 0 || len(dest.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+	// strip trailing slash (if any)
+	if dest.File != "/" && strings.HasSuffix(dest.File, "/") {
+		dest.File = dest.File[:len(dest.File)-1]
+	}
+	if err := o.checkDestinationIsDir(dest); err == nil {
+		dest.File = dest.File + "/" + path.Base(src.File)
+	}
+
+	prefix := getPrefix(src.File)
+	prefix = path.Clean(prefix)
+	prefix = stripPathShortcuts(prefix)
+
+	// both exec sessions write to the same streams
+	streams := *o
+	streams.Out = &syncWriter{writer: o.Out}
+	streams.ErrOut = &syncWriter{writer: o.ErrOut}
+	o = &streams
+
+	retries := &retryBudget{max: o.Retries}
+	for {
+		err := o.streamBetweenPods(src, dest, prefix)
+		if err == nil && o.Verify {
+			err = o.verifyBetweenPods(src, dest)
+		}
+
+		if err == nil || !isRetryable(err) || !retries.next() {
+			return err
+		}
+		fmt.Fprintf(o.ErrOut, "Copying %s again after a %v, retry %s\n", src.File, err, retries)
+	}
+}
+
+func (o *CopyOptions) streamBetweenPods(src, dest fileSpec, prefix string) error {
+	reader, writer := io.Pipe()
+	progress := o.newProgressBar(0)
+	defer progress.finish()
+
+	srcErr := make(chan error, 1)
+	go func() {
+		pipe := o.newTarPipe(src)
+		defer pipe.Close()
+		err := renameTarEntries(io.TeeReader(pipe, progress), writer, prefixRenamer(prefix, path.Base(dest.File)))
+		writer.CloseWithError(err)
+		srcErr <- err
+	}()
+
+	options := &exec.ExecOptions{
+		StreamOptions: exec.StreamOptions{
+			IOStreams: genericclioptions.IOStreams{
+				In:     reader,
+				Out:    o.Out,
+				ErrOut: o.ErrOut,
+			},
+			Stdin: true,
+
+			Namespace: dest.PodNamespace,
+			PodName:   dest.PodName,
+		},
+
+		Command:  o.untarCommand(dest),
+		Executor: o.executor(),
+	}
+	destErr := o.execute(dest.ClusterName, options)
+	// unblock the source if the destination stopped reading early
+	reader.Close()
+
+	// a failed source stream is the cause of the destination failing, if it did
+	if err := <-srcErr; err != nil && err != io.ErrClosedPipe {
+		return err
+	}
+	return destErr
+}
+
+// tarRenamer maps the name of a tar entry to the name it is extracted as. It
+// returns false for entries that were not requested, which means the tar
+// stream was tampered with.
+type tarRenamer func(name string) (string, bool)
+
+// prefixRenamer replaces the prefix of every entry name with destName, the
+// way makeTar names the entries of a local file
+func prefixRenamer(prefix, destName string) tarRenamer {
+	return func(name string) (string, bool) {
+		if !strings.HasPrefix(name, prefix) {
+			return "", false
+		}
+		return path.Join(destName, name[len(prefix):]), true
+	}
+}
+
+// renameTarEntries copies the tar stream in to out, renaming its entries
+func renameTarEntries(in io.Reader, out io.Writer, rename tarRenamer) error {
+	tarReader := tar.NewReader(in)
+	tarWriter := tar.NewWriter(out)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := rename(header.Name)
+		if !ok {
+			return fmt.Errorf("tar contents corrupted")
+		}
+		header.Name = name
+		if header.Typeflag == tar.TypeLink {
+			if linkname, ok := rename(header.Linkname); ok {
+				header.Linkname = linkname
+			}
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// verifyBetweenPods compares the checksums of the files in src with the
+// checksums of their copies in dest
+func (o *CopyOptions) verifyBetweenPods(src, dest fileSpec) error {
+	checksums := func(spec fileSpec) (map[string]string, error) {
+		files, err := o.remoteChecksums(spec, "find", spec.File, "-type", "f", "-exec", "sha256sum", "{}", "+")
+		if err != nil {
+			return nil, err
+		}
+		relative := map[string]string{}
+		for file, checksum := range files {
+			relative[strings.TrimPrefix(path.Clean(file), path.Clean(spec.File))] = checksum
+		}
+		return relative, nil
+	}
+	srcChecksums, err := checksums(src)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "warning: unable to verify the checksums of %s: %v\n", src.File, err)
+		return nil
+	}
+	destChecksums, err := checksums(dest)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "warning: unable to verify the checksums of %s: %v\n", dest.File, err)
+		return nil
+	}
+
+	mismatched := []string{}
+	for name, checksum := range srcChecksums {
+		if destChecksums[name] != checksum {
+			mismatched = append(mismatched, path.Clean(src.File)+name)
+		}
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return &checksumError{files: mismatched}
+	}
+	return nil
+}
+
+// syncWriter serializes the writes of concurrent exec sessions
+type syncWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Write(p)
+}
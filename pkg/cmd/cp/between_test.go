@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/client-go/kubernetes/fake"
+	restclient "github.com/Angus-F/client-go/rest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func newTestClusterClients(host, namespace, podName string) *clusterClients {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: namespace},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+	}
+	return &clusterClients{
+		namespace:    namespace,
+		clientset:    fake.NewSimpleClientset(pod),
+		clientConfig: &restclient.Config{Host: host, APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}},
+	}
+}
+
+func TestCopyBetweenPods(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor *localExecutor
+		src      string
+		dest     string
+		// expected maps files relative to the temporary directory to their content
+		expected map[string]string
+	}{
+		{
+			name:     "directory into an existing directory",
+			executor: &localExecutor{},
+			src:      "data",
+			dest:     "dest",
+			expected: map[string]string{
+				"dest/data/a":     strings.Repeat("a", 2000),
+				"dest/data/sub/b": strings.Repeat("b", 2000),
+			},
+		},
+		{
+			name:     "directory to a new name",
+			executor: &localExecutor{},
+			src:      "data",
+			dest:     "dest/renamed",
+			expected: map[string]string{
+				"dest/renamed/a":     strings.Repeat("a", 2000),
+				"dest/renamed/sub/b": strings.Repeat("b", 2000),
+			},
+		},
+		{
+			name:     "single file",
+			executor: &localExecutor{},
+			src:      "data/a",
+			dest:     "dest/copy",
+			expected: map[string]string{
+				"dest/copy": strings.Repeat("a", 2000),
+			},
+		},
+		{
+			name:     "resume the source stream",
+			executor: &localExecutor{drops: 1, dropAfter: 3000},
+			src:      "data",
+			dest:     "dest",
+			expected: map[string]string{
+				"dest/data/a":     strings.Repeat("a", 2000),
+				"dest/data/sub/b": strings.Repeat("b", 2000),
+			},
+		},
+		{
+			name:     "restart after a truncated source stream",
+			executor: &localExecutor{drops: 1, dropAfter: 3000, silent: true},
+			src:      "data",
+			dest:     "dest",
+			expected: map[string]string{
+				"dest/data/a":     strings.Repeat("a", 2000),
+				"dest/data/sub/b": strings.Repeat("b", 2000),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o, errOut := newTransferTestOptions(t, test.executor)
+			o.clusterNames = []string{"east", "west"}
+			o.clusters = map[string]*clusterClients{
+				"east": newTestClusterClients("east.example.com", "team-a", "web-1"),
+				"west": newTestClusterClients("west.example.com", "team-b", "db-0"),
+			}
+
+			dir, err := ioutil.TempDir("", "cp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			for _, name := range []string{"data/sub", "dest"} {
+				if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			createTmpFile(t, filepath.Join(dir, "data/a"), strings.Repeat("a", 2000))
+			createTmpFile(t, filepath.Join(dir, "data/sub/b"), strings.Repeat("b", 2000))
+
+			src := "east:team-a/web-1:" + filepath.Join(dir, test.src)
+			dest := "west:team-b/db-0:" + filepath.Join(dir, test.dest)
+			if err := o.Run(nil, []string{src, dest}); err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+			}
+			for name, content := range test.expected {
+				cmpFileData(t, filepath.Join(dir, name), content)
+			}
+
+			for i, command := range test.executor.commandLines() {
+				host := test.executor.hosts[i]
+				switch {
+				case strings.HasPrefix(command, "tar cf") && host != "east.example.com":
+					t.Errorf("expected the source tar to run in cluster east, got %s", host)
+				case strings.HasPrefix(command, "tar -xmf") && host != "west.example.com":
+					t.Errorf("expected the destination tar to run in cluster west, got %s", host)
+				}
+			}
+		})
+	}
+}
+
+func TestRenameTarEntries(t *testing.T) {
+	in := &bytes.Buffer{}
+	tw := tar.NewWriter(in)
+	entries := []*tar.Header{
+		{Name: "var/data/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "var/data/file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		{Name: "var/data/hardlink", Typeflag: tar.TypeLink, Linkname: "var/data/file"},
+		{Name: "var/data/symlink", Typeflag: tar.TypeSymlink, Linkname: "../etc/passwd"},
+	}
+	for _, header := range entries {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			tw.Write([]byte("data"))
+		}
+	}
+	tw.Close()
+
+	out := &bytes.Buffer{}
+	if err := renameTarEntries(in, out, prefixRenamer("var/data", "copy")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"copy ", "copy/file ", "copy/hardlink copy/file", "copy/symlink ../etc/passwd"}
+	actual := []string{}
+	tr := tar.NewReader(out)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, header.Name+" "+header.Linkname)
+	}
+	if strings.Join(actual, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	corrupted := &bytes.Buffer{}
+	tw = tar.NewWriter(corrupted)
+	tw.WriteHeader(&tar.Header{Name: "etc/passwd", Typeflag: tar.TypeReg})
+	tw.Close()
+	if err := renameTarEntries(corrupted, ioutil.Discard, prefixRenamer("var/data", "copy")); err == nil || err.Error() != "tar contents corrupted" {
+		t.Errorf("expected the tar to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/util/completion"
+)
+
+// completeFileSpec completes a file spec argument: local paths are left to
+// the shell, pod names of --clusterName are completed with a trailing colon
+// and remote paths are listed with ls in the container
+func (o *CopyOptions) completeFileSpec(f cmdutil.Factory, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.HasPrefix(toComplete, "/") || strings.HasPrefix(toComplete, ".") || strings.HasPrefix(toComplete, "~") {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	if !strings.Contains(toComplete, ":") {
+		return o.completePods(f, toComplete)
+	}
+
+	o.clusterNames, _ = f.ClusterNames()
+	spec, err := extractFileSpec(toComplete, o.clusterNames)
+	if err != nil || len(spec.PodName) == 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	client := completion.ClusterClient(f, o.ClusterName)
+	if client == nil && len(spec.ClusterName) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	o.clientForCluster = f.ClientForCluster
+	if client != nil {
+		if err := o.completeDefaultCluster(); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+	// not the destination, listing files is not copying to the pod
+	specs := []fileSpec{spec, {}}
+	if err := o.completeClusters(specs...); err != nil || o.authorize(specs) != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	dir := spec.File[:strings.LastIndex(spec.File, "/")+1]
+	files, err := o.remoteFiles(spec, dir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	prefix := toComplete[:len(toComplete)-len(spec.File)] + dir
+	completions := []string{}
+	for _, file := range completion.FilterPrefix(files, spec.File[len(dir):]) {
+		completions = append(completions, prefix+file)
+	}
+	return completions, cobra.ShellCompDirectiveNoSpace
+}
+
+// completePods completes pod-name: or namespace/pod-name: in --clusterName
+func (o *CopyOptions) completePods(f cmdutil.Factory, toComplete string) ([]string, cobra.ShellCompDirective) {
+	client := completion.ClusterClient(f, o.ClusterName)
+	if client == nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	namespace, prefix := client.Namespace, ""
+	if i := strings.Index(toComplete, "/"); i >= 0 {
+		namespace, prefix = toComplete[:i], toComplete[:i+1]
+	}
+	pods, err := completion.PodNames(client, configs.CommandCp, namespace)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	completions := []string{}
+	for _, pod := range completion.FilterPrefix(pods, toComplete[len(prefix):]) {
+		completions = append(completions, prefix+pod+":")
+	}
+	if len(completions) == 0 {
+		// a relative local path
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return completions, cobra.ShellCompDirectiveNoSpace
+}
+
+// completeContainer completes --container with the containers of the first
+// pod named in the file specs
+func (o *CopyOptions) completeContainer(f cmdutil.Factory, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clusterNames, _ := f.ClusterNames()
+	for _, arg := range args {
+		spec, err := extractFileSpec(arg, clusterNames)
+		if err != nil || len(spec.PodName) == 0 {
+			continue
+		}
+		clusterName := o.ClusterName
+		if len(spec.ClusterName) > 0 {
+			clusterName = spec.ClusterName
+		}
+		client := completion.ClusterClient(f, clusterName)
+		if client == nil {
+			break
+		}
+		namespace := spec.PodNamespace
+		if len(namespace) == 0 {
+			namespace = client.Namespace
+		}
+		containers, err := completion.ContainerNames(client, configs.CommandCp, namespace, spec.PodName)
+		if err != nil {
+			break
+		}
+		return completion.FilterPrefix(containers, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// remoteFiles lists the files of dir in the container of spec, the names of
+// directories end with a slash
+func (o *CopyOptions) remoteFiles(spec fileSpec, dir string) ([]string, error) {
+	clients := o.clientsFor(spec.ClusterName)
+	namespace := spec.PodNamespace
+	if len(namespace) == 0 {
+		namespace = clients.namespace
+	}
+	clusterName := spec.ClusterName
+	if len(clusterName) == 0 {
+		clusterName = o.ClusterName
+	}
+	key := []string{"files", clusterName, namespace, spec.PodName, o.Container, dir}
+	return completion.Cached(key, func() ([]string, error) {
+		listDir := dir
+		if len(listDir) == 0 {
+			listDir = "."
+		}
+		out := &bytes.Buffer{}
+		if err := o.remoteExec(spec, out, ioutil.Discard, "ls", "-1Ap", "--", listDir); err != nil {
+			return nil, err
+		}
+		files := []string{}
+		for _, file := range strings.Split(out.String(), "\n") {
+			if len(file) > 0 {
+				files = append(files, file)
+			}
+		}
+		return files, nil
+	})
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/rest/fake"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+	"github.com/Angus-F/kubectl/pkg/util/completion"
+)
+
+func TestCompleteFileSpec(t *testing.T) {
+	cacheDir := completion.CacheDir
+	completion.CacheDir = t.TempDir()
+	defer func() { completion.CacheDir = cacheDir }()
+
+	dir := t.TempDir()
+	createTmpFile(t, filepath.Join(dir, "a"), "a")
+	createTmpFile(t, filepath.Join(dir, "b c"), "b")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		toComplete        string
+		policy            string
+		expected          []string
+		expectedDirective cobra.ShellCompDirective
+	}{
+		{
+			name:              "local path",
+			toComplete:        "./da",
+			expectedDirective: cobra.ShellCompDirectiveDefault,
+		},
+		{
+			name:              "pod",
+			toComplete:        "web",
+			expected:          []string{"web-1:"},
+			expectedDirective: cobra.ShellCompDirectiveNoSpace,
+		},
+		{
+			name:              "pod in a namespace",
+			toComplete:        "test/w",
+			expected:          []string{"test/web-1:"},
+			expectedDirective: cobra.ShellCompDirectiveNoSpace,
+		},
+		{
+			name:              "no pod matches a relative local path",
+			toComplete:        "data",
+			expectedDirective: cobra.ShellCompDirectiveDefault,
+		},
+		{
+			name:              "remote directory",
+			toComplete:        "web-1:" + dir + "/",
+			expected:          []string{"web-1:" + dir + "/a", "web-1:" + dir + "/b c", "web-1:" + dir + "/sub/"},
+			expectedDirective: cobra.ShellCompDirectiveNoSpace,
+		},
+		{
+			name:              "remote file prefix",
+			toComplete:        "test/web-1:" + dir + "/s",
+			expected:          []string{"test/web-1:" + dir + "/sub/"},
+			expectedDirective: cobra.ShellCompDirectiveNoSpace,
+		},
+		{
+			name:              "denied by the access policy",
+			toComplete:        "web-1:" + dir + "/",
+			policy:            "clusters:\n- name: dev\n  commands: [exec]\n",
+			expectedDirective: cobra.ShellCompDirectiveNoFileComp,
+		},
+		{
+			name:              "pods denied by the access policy",
+			toComplete:        "web",
+			policy:            "clusters:\n- name: dev\n  commands: [exec]\n",
+			expectedDirective: cobra.ShellCompDirectiveDefault,
+		},
+		{
+			name:              "pods in a denied namespace",
+			toComplete:        "kube-system/",
+			policy:            "clusters:\n- name: dev\n  namespaces:\n    deny: [kube-system]\n",
+			expectedDirective: cobra.ShellCompDirectiveDefault,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			if len(test.policy) > 0 {
+				policy, err := configs.ParsePolicy([]byte(test.policy), "test")
+				if err != nil {
+					t.Fatal(err)
+				}
+				tf.PolicyVal = policy
+			}
+
+			codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "test"},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+			}
+			tf.Client = &fake.RESTClient{
+				GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					switch p, m := req.URL.Path, req.Method; {
+					case p == "/api/v1/namespaces/test/pods" && m == "GET":
+						body := cmdtesting.ObjBody(codec, &v1.PodList{Items: []v1.Pod{*pod}})
+						return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: body}, nil
+					case p == "/api/v1/namespaces/test/pods/web-1" && m == "GET":
+						body := cmdtesting.ObjBody(codec, pod)
+						return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: body}, nil
+					default:
+						t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+						return nil, fmt.Errorf("unexpected request")
+					}
+				}),
+			}
+			tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+
+			o := NewCopyOptions(genericclioptions.NewTestIOStreamsDiscard())
+			o.ClusterName = "dev"
+			o.Executor = &localExecutor{}
+			completions, directive := o.completeFileSpec(tf, test.toComplete)
+			sort.Strings(completions)
+			if len(completions) != 0 || len(test.expected) != 0 {
+				if !reflect.DeepEqual(completions, test.expected) {
+					t.Errorf("expected %q, got %q", test.expected, completions)
+				}
+			}
+			if directive != test.expectedDirective {
+				t.Errorf("expected directive %v, got %v", test.expectedDirective, directive)
+			}
+		})
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/lithammer/dedent"
 	"github.com/spf13/cobra"
@@ -34,13 +35,15 @@ import (
 	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
 	"github.com/Angus-F/client-go/kubernetes"
 	restclient "github.com/Angus-F/client-go/rest"
-	"github.com/Angus-F/client-go/tools/clientcmd"
 	clientcmdapi "github.com/Angus-F/client-go/tools/clientcmd/api"
+	"github.com/Angus-F/kubectl/pkg/audit"
 	"github.com/Angus-F/kubectl/pkg/cmd/exec"
 	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
 	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/term"
 	"github.com/Angus-F/kubectl/pkg/util/templates"
-    "github.com/Angus-F/kubectl/pkg/configs"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 var (
@@ -50,8 +53,7 @@ var (
 		# Requires that the 'tar' binary is present in your container
 		# image.  If 'tar' is not present, 'kubectl cp' will fail.
 		#
-		# For advanced use cases, such as symlinks, wildcard expansion or
-		# file mode preservation consider using 'kubectl exec'.
+		# For advanced use cases consider using 'kubectl exec'.
 
 		# Copy /tmp/foo local file to /tmp/bar in a remote pod in namespace <some-namespace>
 		tar cf - /tmp/foo | kubectl exec -i -n <some-namespace> <some-pod> -- tar xf - -C /tmp/bar
@@ -69,13 +71,43 @@ var (
 		kubectl cp /tmp/foo <some-namespace>/<some-pod>:/tmp/bar
 
 		# Copy /tmp/foo from a remote pod to /tmp/bar locally
-		kubectl cp <some-namespace>/<some-pod>:/tmp/foo /tmp/bar`))
+		kubectl cp <some-namespace>/<some-pod>:/tmp/foo /tmp/bar
+
+		# Copy the log files matching a pattern in a remote pod, expanded in the container, to the local ./out directory
+		kubectl cp '<some-namespace>/<some-pod>:/var/log/*.log' ./out/
+
+		# Copy several local files to the /etc/app directory in a remote pod
+		kubectl cp app.yaml secrets.yaml <some-pod>:/etc/app
+
+		# Make /etc/app in a remote pod a copy of the local ./config directory, deleting extra files,
+		# and keep copying changed files until interrupted
+		kubectl cp ./config <some-pod>:/etc/app --sync --delete --watch
+
+		# Copy /var/www from a remote pod, keeping its symlinks and file modes, and failing on any entry
+		# pointing outside the local ./www directory
+		kubectl cp <some-pod>:/var/www ./www --symlinks=preserve --preserve-permissions --strict
+
+		# Copy /tmp/foo local file to /tmp/bar in a distroless container, with tar in a helper container
+		# added to the pod as an ephemeral container, which changes the pod spec
+		kubectl cp /tmp/foo <some-pod>:/tmp/bar --transport=ephemeral
+
+		# Copy a large file from a remote pod, resuming up to 10 times if the connection drops and verifying its checksum
+		kubectl cp <some-pod>:/var/dump/heap.hprof heap.hprof --retries=10 --progress --verify
+
+		# Copy /var/data from a pod in cluster east to /var/data in a pod in cluster west, without staging it locally
+		kubectl cp east:<some-namespace>/<some-pod>:/var/data west:<some-namespace>/<other-pod>:/var/data`))
 
 	cpUsageStr = dedent.Dedent(`
-		expected 'cp <file-spec-src> <file-spec-dest> [-c container] [-C clusterName]'.
+		expected 'cp <file-spec-src>... <file-spec-dest> [-c container] [-C clusterName]'.
 		<file-spec> is:
-		[namespace/]pod-name:/file/path for a remote file
-		/file/path for a local file`)
+		[[cluster:]namespace/]pod-name:/file/path for a remote file
+		/file/path for a local file
+		The cluster prefix overrides --clusterName and requires the namespace,
+		e.g. east:default/web-0:/var/data. It must be a registered cluster,
+		otherwise it is read as the pod name. A pod named like a registered
+		cluster is copied from or to with its namespace, e.g. default/east:/var/data
+		Several sources, or patterns matching several files, are copied into the
+		existing destination directory. They must be local, or in the same pod.`)
 )
 
 // CopyOptions have the data required to perform the copy operation
@@ -83,20 +115,69 @@ type CopyOptions struct {
 	Container  string
 	Namespace  string
 	NoPreserve bool
+	Retries    int
+	Verify     bool
+	Progress   bool
+	// Transport is auto or the name of one of transports
+	Transport     string
+	HelperImage   string
+	HelperTimeout time.Duration
+	// Sync copies only the files that differ between two directories
+	Sync          bool
+	Delete        bool
+	Checksum      bool
+	Watch         bool
+	WatchInterval time.Duration
+	// Symlinks is how symlinks copied from a container are extracted, one of
+	// skip, preserve or follow
+	Symlinks            string
+	Strict              bool
+	PreservePermissions bool
 
 	ClusterName string
 
 	ClientConfig      *restclient.Config
 	Clientset         kubernetes.Interface
 	ExecParentCmdName string
+	// Executor runs the remote commands, defaults to exec.DefaultRemoteExecutor
+	Executor exec.RemoteExecutor
+	// Auditor, if set, logs every copy to the audit log
+	Auditor audit.Logger
+
+	// clusterNames are the registered clusters file specs may name
+	clusterNames []string
+	// clientForCluster resolves the clusters named in file specs
+	clientForCluster func(clusterName string) (*cmdutil.ClusterClient, error)
+	// clusters holds the clients of the clusters named in file specs
+	clusters map[string]*clusterClients
+	// checkAccess checks the access policy of --clusterName
+	checkAccess func(access configs.Access) error
 
 	genericclioptions.IOStreams
-	Configs map[string]string
+}
+
+// clusterClients are the clients used to exec into the pods of one cluster
+type clusterClients struct {
+	// name is the cluster the clients were resolved to
+	name         string
+	namespace    string
+	clientConfig *restclient.Config
+	clientset    kubernetes.Interface
+	// checkAccess checks the access policy of the cluster, nil if unrestricted
+	checkAccess func(access configs.Access) error
 }
 
 // NewCopyOptions creates the options for copy
 func NewCopyOptions(ioStreams genericclioptions.IOStreams) *CopyOptions {
 	return &CopyOptions{
+		Retries:       defaultRetries,
+		Progress:      term.IsTerminal(ioStreams.ErrOut),
+		Transport:     transportAuto,
+		HelperImage:   defaultHelperImage,
+		HelperTimeout: time.Minute,
+		WatchInterval: time.Second,
+		Symlinks:      symlinksSkip,
+
 		IOStreams: ioStreams,
 	}
 }
@@ -104,13 +185,17 @@ func NewCopyOptions(ioStreams genericclioptions.IOStreams) *CopyOptions {
 // NewCmdCp creates a new Copy command.
 func NewCmdCp(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
 	o := NewCopyOptions(ioStreams)
+	o.Auditor = audit.NewDefaultLogger()
 
 	cmd := &cobra.Command{
-		Use:                   "cp <file-spec-src> <file-spec-dest> (clusterName is required strictly)",
+		Use:                   "cp <file-spec-src>... <file-spec-dest>",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Copy files and directories to and from containers."),
 		Long:                  i18n.T("Copy files and directories to and from containers."),
 		Example:               cpExample,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return o.completeFileSpec(f, toComplete)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd))
 			cmdutil.CheckErr(o.Run(cmd, args))
@@ -119,23 +204,45 @@ func NewCmdCp(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.C
 	cmdutil.AddContainerVarFlags(cmd, &o.Container, o.Container)
 	cmdutil.AddClusterVarFlags(cmd, &o.ClusterName, o.ClusterName)
 	cmd.Flags().BoolVarP(&o.NoPreserve, "no-preserve", "", false, "The copied file/directory's ownership and permissions will not be preserved in the container")
+	cmd.Flags().IntVar(&o.Retries, "retries", o.Retries, "Number of times to retry copying from a container when the connection drops or the checksums don't match. A single file is resumed where it stopped, which requires 'sh' and 'tail' in the container, a directory is copied again from the start. A negative value retries forever.")
+	cmd.Flags().BoolVar(&o.Verify, "verify", o.Verify, "If true, compare the SHA-256 checksums of the files copied from a container, computed with 'sha256sum' in the container, with the local copies, and copy the mismatched files again.")
+	cmd.Flags().BoolVar(&o.Progress, "progress", o.Progress, "If true, print the bytes copied and the transfer rate to stderr. Defaults to true if stderr is a terminal.")
+	cmd.Flags().StringVar(&o.Transport, "transport", o.Transport, "How to copy files to and from the container, one of: auto|tar|cat|base64|ephemeral. auto uses tar, and falls back to cat or base64 for single files if tar is missing. ephemeral runs tar in a helper container added to the pod, for images without any of these tools. It is never used unless set, since it changes the pod spec by adding an ephemeral container, even to copy from the pod, which the access policy treats like copying to the pod.")
+	cmd.Flags().StringVar(&o.HelperImage, "helper-image", o.HelperImage, "The image of the helper container used by --transport=ephemeral. It must contain tar, sh and sleep.")
+	cmd.Flags().DurationVar(&o.HelperTimeout, "helper-timeout", o.HelperTimeout, "How long to wait for the helper container used by --transport=ephemeral to start.")
+	cmd.Flags().BoolVar(&o.Sync, "sync", o.Sync, "If true, make the destination directory a copy of the source directory, copying only the regular files whose size or modification time differ. Requires 'find' and 'stat' in the container.")
+	cmd.Flags().BoolVar(&o.Delete, "delete", o.Delete, "If true, delete the files in the destination that are missing from the source. Only valid with --sync.")
+	cmd.Flags().BoolVar(&o.Checksum, "checksum", o.Checksum, "If true, compare files by size and SHA-256 checksum instead of size and modification time. Only valid with --sync.")
+	cmd.Flags().BoolVarP(&o.Watch, "watch", "w", o.Watch, "If true, keep syncing local changes to the container until interrupted. Only valid with --sync from a local directory.")
+	cmd.Flags().DurationVar(&o.WatchInterval, "watch-interval", o.WatchInterval, "How often --watch checks the local directory for changes.")
+	cmd.Flags().StringVar(&o.Symlinks, "symlinks", o.Symlinks, "How to extract the symlinks copied from a container, one of: skip|preserve|follow. preserve creates the symlinks pointing within the destination, follow replaces the ones pointing to files within the destination with copies of the files. Symlinks pointing outside the destination are always skipped.")
+	cmd.Flags().BoolVar(&o.Strict, "strict", o.Strict, "If true, fail instead of skipping the files copied from a container that would be written outside the destination, and the links pointing outside it.")
+	cmd.Flags().BoolVar(&o.PreservePermissions, "preserve-permissions", o.PreservePermissions, "If true, keep the mode of the files copied from a container. Running as root, their ownership and setuid, setgid and sticky bits are kept too.")
+	cmdutil.CheckErr(cmd.RegisterFlagCompletionFunc("container", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return o.completeContainer(f, args, toComplete)
+	}))
 
 	return cmd
 }
 
 type fileSpec struct {
+	// ClusterName is empty unless the spec names a cluster, which then
+	// overrides --clusterName
+	ClusterName  string
 	PodNamespace string
 	PodName      string
 	File         string
 }
 
 var (
-	errFileSpecDoesntMatchFormat = errors.New("filespec must match the canonical format: [[namespace/]pod:]file/path")
+	errFileSpecDoesntMatchFormat = errors.New("filespec must match the canonical format: [[[cluster:]namespace/]pod:]file/path")
 	errFileCannotBeEmpty         = errors.New("filepath can not be empty")
+	errRemoteSpecRequired        = errors.New("one of src or dest must be a remote file specification")
 )
 
-
-func extractFileSpec(arg string) (fileSpec, error) {
+// extractFileSpec parses a file spec. Its first segment names a cluster only
+// if it is one of clusterNames, pod:dir/name:file stays a pod file otherwise.
+func extractFileSpec(arg string, clusterNames []string) (fileSpec, error) {
 	i := strings.Index(arg, ":")
 
 	if i == -1 {
@@ -146,6 +253,12 @@ func extractFileSpec(arg string) (fileSpec, error) {
 		return fileSpec{}, errFileSpecDoesntMatchFormat
 	}
 
+	if containsString(clusterNames, arg[:i]) {
+		if spec, ok := extractClusterFileSpec(arg[:i], arg[i+1:]); ok {
+			return spec, nil
+		}
+	}
+
 	pod, file := arg[:i], arg[i+1:]
 	pieces := strings.Split(pod, "/")
 	switch len(pieces) {
@@ -165,89 +278,199 @@ func extractFileSpec(arg string) (fileSpec, error) {
 	}
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// extractClusterFileSpec parses the rest of a cluster:namespace/pod:/file/path
+// spec. It returns false if rest is the file path of a namespace/pod:file/path
+// spec instead, which is the case unless rest starts with a namespace/pod
+// followed by a colon.
+func extractClusterFileSpec(cluster, rest string) (fileSpec, bool) {
+	i := strings.Index(rest, ":")
+	if i == -1 || strings.Contains(cluster, "/") {
+		return fileSpec{}, false
+	}
+	pieces := strings.Split(rest[:i], "/")
+	if len(pieces) != 2 {
+		return fileSpec{}, false
+	}
+	for _, piece := range pieces {
+		if len(validation.IsDNS1123Subdomain(piece)) > 0 {
+			return fileSpec{}, false
+		}
+	}
+	return fileSpec{
+		ClusterName:  cluster,
+		PodNamespace: pieces[0],
+		PodName:      pieces[1],
+		File:         rest[i+1:],
+	}, true
+}
+
 // Complete completes all the required options
 func (o *CopyOptions) Complete(f cmdutil.Factory, cmd *cobra.Command) error {
 	if cmd.Parent() != nil {
 		o.ExecParentCmdName = cmd.Parent().CommandPath()
 	}
-	o.Configs = make(map[string]string)
-	if len(configs.ClusterName) != len(configs.ConfigContent) {
-		return fmt.Errorf("the numbers of ClusterName and the ConfigContent is unmatched")
+	o.clientForCluster = f.ClientForCluster
+	var err error
+	if o.clusterNames, err = f.ClusterNames(); err != nil {
+		return err
 	}
-	if len(configs.ClusterName) == 0 || len(configs.ConfigContent) == 0 {
-		return fmt.Errorf("fail to find configs to set")
+	if err := o.completeDefaultCluster(); err != nil && len(o.ClusterName) > 0 {
+		// without --clusterName both file specs may name their clusters,
+		// completeClusters reports the error otherwise
+		return err
 	}
+	return nil
+}
 
-	for i := 0; i < len(configs.ClusterName); i++ {
-		o.Configs[configs.ClusterName[i]] = configs.ConfigContent[i]
+// completeDefaultCluster loads the clients of --clusterName, which are used
+// for the file specs that don't name a cluster
+func (o *CopyOptions) completeDefaultCluster() error {
+	clients, err := o.loadCluster(o.ClusterName)
+	if err != nil {
+		return err
 	}
+	// without --clusterName the default cluster is used
+	o.ClusterName = clients.name
+	o.Namespace = clients.namespace
+	o.ClientConfig = clients.clientConfig
+	o.Clientset = clients.clientset
+	o.checkAccess = clients.checkAccess
+	return nil
+}
 
-	if len(o.ClusterName) > 0 {
-		flag := false
-		for filename := range o.Configs {
-			if filename == o.ClusterName {
-				flag = true
-				break
+// completeClusters loads the clients of the clusters the remote specs point to
+func (o *CopyOptions) completeClusters(specs ...fileSpec) error {
+	for _, spec := range specs {
+		if len(spec.PodName) == 0 {
+			continue
+		}
+		if len(spec.ClusterName) == 0 {
+			if o.Clientset == nil {
+				if err := o.completeDefaultCluster(); err != nil {
+					return err
+				}
 			}
+			continue
 		}
-		if !flag {
-			return fmt.Errorf("the clusterName can not be found")
+		if _, found := o.clusters[spec.ClusterName]; found {
+			continue
 		}
-	} else {
-		return fmt.Errorf("Please set the clusterName")
+		clients, err := o.loadCluster(spec.ClusterName)
+		if err != nil {
+			return err
+		}
+		if o.clusters == nil {
+			o.clusters = map[string]*clusterClients{}
+		}
+		o.clusters[spec.ClusterName] = clients
 	}
+	return nil
+}
 
-	NewDirectClientConfig, err := clientcmd.NewClientConfigFromBytes([]byte(o.Configs[o.ClusterName]))
-	if err != nil {
-		return err
+func (o *CopyOptions) loadCluster(clusterName string) (*clusterClients, error) {
+	if o.clientForCluster == nil {
+		return nil, fmt.Errorf("no clients for cluster %q", clusterName)
 	}
-	o.Namespace, _, err = NewDirectClientConfig.Namespace()
+	client, err := o.clientForCluster(clusterName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-
-	o.ClientConfig, err = clientcmd.RESTConfigFromKubeConfigWithDefaultSet([]byte(o.Configs[o.ClusterName]))
+	clients := &clusterClients{name: client.Name, namespace: client.Namespace, checkAccess: client.CheckAccess}
+	clients.clientset, err = client.KubernetesClientSet()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-
-	o.Clientset, err = kubernetes.NewForConfig(o.ClientConfig)
+	clients.clientConfig, err = client.ToRESTConfig()
 	if err != nil {
-		return err
-	}
-
-	/**
-	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
-	if err != nil {
-		return err
+		return nil, err
 	}
+	return clients, nil
+}
 
-	o.Clientset, err = f.KubernetesClientSet()
-	if err != nil {
-		return err
+// clientsFor returns the clients of the cluster named in a file spec, or the
+// clients of --clusterName if clusterName is empty
+func (o *CopyOptions) clientsFor(clusterName string) *clusterClients {
+	if clients, found := o.clusters[clusterName]; found && len(clusterName) > 0 {
+		return clients
 	}
+	return &clusterClients{namespace: o.Namespace, clientConfig: o.ClientConfig, clientset: o.Clientset, checkAccess: o.checkAccess}
+}
 
-	o.ClientConfig, err = f.ToRESTConfig()
-	if err != nil {
-		return err
+// authorize checks the access policy of the clusters of the remote specs
+// before anything is sent to them, the last spec is the destination
+func (o *CopyOptions) authorize(specs []fileSpec) error {
+	for i, spec := range specs {
+		if len(spec.PodName) == 0 {
+			continue
+		}
+		clients := o.clientsFor(spec.ClusterName)
+		if clients.checkAccess == nil {
+			continue
+		}
+		namespace := spec.PodNamespace
+		if len(namespace) == 0 {
+			namespace = clients.namespace
+		}
+		access := configs.Access{Command: configs.CommandCp, Namespace: namespace, CopyToPod: i == len(specs)-1}
+		if err := clients.checkAccess(access); err != nil {
+			return err
+		}
+		// the ephemeral transport adds a helper container to the pod spec,
+		// even to copy from the pod, so it is checked like copying into it
+		if o.Transport == transportEphemeral && !access.CopyToPod {
+			access.CopyToPod = true
+			if err := clients.checkAccess(access); err != nil {
+				return fmt.Errorf("--transport=%s adds a container to pod %s: %v", transportEphemeral, spec.PodName, err)
+			}
+		}
 	}
-	*/
-
 	return nil
 }
 
 // Validate makes sure provided values for CopyOptions are valid
 func (o *CopyOptions) Validate(cmd *cobra.Command, args []string) error {
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return cmdutil.UsageErrorf(cmd, cpUsageStr)
 	}
+	if o.Sync && len(args) != 2 {
+		return fmt.Errorf("--sync requires exactly one source")
+	}
+	if _, found := transports[o.Transport]; !found && o.Transport != transportAuto {
+		return fmt.Errorf("--transport must be one of auto|tar|cat|base64|ephemeral, got %q", o.Transport)
+	}
+	if !o.Sync && (o.Delete || o.Checksum || o.Watch) {
+		return fmt.Errorf("--delete, --checksum and --watch can only be used with --sync")
+	}
+	if o.Sync && o.Transport != transportAuto && o.Transport != transportTar {
+		return fmt.Errorf("--sync requires tar in the container, --transport=%s is not supported", o.Transport)
+	}
+	if o.Watch && o.WatchInterval <= 0 {
+		return fmt.Errorf("--watch-interval must be greater than 0")
+	}
+	if o.Symlinks != symlinksSkip && o.Symlinks != symlinksPreserve && o.Symlinks != symlinksFollow {
+		return fmt.Errorf("--symlinks must be one of skip|preserve|follow, got %q", o.Symlinks)
+	}
 	return nil
 }
 
 // Run performs the execution
 func (o *CopyOptions) Run(cmd *cobra.Command, args []string) error {
+	if o.Auditor == nil {
+		return o.run(cmd, args)
+	}
+	return o.runAudited(cmd, args)
+}
+
+func (o *CopyOptions) run(cmd *cobra.Command, args []string) error {
 	err := o.Validate(cmd, args)
 	if err != nil {
 		return err
@@ -255,24 +478,53 @@ func (o *CopyOptions) Run(cmd *cobra.Command, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("source and destination are required")
 	}
-	srcSpec, err := extractFileSpec(args[0])
-	if err != nil {
+	specs := make([]fileSpec, len(args))
+	for i, arg := range args {
+		if specs[i], err = extractFileSpec(arg, o.clusterNames); err != nil {
+			return err
+		}
+	}
+	if err := o.completeClusters(specs...); err != nil {
 		return err
 	}
-	destSpec, err := extractFileSpec(args[1])
-	if err != nil {
+	if err := o.authorize(specs); err != nil {
 		return err
 	}
-	if len(srcSpec.PodName) != 0 && len(destSpec.PodName) != 0 {
-		return fmt.Errorf("one of src or dest must be a local file specification")
+	sources, destSpec := specs[:len(specs)-1], specs[len(specs)-1]
+
+	srcSpec := sources[0]
+	if len(sources) > 1 || (!o.Sync && hasGlobMeta(srcSpec.File) && (len(srcSpec.PodName) != 0 || len(destSpec.PodName) != 0)) {
+		src, files, err := o.expandSources(sources)
+		if err != nil {
+			return err
+		}
+		if len(files) > 1 {
+			return o.copyMany(src, files, destSpec)
+		}
+		srcSpec.File = files[0]
 	}
-	if len(srcSpec.PodName) != 0 {
-		return o.copyFromPod(srcSpec, destSpec)
+	if o.Sync {
+		if len(srcSpec.PodName) != 0 && len(destSpec.PodName) != 0 {
+			return fmt.Errorf("--sync can only be used between a pod and the local filesystem")
+		}
+		if len(srcSpec.PodName) == 0 && len(destSpec.PodName) == 0 {
+			return errRemoteSpecRequired
+		}
+		if o.Watch && len(destSpec.PodName) == 0 {
+			return fmt.Errorf("--watch can only sync a local directory to a pod")
+		}
+		return o.runSync(srcSpec, destSpec)
 	}
-	if len(destSpec.PodName) != 0 {
-		return o.copyToPod(srcSpec, destSpec, &exec.ExecOptions{})
+	if len(srcSpec.PodName) != 0 && len(destSpec.PodName) != 0 {
+		if o.Transport != transportAuto && o.Transport != transportTar {
+			return fmt.Errorf("copying between pods requires tar in both containers, --transport=%s is not supported", o.Transport)
+		}
+		return o.copyBetweenPods(srcSpec, destSpec)
+	}
+	if len(srcSpec.PodName) != 0 || len(destSpec.PodName) != 0 {
+		return o.copyWithTransport(srcSpec, destSpec)
 	}
-	return fmt.Errorf("one of src or dest must be a remote file specification")
+	return errRemoteSpecRequired
 }
 
 // checkDestinationIsDir receives a destination fileSpec and
@@ -292,10 +544,10 @@ func (o *CopyOptions) checkDestinationIsDir(dest fileSpec) error {
 		},
 
 		Command:  []string{"test", "-d", dest.File},
-		Executor: &exec.DefaultRemoteExecutor{},
+		Executor: o.executor(),
 	}
 
-	return o.execute(options)
+	return o.execute(dest.ClusterName, options)
 }
 
 func (o *CopyOptions) copyToPod(src, dest fileSpec, options *exec.ExecOptions) error {
@@ -318,22 +570,17 @@ func (o *CopyOptions) copyToPod(src, dest fileSpec, options *exec.ExecOptions) e
 		dest.File = dest.File + "/" + path.Base(src.File)
 	}
 
+	progress := o.newProgressBar(0)
+	defer progress.finish()
 	go func() {
 		defer writer.Close()
-		cmdutil.CheckErr(makeTar(src.File, dest.File, writer))
+		err := makeTar(src.File, dest.File, io.MultiWriter(writer, progress))
+		// the pipe is closed if the container stopped reading, e.g. because tar is missing
+		if err != io.ErrClosedPipe {
+			cmdutil.CheckErr(err)
+		}
 	}()
-	var cmdArr []string
-
-	// TODO: Improve error messages by first testing if 'tar' is present in the container?
-	if o.NoPreserve {
-		cmdArr = []string{"tar", "--no-same-permissions", "--no-same-owner", "-xmf", "-"}
-	} else {
-		cmdArr = []string{"tar", "-xmf", "-"}
-	}
-	destDir := path.Dir(dest.File)
-	if len(destDir) > 0 {
-		cmdArr = append(cmdArr, "-C", destDir)
-	}
+	defer reader.Close()
 
 	options.StreamOptions = exec.StreamOptions{
 		IOStreams: genericclioptions.IOStreams{
@@ -347,42 +594,48 @@ func (o *CopyOptions) copyToPod(src, dest fileSpec, options *exec.ExecOptions) e
 		PodName:   dest.PodName,
 	}
 
-	options.Command = cmdArr
-	options.Executor = &exec.DefaultRemoteExecutor{}
-	return o.execute(options)
+	options.Command = o.untarCommand(dest)
+	options.Executor = o.executor()
+	return o.execute(dest.ClusterName, options)
+}
+
+// untarCommand returns the command extracting a tar stream from stdin in the
+// parent directory of dest
+func (o *CopyOptions) untarCommand(dest fileSpec) []string {
+	return o.untarCommandIn(path.Dir(dest.File))
+}
+
+// untarCommandIn returns the command extracting a tar stream from stdin in destDir
+func (o *CopyOptions) untarCommandIn(destDir string) []string {
+	var cmdArr []string
+
+	// synced files are compared by modification time, so it is kept
+	extract := "-xmf"
+	if o.Sync {
+		extract = "-xf"
+	}
+
+	// TODO: Improve error messages by first testing if 'tar' is present in the container?
+	if o.NoPreserve {
+		cmdArr = []string{"tar", "--no-same-permissions", "--no-same-owner", extract, "-"}
+	} else {
+		cmdArr = []string{"tar", extract, "-"}
+	}
+	if len(destDir) > 0 {
+		cmdArr = append(cmdArr, "-C", destDir)
+	}
+	return cmdArr
 }
 
 func (o *CopyOptions) copyFromPod(src, dest fileSpec) error {
 	if len(src.File) == 0 || len(dest.File) == 0 {
 		return errFileCannotBeEmpty
 	}
-	reader, outStream := io.Pipe()
-	options := &exec.ExecOptions{
-		StreamOptions: exec.StreamOptions{
-			IOStreams: genericclioptions.IOStreams{
-				In:     nil,
-				Out:    outStream,
-				ErrOut: o.Out,
-			},
-
-			Namespace: src.PodNamespace,
-			PodName:   src.PodName,
-		},
-
-		// TODO: Improve error messages by first testing if 'tar' is present in the container?
-		Command:  []string{"tar", "cf", "-", src.File},
-		Executor: &exec.DefaultRemoteExecutor{},
+	retries := &retryBudget{max: o.Retries}
+	if size, ok := o.remoteFileSize(src); ok {
+		return o.copyFileFromPod(src, dest, size, retries)
 	}
-	go func() {
-		defer outStream.Close()
-		cmdutil.CheckErr(o.execute(options))
-	}()
-	prefix := getPrefix(src.File)
-	prefix = path.Clean(prefix)
-	// remove extraneous path shortcuts - these could occur if a path contained extra "../"
-	// and attempted to navigate beyond "/" in a remote filesystem
-	prefix = stripPathShortcuts(prefix)
-	return o.untarAll(src, reader, dest.File, prefix)
+	return o.copyTarFromPod(src, dest, retries)
 }
 
 // stripPathShortcuts removes any leading or trailing "../" from a given path
@@ -488,7 +741,10 @@ func recursiveTar(srcBase, srcFile, destBase, destFile string, tw *tar.Writer) e
 }
 
 func (o *CopyOptions) untarAll(src fileSpec, reader io.Reader, destDir, prefix string) error {
-	symlinkWarningPrinted := false
+	x, err := o.newExtractor(src, destDir, prefix)
+	if err != nil {
+		return err
+	}
 	// TODO: use compression here?
 	tarReader := tar.NewReader(reader)
 	for {
@@ -507,45 +763,12 @@ func (o *CopyOptions) untarAll(src fileSpec, reader io.Reader, destDir, prefix s
 		if !strings.HasPrefix(header.Name, prefix) {
 			return fmt.Errorf("tar contents corrupted")
 		}
-		// basic file information
-		mode := header.FileInfo().Mode()
 		destFileName := filepath.Join(destDir, header.Name[len(prefix):])
-		if !isDestRelative(destDir, destFileName) {
-			fmt.Fprintf(o.IOStreams.ErrOut, "warning: file %q is outside target destination, skipping\n", destFileName)
-			continue
-		}
-		baseName := filepath.Dir(destFileName)
-		if err := os.MkdirAll(baseName, 0755); err != nil {
-			return err
-		}
-		if header.FileInfo().IsDir() {
-			if err := os.MkdirAll(destFileName, 0755); err != nil {
-				return err
-			}
-			continue
-		}
-		if mode&os.ModeSymlink != 0 {
-			if !symlinkWarningPrinted && len(o.ExecParentCmdName) > 0 {
-				fmt.Fprintf(o.IOStreams.ErrOut, "warning: skipping symlink: %q -> %q (consider using \"%s exec -n %q %q -- tar cf - %q | tar xf -\")\n", destFileName, header.Linkname, o.ExecParentCmdName, src.PodNamespace, src.PodName, src.File)
-				symlinkWarningPrinted = true
-				continue
-			}
-			fmt.Fprintf(o.IOStreams.ErrOut, "warning: skipping symlink: %q -> %q\n", destFileName, header.Linkname)
-			continue
-		}
-		outFile, err := os.Create(destFileName)
-		if err != nil {
-			return err
-		}
-		defer outFile.Close()
-		if _, err := io.Copy(outFile, tarReader); err != nil {
-			return err
-		}
-		if err := outFile.Close(); err != nil {
+		if err := x.extract(header, destFileName, tarReader); err != nil {
 			return err
 		}
 	}
-	return nil
+	return x.finish()
 }
 
 // isDestRelative returns true if dest is pointing outside the base directory,
@@ -563,17 +786,18 @@ func getPrefix(file string) string {
 	return strings.TrimLeft(file, "/")
 }
 
-func (o *CopyOptions) execute(options *exec.ExecOptions) error {
+func (o *CopyOptions) execute(clusterName string, options *exec.ExecOptions) error {
+	clients := o.clientsFor(clusterName)
 	if len(options.Namespace) == 0 {
-		options.Namespace = o.Namespace
+		options.Namespace = clients.namespace
 	}
 
 	if len(o.Container) > 0 {
 		options.ContainerName = o.Container
 	}
 
-	options.Config = o.ClientConfig
-	options.PodClient = o.Clientset.CoreV1()
+	options.Config = clients.clientConfig
+	options.PodClient = clients.clientset.CoreV1()
 
 	if err := options.Validate(); err != nil {
 		return err
@@ -55,11 +55,60 @@ const (
 func TestExtractFileSpec(t *testing.T) {
 	tests := []struct {
 		spec              string
+		expectedCluster   string
 		expectedPod       string
 		expectedNamespace string
 		expectedFile      string
 		expectErr         bool
 	}{
+		{
+			spec:              "east:namespace/pod:/some/file",
+			expectedCluster:   "east",
+			expectedPod:       "pod",
+			expectedNamespace: "namespace",
+			expectedFile:      "/some/file",
+		},
+		{
+			spec:              "east:namespace/pod:/some/filenamewith:in",
+			expectedCluster:   "east",
+			expectedPod:       "pod",
+			expectedNamespace: "namespace",
+			expectedFile:      "/some/filenamewith:in",
+		},
+		{
+			spec:              "namespace/pod:some/file:in",
+			expectedPod:       "pod",
+			expectedNamespace: "namespace",
+			expectedFile:      "some/file:in",
+		},
+		{
+			spec:         "pod:Some/File:in",
+			expectedPod:  "pod",
+			expectedFile: "Some/File:in",
+		},
+		{
+			spec:         "mypod:logs/app-10:00.log",
+			expectedPod:  "mypod",
+			expectedFile: "logs/app-10:00.log",
+		},
+		{
+			spec:              "east:logs/app-10:00.log",
+			expectedCluster:   "east",
+			expectedPod:       "app-10",
+			expectedNamespace: "logs",
+			expectedFile:      "00.log",
+		},
+		{
+			spec:         "east:/var/data",
+			expectedPod:  "east",
+			expectedFile: "/var/data",
+		},
+		{
+			spec:              "default/east:logs/app-10:00.log",
+			expectedPod:       "east",
+			expectedNamespace: "default",
+			expectedFile:      "logs/app-10:00.log",
+		},
 		{
 			spec:              "namespace/pod:/some/file",
 			expectedPod:       "pod",
@@ -90,7 +139,7 @@ func TestExtractFileSpec(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		spec, err := extractFileSpec(test.spec)
+		spec, err := extractFileSpec(test.spec, []string{"east"})
 		if test.expectErr && err == nil {
 			t.Errorf("unexpected non-error")
 			continue
@@ -99,6 +148,9 @@ func TestExtractFileSpec(t *testing.T) {
 			t.Errorf("unexpected error: %v", err)
 			continue
 		}
+		if spec.ClusterName != test.expectedCluster {
+			t.Errorf("expected: %s, saw: %s", test.expectedCluster, spec.ClusterName)
+		}
 		if spec.PodName != test.expectedPod {
 			t.Errorf("expected: %s, saw: %s", test.expectedPod, spec.PodName)
 		}
@@ -702,8 +754,13 @@ func TestValidate(t *testing.T) {
 			expectedErr: false,
 		},
 		{
-			name:        "Validate Fail",
+			name:        "Validate Several Sources",
 			args:        []string{"1", "2", "3"},
+			expectedErr: false,
+		},
+		{
+			name:        "Validate Fail",
+			args:        []string{"1"},
 			expectedErr: true,
 		},
 	}
@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	coreclient "github.com/Angus-F/client-go/kubernetes/typed/core/v1"
+	"github.com/Angus-F/kubectl/pkg/cmd/exec"
+	"github.com/Angus-F/kubectl/pkg/cmd/util/podcmd"
+)
+
+const (
+	defaultHelperImage = "busybox:1.33"
+
+	// helperNamePrefix names the ephemeral containers added by the ephemeral
+	// transport, which reuses a running one for the same target and image
+	helperNamePrefix = "kesctl-cp-"
+	// helperLifetime is how long a helper container runs. Ephemeral
+	// containers can't be removed, but they stop once their command exits.
+	helperLifetime = "3600"
+	// helperRoot is the root filesystem of the target container as seen from
+	// a helper container sharing its process namespace
+	helperRoot = "/proc/1/root"
+)
+
+var helperNameSuffixFunc = utilrand.String
+
+// ephemeralTransport copies with tar in an ephemeral helper container that
+// shares the process namespace of the target container, and reaches its
+// files through /proc/1/root
+type ephemeralTransport struct{}
+
+func (ephemeralTransport) name() string { return transportEphemeral }
+
+func (ephemeralTransport) probe(o *CopyOptions, spec fileSpec, toPod bool) error {
+	return nil
+}
+
+func (ephemeralTransport) copyToPod(o *CopyOptions, src, dest fileSpec) error {
+	helper, err := o.helperContainer(dest)
+	if err != nil {
+		return err
+	}
+	return helper.copyToPod(src, helperFileSpec(dest), &exec.ExecOptions{})
+}
+
+func (ephemeralTransport) copyFromPod(o *CopyOptions, src, dest fileSpec) error {
+	helper, err := o.helperContainer(src)
+	if err != nil {
+		return err
+	}
+	return helper.copyFromPod(helperFileSpec(src), dest)
+}
+
+// helperFileSpec maps spec to the root filesystem of the target container.
+// Relative paths are taken relative to its root.
+func helperFileSpec(spec fileSpec) fileSpec {
+	file := path.Join(helperRoot, spec.File)
+	if strings.HasSuffix(spec.File, "/") {
+		file += "/"
+	}
+	spec.File = file
+	return spec
+}
+
+// helperContainer returns copy options that exec into a running helper
+// container targeting the container of spec, adding one if there is none
+func (o *CopyOptions) helperContainer(spec fileSpec) (*CopyOptions, error) {
+	clients := o.clientsFor(spec.ClusterName)
+	namespace := spec.PodNamespace
+	if len(namespace) == 0 {
+		namespace = clients.namespace
+	}
+	pods := clients.clientset.CoreV1().Pods(namespace)
+	ctx := context.TODO()
+
+	pod, err := pods.Get(ctx, spec.PodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if pod.Spec.ShareProcessNamespace != nil && *pod.Spec.ShareProcessNamespace {
+		return nil, fmt.Errorf("the %s transport doesn't support pods sharing their process namespace", transportEphemeral)
+	}
+	target, err := podcmd.FindOrDefaultContainerByName(pod, o.Container, true, o.ErrOut)
+	if err != nil {
+		return nil, err
+	}
+
+	name := runningHelper(pod, target.Name, o.HelperImage)
+	if len(name) == 0 {
+		helper := &corev1.EphemeralContainer{
+			EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+				Name:                     helperNamePrefix + helperNameSuffixFunc(5),
+				Image:                    o.HelperImage,
+				Command:                  []string{"sleep", helperLifetime},
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			},
+			TargetContainerName: target.Name,
+		}
+		if err := addEphemeralContainer(ctx, pods, pod, helper); err != nil {
+			return nil, err
+		}
+		name = helper.Name
+		fmt.Fprintf(o.ErrOut, "Copying with helper container %s (%s) in pod %s\n", name, o.HelperImage, pod.Name)
+
+		err := wait.PollImmediate(time.Second, o.HelperTimeout, func() (bool, error) {
+			pod, err := pods.Get(ctx, spec.PodName, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			for _, status := range pod.Status.EphemeralContainerStatuses {
+				if status.Name != name {
+					continue
+				}
+				if status.State.Terminated != nil {
+					return false, fmt.Errorf("helper container %s terminated: %s", name, status.State.Terminated.Reason)
+				}
+				return status.State.Running != nil, nil
+			}
+			return false, nil
+		})
+		if err == wait.ErrWaitTimeout {
+			return nil, fmt.Errorf("timed out after %v waiting for helper container %s to start", o.HelperTimeout, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	helper := *o
+	helper.Container = name
+	return &helper, nil
+}
+
+// runningHelper returns the name of a running helper container targeting
+// the container target, or an empty string if there is none
+func runningHelper(pod *corev1.Pod, target, image string) string {
+	running := map[string]bool{}
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		running[status.Name] = status.State.Running != nil
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		if strings.HasPrefix(container.Name, helperNamePrefix) && container.TargetContainerName == target &&
+			container.Image == image && running[container.Name] {
+			return container.Name
+		}
+	}
+	return ""
+}
+
+// addEphemeralContainer adds container to pod the way kubectl debug does
+func addEphemeralContainer(ctx context.Context, pods coreclient.PodInterface, pod *corev1.Pod, container *corev1.EphemeralContainer) error {
+	podJS, err := json.Marshal(pod)
+	if err != nil {
+		return fmt.Errorf("error creating JSON for pod: %v", err)
+	}
+	helperPod := pod.DeepCopy()
+	helperPod.Spec.EphemeralContainers = append(helperPod.Spec.EphemeralContainers, *container)
+	helperJS, err := json.Marshal(helperPod)
+	if err != nil {
+		return fmt.Errorf("error creating JSON for helper container: %v", err)
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(podJS, helperJS, pod)
+	if err != nil {
+		return fmt.Errorf("error creating patch to add helper container: %v", err)
+	}
+	klog.V(2).Infof("generated strategic merge patch for helper container: %s", patch)
+
+	_, err = pods.Patch(ctx, pod.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "ephemeralcontainers")
+	if err != nil {
+		// The apiserver returns a 404 without details when the EphemeralContainers feature is disabled
+		if serr, ok := err.(*errors.StatusError); ok && serr.Status().Reason == metav1.StatusReasonNotFound && (serr.ErrStatus.Details == nil || serr.ErrStatus.Details.Name == "") {
+			return fmt.Errorf("ephemeral containers are disabled for this cluster (error from server: %q)", err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// symlinksSkip skips every symlink copied from a container
+	symlinksSkip = "skip"
+	// symlinksPreserve creates the symlinks pointing within the destination
+	symlinksPreserve = "preserve"
+	// symlinksFollow replaces the symlinks pointing to files within the
+	// destination with copies of the files
+	symlinksFollow = "follow"
+)
+
+// extractor writes the entries of a tar stream copied from a container below
+// destDir. Entries are never written outside destDir, including through the
+// symlinks found in it, and symlinks and hardlinks pointing outside destDir
+// are skipped, or fail the copy with --strict.
+type extractor struct {
+	o      *CopyOptions
+	src    fileSpec
+	prefix string
+
+	destDir string
+	// root is destDir with its symlinks evaluated
+	root string
+	// asRoot is true if the owner of the extracted files can be changed
+	asRoot bool
+
+	symlinkWarningPrinted bool
+	// links are the symlinks replaced by their targets with --symlinks=follow
+	links []string
+	// dirs are the directories whose mode is set once they are written
+	dirs []extractedDir
+}
+
+type extractedDir struct {
+	header *tar.Header
+	name   string
+}
+
+func (o *CopyOptions) newExtractor(src fileSpec, destDir, prefix string) (*extractor, error) {
+	root, err := resolvePath(destDir)
+	if err != nil {
+		return nil, err
+	}
+	return &extractor{
+		o:       o,
+		src:     src,
+		prefix:  prefix,
+		destDir: destDir,
+		root:    root,
+		asRoot:  os.Geteuid() == 0,
+	}, nil
+}
+
+// outside reports an entry pointing outside the destination. It is skipped
+// with a warning, unless --strict is set.
+func (x *extractor) outside(format string, a ...interface{}) error {
+	message := fmt.Sprintf(format, a...)
+	if x.o.Strict {
+		return errors.New(message)
+	}
+	fmt.Fprintf(x.o.IOStreams.ErrOut, "warning: %s, skipping\n", message)
+	return nil
+}
+
+// within returns true if name, once its symlinks are evaluated, is within the destination
+func (x *extractor) within(name string) bool {
+	if !isDestRelative(x.destDir, name) {
+		return false
+	}
+	resolved, err := resolvePath(name)
+	return err == nil && isDestRelative(x.root, resolved)
+}
+
+// extract writes the entry of header to destFileName, reading its content from content
+func (x *extractor) extract(header *tar.Header, destFileName string, content io.Reader) error {
+	if !isDestRelative(x.destDir, destFileName) {
+		return x.outside("file %q is outside target destination", destFileName)
+	}
+	// the destination itself may be a symlink named by the user
+	if destFileName != x.destDir && !x.within(filepath.Dir(destFileName)) {
+		return x.outside("file %q resolves outside target destination through a symlink", destFileName)
+	}
+	if err := os.MkdirAll(filepath.Dir(destFileName), 0755); err != nil {
+		return err
+	}
+
+	mode := header.FileInfo().Mode()
+	switch {
+	case header.FileInfo().IsDir():
+		if destFileName != x.destDir && !x.within(destFileName) {
+			return x.outside("directory %q resolves outside target destination through a symlink", destFileName)
+		}
+		if err := os.MkdirAll(destFileName, 0755); err != nil {
+			return err
+		}
+		if x.o.PreservePermissions {
+			x.dirs = append(x.dirs, extractedDir{header: header, name: destFileName})
+		}
+		return nil
+	case header.Typeflag == tar.TypeLink:
+		return x.extractHardlink(header, destFileName)
+	case mode&os.ModeSymlink != 0:
+		return x.extractSymlink(header, destFileName)
+	}
+	return x.extractFile(header, destFileName, content)
+}
+
+func (x *extractor) extractFile(header *tar.Header, destFileName string, content io.Reader) error {
+	if err := x.removeSymlink(destFileName); err != nil {
+		return err
+	}
+	outFile, err := os.Create(destFileName)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+	if _, err := io.Copy(outFile, content); err != nil {
+		return err
+	}
+	if err := outFile.Close(); err != nil {
+		return err
+	}
+	if err := x.preserve(header, destFileName); err != nil {
+		return err
+	}
+	if x.o.Sync {
+		// synced files are compared by modification time
+		if err := os.Chtimes(destFileName, header.ModTime, header.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) extractSymlink(header *tar.Header, destFileName string) error {
+	if len(x.o.Symlinks) == 0 || x.o.Symlinks == symlinksSkip {
+		if !x.symlinkWarningPrinted && len(x.o.ExecParentCmdName) > 0 {
+			fmt.Fprintf(x.o.IOStreams.ErrOut, "warning: skipping symlink: %q -> %q (consider using \"%s exec -n %q %q -- tar cf - %q | tar xf -\")\n", destFileName, header.Linkname, x.o.ExecParentCmdName, x.src.PodNamespace, x.src.PodName, x.src.File)
+			x.symlinkWarningPrinted = true
+			return nil
+		}
+		fmt.Fprintf(x.o.IOStreams.ErrOut, "warning: skipping symlink: %q -> %q\n", destFileName, header.Linkname)
+		return nil
+	}
+
+	// absolute targets would be resolved against the local filesystem
+	if filepath.IsAbs(header.Linkname) || !x.within(filepath.Join(filepath.Dir(destFileName), header.Linkname)) {
+		return x.outside("symlink %q -> %q points outside target destination", destFileName, header.Linkname)
+	}
+	if err := x.removeSymlink(destFileName); err != nil {
+		return err
+	}
+	if err := os.Symlink(header.Linkname, destFileName); err != nil {
+		return err
+	}
+	if x.o.Symlinks == symlinksFollow {
+		x.links = append(x.links, destFileName)
+	}
+	if x.asRoot && x.o.PreservePermissions {
+		return os.Lchown(destFileName, header.Uid, header.Gid)
+	}
+	return nil
+}
+
+func (x *extractor) extractHardlink(header *tar.Header, destFileName string) error {
+	if !strings.HasPrefix(header.Linkname, x.prefix) {
+		return x.outside("hardlink %q -> %q points outside target destination", destFileName, header.Linkname)
+	}
+	target := filepath.Join(x.destDir, header.Linkname[len(x.prefix):])
+	if !x.within(target) {
+		return x.outside("hardlink %q -> %q points outside target destination", destFileName, header.Linkname)
+	}
+	if _, err := os.Lstat(target); err != nil {
+		// the target was skipped
+		fmt.Fprintf(x.o.IOStreams.ErrOut, "warning: skipping hardlink %q to missing file %q\n", destFileName, target)
+		return nil
+	}
+	if err := x.removeSymlink(destFileName); err != nil {
+		return err
+	}
+	if err := os.Remove(destFileName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(target, destFileName)
+}
+
+// removeSymlink removes the symlink at name, if any, so that it isn't followed
+// when name is written
+func (x *extractor) removeSymlink(name string) error {
+	if name == x.destDir {
+		return nil
+	}
+	info, err := os.Lstat(name)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(name)
+}
+
+// preserve sets the mode of name from its tar header with
+// --preserve-permissions. Running as root, the owner and the setuid, setgid
+// and sticky bits are set too.
+func (x *extractor) preserve(header *tar.Header, name string) error {
+	if !x.o.PreservePermissions {
+		return nil
+	}
+	mode := header.FileInfo().Mode()
+	if !x.asRoot {
+		return os.Chmod(name, mode.Perm())
+	}
+	// changing the owner clears the setuid and setgid bits, so it comes first
+	if err := os.Lchown(name, header.Uid, header.Gid); err != nil {
+		return err
+	}
+	return os.Chmod(name, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+}
+
+// finish replaces the symlinks with their targets with --symlinks=follow,
+// then sets the mode of the directories, deepest first so that read-only
+// directories can still be written.
+func (x *extractor) finish() error {
+	for _, link := range x.links {
+		if err := x.follow(link); err != nil {
+			return err
+		}
+	}
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		if err := x.preserve(x.dirs[i].header, x.dirs[i].name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// follow replaces link with a copy of the file it points to. Links to
+// directories are kept, their contents are already within the destination.
+func (x *extractor) follow(link string) error {
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		// replaced by a later entry
+		return nil
+	}
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Fprintf(x.o.IOStreams.ErrOut, "warning: skipping dangling symlink %q\n", link)
+		return os.Remove(link)
+	}
+	if !isDestRelative(x.root, target) {
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+		return x.outside("symlink %q resolves outside target destination", link)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	in, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := ioutil.TempFile(filepath.Dir(link), ".cp-follow-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), link)
+}
+
+// resolvePath returns the absolute path of name with the symlinks of its
+// existing part evaluated. The missing part is joined as is. A dangling
+// symlink is an error, as writing through it could escape the destination.
+func resolvePath(name string) (string, error) {
+	name, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(name)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if _, err := os.Lstat(name); err == nil {
+			return "", fmt.Errorf("%q is a dangling symlink", name)
+		}
+		parent := filepath.Dir(name)
+		if parent == name {
+			return filepath.Join(name, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(name), missing)
+		name = parent
+	}
+}
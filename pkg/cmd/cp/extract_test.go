@@ -0,0 +1,297 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  string
+	mode     int64
+	uid      int
+}
+
+func writeTestTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     entry.mode,
+			Uid:      entry.uid,
+			Gid:      entry.uid,
+			Size:     int64(len(entry.content)),
+		}
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestUntarPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		symlinks string
+		strict   bool
+		entries  []tarEntry
+		// files maps paths relative to the test directory to the content of
+		// the regular files expected there, or to "" if nothing is expected
+		files map[string]string
+		// links maps paths relative to the test directory to the targets of
+		// the symlinks expected there
+		links       map[string]string
+		expectedErr string
+	}{
+		{
+			name: "skip symlinks",
+			entries: []tarEntry{
+				{name: "target", content: "t"},
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "target"},
+			},
+			files: map[string]string{"dest/target": "t", "dest/link": ""},
+		},
+		{
+			name:     "preserve symlinks within the destination",
+			symlinks: symlinksPreserve,
+			entries: []tarEntry{
+				{name: "target", content: "t"},
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "target"},
+				{name: "nested/up", typeflag: tar.TypeSymlink, linkname: "../target"},
+				{name: "abs", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+				{name: "out", typeflag: tar.TypeSymlink, linkname: "../outside/secret"},
+				{name: "deep", typeflag: tar.TypeSymlink, linkname: "nested/../../outside"},
+				{name: "through", typeflag: tar.TypeSymlink, linkname: "escape/secret"},
+			},
+			files: map[string]string{"dest/abs": "", "dest/out": "", "dest/deep": "", "dest/through": ""},
+			links: map[string]string{"dest/link": "target", "dest/nested/up": "../target"},
+		},
+		{
+			name:     "write through a preserved symlink",
+			symlinks: symlinksPreserve,
+			entries: []tarEntry{
+				{name: "sub/", typeflag: tar.TypeDir, mode: 0755},
+				{name: "dirlink", typeflag: tar.TypeSymlink, linkname: "sub"},
+				{name: "dirlink/file", content: "f"},
+			},
+			files: map[string]string{"dest/sub/file": "f"},
+			links: map[string]string{"dest/dirlink": "sub"},
+		},
+		{
+			name: "write through an existing symlink pointing outside",
+			entries: []tarEntry{
+				{name: "escape/secret", content: "evil"},
+				{name: "escape/dir/", typeflag: tar.TypeDir, mode: 0755},
+			},
+			files: map[string]string{"outside/secret": "secret", "outside/dir": ""},
+			links: map[string]string{"dest/escape": "../outside"},
+		},
+		{
+			name: "replace an existing symlink",
+			entries: []tarEntry{
+				{name: "escape", content: "plain"},
+			},
+			files: map[string]string{"dest/escape": "plain", "outside/secret": "secret"},
+		},
+		{
+			name: "relative names outside the destination",
+			entries: []tarEntry{
+				{name: "../evil", content: "evil"},
+				{name: "nested/../../evil", content: "evil"},
+			},
+			files: map[string]string{"evil": ""},
+		},
+		{
+			name:     "follow symlinks to files",
+			symlinks: symlinksFollow,
+			entries: []tarEntry{
+				{name: "target", content: "t"},
+				{name: "copy", typeflag: tar.TypeSymlink, linkname: "target"},
+				{name: "chain", typeflag: tar.TypeSymlink, linkname: "copy"},
+				{name: "sub/", typeflag: tar.TypeDir, mode: 0755},
+				{name: "sub/f", content: "f"},
+				{name: "dirlink", typeflag: tar.TypeSymlink, linkname: "sub"},
+				{name: "dangling", typeflag: tar.TypeSymlink, linkname: "missing"},
+				{name: "out", typeflag: tar.TypeSymlink, linkname: "../outside/secret"},
+			},
+			files: map[string]string{"dest/copy": "t", "dest/chain": "t", "dest/dangling": "", "dest/out": ""},
+			links: map[string]string{"dest/dirlink": "sub"},
+		},
+		{
+			name: "hardlinks",
+			entries: []tarEntry{
+				{name: "target", content: "t"},
+				{name: "hard", typeflag: tar.TypeLink, linkname: "target"},
+				{name: "out", typeflag: tar.TypeLink, linkname: "../outside/secret"},
+				{name: "through", typeflag: tar.TypeLink, linkname: "escape/secret"},
+				{name: "missing", typeflag: tar.TypeLink, linkname: "skipped"},
+			},
+			files: map[string]string{"dest/hard": "t", "dest/out": "", "dest/through": "", "dest/missing": ""},
+		},
+		{
+			name:        "strict relative name outside the destination",
+			strict:      true,
+			entries:     []tarEntry{{name: "../evil", content: "evil"}},
+			files:       map[string]string{"evil": ""},
+			expectedErr: "is outside target destination",
+		},
+		{
+			name:        "strict write through an existing symlink",
+			strict:      true,
+			entries:     []tarEntry{{name: "escape/secret", content: "evil"}},
+			files:       map[string]string{"outside/secret": "secret"},
+			expectedErr: "resolves outside target destination through a symlink",
+		},
+		{
+			name:        "strict symlink pointing outside",
+			symlinks:    symlinksPreserve,
+			strict:      true,
+			entries:     []tarEntry{{name: "out", typeflag: tar.TypeSymlink, linkname: "../outside/secret"}},
+			files:       map[string]string{"dest/out": ""},
+			expectedErr: "points outside target destination",
+		},
+		{
+			name:        "strict hardlink pointing outside",
+			strict:      true,
+			entries:     []tarEntry{{name: "out", typeflag: tar.TypeLink, linkname: "../outside/secret"}},
+			files:       map[string]string{"dest/out": ""},
+			expectedErr: "points outside target destination",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testdir, err := ioutil.TempDir("", "test-untar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(testdir)
+			dest := filepath.Join(testdir, "dest")
+			if err := os.MkdirAll(filepath.Join(testdir, "outside"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(dest, 0755); err != nil {
+				t.Fatal(err)
+			}
+			createTmpFile(t, filepath.Join(testdir, "outside/secret"), "secret")
+			if err := os.Symlink("../outside", filepath.Join(dest, "escape")); err != nil {
+				t.Fatal(err)
+			}
+
+			errOut := &bytes.Buffer{}
+			o := NewCopyOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &bytes.Buffer{}, ErrOut: errOut})
+			if len(test.symlinks) > 0 {
+				o.Symlinks = test.symlinks
+			}
+			o.Strict = test.strict
+			err = o.untarAll(fileSpec{}, writeTestTar(t, test.entries), dest, "")
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Errorf("expected error %q, got %v", test.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for name, content := range test.files {
+				info, err := os.Lstat(filepath.Join(testdir, name))
+				if len(content) == 0 {
+					if !os.IsNotExist(err) {
+						t.Errorf("expected nothing at %s\n%s", name, errOut.String())
+					}
+					continue
+				}
+				if err != nil || !info.Mode().IsRegular() {
+					t.Errorf("expected a regular file at %s: %v", name, err)
+					continue
+				}
+				cmpFileData(t, filepath.Join(testdir, name), content)
+			}
+			for name, target := range test.links {
+				actual, err := os.Readlink(filepath.Join(testdir, name))
+				if err != nil || actual != target {
+					t.Errorf("expected a symlink %s -> %s, got %q %v", name, target, actual, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUntarPreservePermissions(t *testing.T) {
+	testdir, err := ioutil.TempDir("", "test-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testdir)
+
+	o := NewCopyOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}})
+	o.PreservePermissions = true
+	entries := []tarEntry{
+		{name: "ro/", typeflag: tar.TypeDir, mode: 0555, uid: 1234},
+		{name: "ro/file", content: "f", mode: 0640, uid: 1234},
+		{name: "suid", content: "s", mode: 04755, uid: 1234},
+	}
+	if err := o.untarAll(fileSpec{}, writeTestTar(t, entries), testdir, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// let the temporary directory be removed
+	defer os.Chmod(filepath.Join(testdir, "ro"), 0755)
+
+	asRoot := os.Geteuid() == 0
+	expected := map[string]os.FileMode{
+		"ro":      os.ModeDir | 0555,
+		"ro/file": 0640,
+		"suid":    0755,
+	}
+	if asRoot {
+		expected["suid"] = os.ModeSetuid | 0755
+	}
+	for name, mode := range expected {
+		info, err := os.Stat(filepath.Join(testdir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != mode {
+			t.Errorf("%s: expected mode %v, got %v", name, mode, info.Mode())
+		}
+	}
+}
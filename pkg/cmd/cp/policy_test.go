@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/client-go/rest/fake"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func TestCopyPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	remoteFile := filepath.Join(dir, "remote")
+	createTmpFile(t, remoteFile, "data")
+	localFile := filepath.Join(dir, "local")
+	createTmpFile(t, localFile, "data")
+
+	tests := []struct {
+		name        string
+		policy      string
+		args        []string
+		transport   string
+		expectedErr string
+	}{
+		{
+			name:   "from a pod",
+			policy: "clusters:\n- name: dev\n  copyToPod: false\n",
+			args:   []string{"web-1:" + remoteFile, filepath.Join(dir, "copy")},
+		},
+		{
+			name:        "to a pod",
+			policy:      "clusters:\n- name: dev\n  copyToPod: false\n",
+			args:        []string{localFile, "web-1:" + filepath.Join(dir, "copy")},
+			expectedErr: `access denied by the access policy test for cluster "dev": copying files to pods is not allowed`,
+		},
+		{
+			name:        "command denied",
+			policy:      "clusters:\n- name: dev\n  commands: [exec, logs]\n",
+			args:        []string{"web-1:" + remoteFile, filepath.Join(dir, "copy")},
+			expectedErr: "cp is not allowed",
+		},
+		{
+			name:        "namespace of a file spec",
+			policy:      "clusters:\n- name: dev\n  namespaces:\n    deny: [kube-system]\n",
+			args:        []string{"kube-system/web-1:" + remoteFile, filepath.Join(dir, "copy")},
+			expectedErr: `cp is not allowed in namespace "kube-system"`,
+		},
+		{
+			name:        "cluster of a file spec",
+			policy:      "clusters:\n- name: prod\n  copyToPod: false\n",
+			args:        []string{"web-1:" + remoteFile, "prod:test/web-1:" + filepath.Join(dir, "copy")},
+			expectedErr: `access denied by the access policy test for cluster "prod"`,
+		},
+		{
+			name:        "ephemeral transport from a pod",
+			policy:      "clusters:\n- name: dev\n  copyToPod: false\n",
+			args:        []string{"web-1:" + remoteFile, filepath.Join(dir, "copy")},
+			transport:   transportEphemeral,
+			expectedErr: `--transport=ephemeral adds a container to pod web-1: access denied by the access policy test for cluster "dev": copying files to pods is not allowed`,
+		},
+		{
+			name:        "ephemeral transport to a pod",
+			policy:      "clusters:\n- name: dev\n  copyToPod: false\n",
+			args:        []string{localFile, "web-1:" + filepath.Join(dir, "copy")},
+			transport:   transportEphemeral,
+			expectedErr: "copying files to pods is not allowed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := configs.ParsePolicy([]byte(test.policy), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.PolicyVal = policy
+			tf.Client = &fake.RESTClient{
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+					return nil, fmt.Errorf("unexpected request")
+				}),
+			}
+			tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+			client, err := tf.ClientForCluster("dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			executor := &localExecutor{}
+			o, _ := newTransferTestOptions(t, executor)
+			o.clusterNames = []string{"dev", "prod"}
+			o.clientForCluster = tf.ClientForCluster
+			o.checkAccess = client.CheckAccess
+			if len(test.transport) > 0 {
+				o.Transport = test.transport
+			}
+
+			err = o.Run(nil, test.args)
+			if len(test.expectedErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+			}
+			if len(executor.commands) != 0 {
+				t.Errorf("expected no command before the denial, got %v", executor.commands)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressInterval = 200 * time.Millisecond
+	progressBarWidth = 30
+)
+
+// progressBar prints the bytes transferred and the transfer rate to out,
+// together with a bar if the total is known. A nil progressBar prints nothing.
+// It can be written to by the goroutine producing a stream while the caller
+// finishes it.
+type progressBar struct {
+	out   io.Writer
+	total int64
+	now   func() time.Time
+	start time.Time
+
+	lock    sync.Mutex
+	done    int64
+	printed time.Time
+	// finished is set once the final state is printed, later writes are not
+	finished bool
+}
+
+func newProgressBar(out io.Writer, total int64) *progressBar {
+	now := time.Now()
+	return &progressBar{out: out, total: total, now: time.Now, start: now}
+}
+
+// Write counts the bytes written, so that the progress bar can be used with io.TeeReader
+func (p *progressBar) Write(b []byte) (int, error) {
+	if p == nil {
+		return len(b), nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.done += int64(len(b))
+	if !p.finished && p.now().Sub(p.printed) >= progressInterval {
+		p.print()
+	}
+	return len(b), nil
+}
+
+// set sets the bytes transferred, e.g. when a transfer is resumed or restarted
+func (p *progressBar) set(done int64) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.done = done
+}
+
+// finish prints the final state and ends the line
+func (p *progressBar) finish() {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.print()
+	fmt.Fprintln(p.out)
+}
+
+// print prints the current state, the lock must be held
+func (p *progressBar) print() {
+	now := p.now()
+	p.printed = now
+
+	rate := float64(0)
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = float64(p.done) / elapsed
+	}
+	if p.total <= 0 {
+		fmt.Fprintf(p.out, "\r%s  %s/s", formatBytes(float64(p.done)), formatBytes(rate))
+		return
+	}
+
+	done := p.done
+	if done > p.total {
+		done = p.total
+	}
+	filled := int(int64(progressBarWidth) * done / p.total)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	fmt.Fprintf(p.out, "\r[%s] %s / %s  %s/s", bar, formatBytes(float64(p.done)), formatBytes(float64(p.total)), formatBytes(rate))
+}
+
+// formatBytes formats a number of bytes with binary prefixes, e.g. 1.5MiB
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f%s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", bytes, units[unit])
+}
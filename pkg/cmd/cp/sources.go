@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hasGlobMeta returns true if file is a pattern rather than a file name
+func hasGlobMeta(file string) bool {
+	return strings.ContainsAny(file, "*?[")
+}
+
+// expandSources expands the patterns among the file names of sources, in the
+// container for remote ones. All sources must be local, or in the same pod.
+func (o *CopyOptions) expandSources(sources []fileSpec) (fileSpec, []string, error) {
+	first := sources[0]
+	files := []string{}
+	for _, spec := range sources {
+		if spec.ClusterName != first.ClusterName || spec.PodNamespace != first.PodNamespace || spec.PodName != first.PodName {
+			return first, nil, fmt.Errorf("all sources must be local files or in the same pod")
+		}
+		if len(spec.File) == 0 {
+			return first, nil, errFileCannotBeEmpty
+		}
+		if !hasGlobMeta(spec.File) {
+			files = append(files, spec.File)
+			continue
+		}
+
+		var matches []string
+		var err error
+		if len(spec.PodName) == 0 {
+			if _, err := os.Lstat(spec.File); err == nil {
+				// a file named like a pattern
+				files = append(files, spec.File)
+				continue
+			}
+			matches, err = filepath.Glob(spec.File)
+		} else {
+			matches, err = o.expandRemoteGlob(spec)
+		}
+		if err != nil {
+			return first, nil, err
+		}
+		if len(matches) == 0 {
+			return first, nil, fmt.Errorf("%s matched no files", spec.File)
+		}
+		files = append(files, matches...)
+	}
+	return first, files, nil
+}
+
+// expandRemoteGlob expands the pattern of spec with sh in its container
+func (o *CopyOptions) expandRemoteGlob(spec fileSpec) ([]string, error) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	// with an empty IFS the unquoted pattern is expanded without being split at
+	// spaces, a pattern matching nothing is kept and printed if it is a file name
+	script := `IFS=; for f in $1; do if [ -e "$f" ] || [ -L "$f" ]; then printf '%s\n' "$f"; fi; done`
+	if err := o.remoteExec(spec, out, errOut, "sh", "-c", script, "sh", spec.File); err != nil {
+		return nil, fmt.Errorf("unable to expand %s in pod %s: %v %s", spec.File, spec.PodName, err, strings.TrimSpace(errOut.String()))
+	}
+	matches := []string{}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		matches = append(matches, scanner.Text())
+	}
+	return matches, scanner.Err()
+}
+
+// copyMany copies several local files to a directory in a pod, or several
+// files of a pod to a local directory, in a single tar stream
+func (o *CopyOptions) copyMany(src fileSpec, files []string, dest fileSpec) error {
+	if len(dest.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+	if len(src.PodName) == 0 && len(dest.PodName) == 0 {
+		return errRemoteSpecRequired
+	}
+	if len(src.PodName) != 0 && len(dest.PodName) != 0 {
+		return fmt.Errorf("several files can only be copied between a pod and the local filesystem")
+	}
+	if o.Transport != transportAuto && o.Transport != transportTar {
+		return fmt.Errorf("several files can only be copied with tar, --transport=%s is not supported", o.Transport)
+	}
+	if len(src.PodName) != 0 {
+		return o.copyManyFromPod(src, files, dest)
+	}
+	return o.copyManyToPod(files, dest)
+}
+
+func (o *CopyOptions) copyManyToPod(files []string, dest fileSpec) error {
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("%s doesn't exist in local filesystem", file)
+		}
+	}
+	if err := o.checkDestinationIsDir(dest); err != nil {
+		return fmt.Errorf("%s must be an existing directory in pod %s to copy several files to it", dest.File, dest.PodName)
+	}
+
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	progress := o.newProgressBar(0)
+	defer progress.finish()
+	go func() {
+		writer.CloseWithError(makeTarFiles(files, io.MultiWriter(writer, progress)))
+	}()
+	return o.remoteExecWithStdin(dest, reader, o.Out, o.ErrOut, o.untarCommandIn(dest.File)...)
+}
+
+// makeTarFiles writes several files to a single tar stream, each named after its base name
+func makeTarFiles(files []string, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+	defer tarWriter.Close()
+	for _, file := range files {
+		file = path.Clean(file)
+		if err := recursiveTar(path.Dir(file), path.Base(file), "", path.Base(file), tarWriter); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+func (o *CopyOptions) copyManyFromPod(src fileSpec, files []string, dest fileSpec) error {
+	if stat, err := os.Stat(dest.File); err != nil || !stat.IsDir() {
+		return fmt.Errorf("%s must be an existing directory to copy several files to it", dest.File)
+	}
+	rename := baseNameRenamer(files)
+
+	retries := &retryBudget{max: o.Retries}
+	for {
+		reader, writer := io.Pipe()
+		go func() {
+			pipe := o.newTarPipeFiles(src, files)
+			defer pipe.Close()
+			writer.CloseWithError(renameTarEntries(pipe, writer, rename))
+		}()
+		progress := o.newProgressBar(0)
+		err := o.untarAll(src, io.TeeReader(reader, progress), dest.File, "")
+		reader.Close()
+		progress.finish()
+		if err == nil && o.Verify {
+			err = o.verifyDir(src, files, dest.File, rename)
+		}
+
+		if err == nil || !isRetryable(err) || !retries.next() {
+			return err
+		}
+		fmt.Fprintf(o.ErrOut, "Copying %s again after a %v, retry %s\n", strings.Join(files, " "), err, retries)
+	}
+}
+
+// baseNameRenamer names the tar entries of the remote files after their base
+// names, the way a file copied to a directory is named
+func baseNameRenamer(files []string) tarRenamer {
+	prefixes := make([]string, len(files))
+	for i, file := range files {
+		prefixes[i] = stripPathShortcuts(path.Clean(getPrefix(file)))
+	}
+	return func(name string) (string, bool) {
+		match := -1
+		for i, prefix := range prefixes {
+			if !hasPathPrefix(name, prefix) {
+				continue
+			}
+			if match == -1 || len(prefix) > len(prefixes[match]) {
+				match = i
+			}
+		}
+		if match == -1 {
+			return "", false
+		}
+		return path.Join(path.Base(prefixes[match]), strings.TrimPrefix(name, prefixes[match])), true
+	}
+}
+
+// hasPathPrefix returns true if name is prefix or a path below it
+func hasPathPrefix(name, prefix string) bool {
+	if len(prefix) == 0 {
+		return true
+	}
+	name = strings.TrimSuffix(name, "/")
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
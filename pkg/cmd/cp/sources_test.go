@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopySeveralFiles(t *testing.T) {
+	files := map[string]string{
+		"remote/x.log":       "x",
+		"remote/y.log":       "y",
+		"remote/z.txt":       "z",
+		"remote/sub/w.log":   "w",
+		"remote/with space/": "",
+		"local/a.yaml":       "a",
+		"local/b.yaml":       "b",
+		"local/c.txt":        "c",
+	}
+	tests := []struct {
+		name        string
+		args        []string
+		expected    map[string]string
+		notExpected []string
+		expectedErr string
+	}{
+		{
+			name: "remote pattern",
+			args: []string{"web-1:remote/*.log", "out"},
+			expected: map[string]string{
+				"out/x.log": "x",
+				"out/y.log": "y",
+			},
+			notExpected: []string{"out/z.txt", "out/w.log"},
+		},
+		{
+			name: "several remote sources",
+			args: []string{"web-1:remote/z.txt", "web-1:remote/sub", "out"},
+			expected: map[string]string{
+				"out/z.txt":     "z",
+				"out/sub/w.log": "w",
+			},
+		},
+		{
+			name: "remote pattern matching a single file",
+			args: []string{"web-1:remote/z.*", "out/renamed"},
+			expected: map[string]string{
+				"out/renamed": "z",
+			},
+		},
+		{
+			name: "local pattern",
+			args: []string{"local/*.yaml", "web-1:out"},
+			expected: map[string]string{
+				"out/a.yaml": "a",
+				"out/b.yaml": "b",
+			},
+			notExpected: []string{"out/c.txt"},
+		},
+		{
+			name: "several local sources",
+			args: []string{"local/a.yaml", "local/c.txt", "web-1:out"},
+			expected: map[string]string{
+				"out/a.yaml": "a",
+				"out/c.txt":  "c",
+			},
+		},
+		{
+			name:        "remote pattern matching nothing",
+			args:        []string{"web-1:remote/*.json", "out"},
+			expectedErr: "matched no files",
+		},
+		{
+			name:        "missing destination directory",
+			args:        []string{"web-1:remote/*.log", "missing"},
+			expectedErr: "must be an existing directory",
+		},
+		{
+			name:        "sources in several pods",
+			args:        []string{"web-1:remote/x.log", "web-2:remote/y.log", "out"},
+			expectedErr: "all sources must be local files or in the same pod",
+		},
+		{
+			name:        "several local sources to a local directory",
+			args:        []string{"local/a.yaml", "local/c.txt", "out"},
+			expectedErr: "one of src or dest must be a remote file specification",
+		},
+		{
+			name:        "local pattern to a local directory",
+			args:        []string{"local/*.yaml", "out"},
+			expectedErr: "one of src or dest must be a remote file specification",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o, errOut := newTransferTestOptions(t, &localExecutor{})
+			dir, cleanup := newSyncTestDirs(t, map[string]string{})
+			defer cleanup()
+			for name, content := range files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasSuffix(name, "/") {
+					createTmpFile(t, filepath.Join(dir, name), content)
+				}
+			}
+			if err := os.Mkdir(filepath.Join(dir, "out"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			args := []string{}
+			for _, arg := range test.args {
+				if i := strings.Index(arg, ":"); i != -1 {
+					args = append(args, arg[:i+1]+filepath.Join(dir, arg[i+1:]))
+				} else {
+					args = append(args, filepath.Join(dir, arg))
+				}
+			}
+			err := o.Run(nil, args)
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+			}
+			for name, content := range test.expected {
+				cmpFileData(t, filepath.Join(dir, name), content)
+			}
+			for _, name := range test.notExpected {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("expected %s not to be copied", name)
+				}
+			}
+		})
+	}
+}
+
+func TestBaseNameRenamer(t *testing.T) {
+	rename := baseNameRenamer([]string{"/var/log/app.log", "/var/lib/data", "/var/lib/data/cache"})
+	tests := map[string]string{
+		"var/log/app.log":           "app.log",
+		"var/lib/data/":             "data",
+		"var/lib/data/db/file":      "data/db/file",
+		"var/lib/data/cache/entry":  "cache/entry",
+		"var/log/app.log.1":         "",
+		"etc/passwd":                "",
+		"var/lib/database/anything": "",
+	}
+	for name, expected := range tests {
+		actual, ok := rename(name)
+		if ok != (len(expected) > 0) || actual != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", name, expected, actual, ok)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Angus-F/kubectl/pkg/util/interrupt"
+)
+
+// syncDeleteBatch is the number of files removed from a container per exec
+const syncDeleteBatch = 100
+
+// syncFile is a regular file of a synced tree
+type syncFile struct {
+	size    int64
+	modTime int64
+	// checksum is only set with --checksum
+	checksum string
+}
+
+// syncTree maps the paths of the regular files of a tree, relative to its root, to their state
+type syncTree map[string]syncFile
+
+// syncPlan lists the files a sync sends and deletes
+type syncPlan struct {
+	send      []string
+	delete    []string
+	unchanged int
+}
+
+// planSync compares the source and destination trees. Files differ if their
+// size or modification time differ, or with checksums, if their size or
+// checksum differ.
+func planSync(src, dest syncTree, checksum, deleteExtras bool) *syncPlan {
+	plan := &syncPlan{}
+	for name, file := range src {
+		existing, found := dest[name]
+		switch {
+		case !found, existing.size != file.size:
+			plan.send = append(plan.send, name)
+		case checksum && existing.checksum != file.checksum:
+			plan.send = append(plan.send, name)
+		case !checksum && existing.modTime != file.modTime:
+			plan.send = append(plan.send, name)
+		default:
+			plan.unchanged++
+		}
+	}
+	if deleteExtras {
+		for name := range dest {
+			if _, found := src[name]; !found {
+				plan.delete = append(plan.delete, name)
+			}
+		}
+	}
+	sort.Strings(plan.send)
+	sort.Strings(plan.delete)
+	return plan
+}
+
+// runSync makes dest a copy of the directory src, copying only the files
+// that differ. With --watch, local changes are synced until interrupted.
+func (o *CopyOptions) runSync(src, dest fileSpec) error {
+	if len(src.File) == 0 || len(dest.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+	src.File = strings.TrimSuffix(src.File, "/")
+	dest.File = strings.TrimSuffix(dest.File, "/")
+	if len(src.PodName) != 0 {
+		return o.syncFromPod(src, dest)
+	}
+
+	if stat, err := os.Stat(src.File); err != nil || !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory in local filesystem", src.File)
+	}
+	local, err := o.localSyncTree(src.File)
+	if err != nil {
+		return err
+	}
+	if err := o.syncToPod(src, dest, local, nil); err != nil {
+		return err
+	}
+	if !o.Watch {
+		return nil
+	}
+
+	stop := make(chan struct{})
+	return interrupt.New(nil, func() { close(stop) }).Run(func() error {
+		return o.watchSync(src, dest, local, stop)
+	})
+}
+
+// watchSync polls src for changes and syncs the changed files to dest until
+// stop is closed. The destination is assumed to be unchanged since the last
+// sync, so only the local tree is compared with its previous state.
+func (o *CopyOptions) watchSync(src, dest fileSpec, last syncTree, stop <-chan struct{}) error {
+	fmt.Fprintf(o.Out, "Watching %s for changes\n", src.File)
+	ticker := time.NewTicker(o.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+		local, err := o.localSyncTree(src.File)
+		if err != nil {
+			return err
+		}
+		if err := o.syncToPod(src, dest, local, last); err != nil {
+			return err
+		}
+		last = local
+	}
+}
+
+// syncToPod syncs the local tree of src to dest. The remote tree is listed
+// unless known is set.
+func (o *CopyOptions) syncToPod(src, dest fileSpec, local, known syncTree) error {
+	remote := known
+	if remote == nil {
+		var err error
+		if remote, err = o.remoteSyncTree(dest, false); err != nil {
+			return err
+		}
+	}
+	plan := planSync(local, remote, o.Checksum, o.Delete)
+	if len(plan.send) == 0 && len(plan.delete) == 0 {
+		if known == nil {
+			o.printSyncResult(src, dest, plan)
+		}
+		return nil
+	}
+
+	if len(plan.send) > 0 {
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(makeSyncTar(src.File, path.Base(dest.File), plan.send, writer))
+		}()
+		err := o.remoteExecWithStdin(dest, reader, o.Out, o.ErrOut, o.untarCommand(dest)...)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(plan.delete); i += syncDeleteBatch {
+		end := i + syncDeleteBatch
+		if end > len(plan.delete) {
+			end = len(plan.delete)
+		}
+		command := []string{"rm", "-f", "--"}
+		for _, name := range plan.delete[i:end] {
+			command = append(command, dest.File+"/"+name)
+		}
+		if err := o.remoteExec(dest, o.Out, o.ErrOut, command...); err != nil {
+			return err
+		}
+	}
+	o.printSyncResult(src, dest, plan)
+	return nil
+}
+
+// syncFromPod syncs the remote tree of src to the local directory dest
+func (o *CopyOptions) syncFromPod(src, dest fileSpec) error {
+	remote, err := o.remoteSyncTree(src, true)
+	if err != nil {
+		return err
+	}
+	local, err := o.localSyncTree(dest.File)
+	if err != nil {
+		return err
+	}
+	plan := planSync(remote, local, o.Checksum, o.Delete)
+
+	if len(plan.send) > 0 {
+		if err := os.MkdirAll(dest.File, 0755); err != nil {
+			return err
+		}
+		reader, writer := io.Pipe()
+		go func() {
+			list := strings.Join(plan.send, "\n") + "\n"
+			writer.CloseWithError(o.remoteExecWithStdin(src, strings.NewReader(list), writer, o.ErrOut, "tar", "cf", "-", "-C", src.File, "-T", "-"))
+		}()
+		err := o.untarAll(src, reader, dest.File, "")
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range plan.delete {
+		if err := os.Remove(filepath.Join(dest.File, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	o.printSyncResult(src, dest, plan)
+	return nil
+}
+
+func (o *CopyOptions) printSyncResult(src, dest fileSpec, plan *syncPlan) {
+	for _, name := range plan.send {
+		fmt.Fprintf(o.Out, "sent %s\n", name)
+	}
+	for _, name := range plan.delete {
+		fmt.Fprintf(o.Out, "deleted %s\n", name)
+	}
+	fmt.Fprintf(o.Out, "Synced %s to %s: %d sent, %d deleted, %d unchanged\n", src.File, dest.File, len(plan.send), len(plan.delete), plan.unchanged)
+}
+
+// localSyncTree lists the regular files in root. A missing root is an empty tree.
+func (o *CopyOptions) localSyncTree(root string) (syncTree, error) {
+	tree := syncTree{}
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && name == root {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		// tar headers round modification times to the nearest second
+		file := syncFile{size: info.Size(), modTime: info.ModTime().Round(time.Second).Unix()}
+		if o.Checksum {
+			if file.checksum, err = fileChecksum(name); err != nil {
+				return err
+			}
+		}
+		tree[filepath.ToSlash(rel)] = file
+		return nil
+	})
+	return tree, err
+}
+
+// remoteSyncTree lists the regular files in the directory of spec with find
+// and stat. A missing directory is an empty tree, unless it is the source.
+func (o *CopyOptions) remoteSyncTree(spec fileSpec, source bool) (syncTree, error) {
+	tree := syncTree{}
+	if err := o.remoteExec(spec, ioutil.Discard, ioutil.Discard, "test", "-d", spec.File); err != nil {
+		if !isExitError(err) {
+			return nil, err
+		}
+		if source {
+			return nil, fmt.Errorf("%s is not a directory in pod %s", spec.File, spec.PodName)
+		}
+		return tree, nil
+	}
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	if err := o.remoteExec(spec, out, errOut, "find", spec.File, "-type", "f", "-exec", "stat", "-c", "%s %Y %n", "{}", "+"); err != nil {
+		return nil, fmt.Errorf("unable to list the files in %s: %v %s", spec.File, err, strings.TrimSpace(errOut.String()))
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected stat output %q", scanner.Text())
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected stat output %q", scanner.Text())
+		}
+		modTime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected stat output %q", scanner.Text())
+		}
+		tree[strings.TrimPrefix(fields[2], spec.File+"/")] = syncFile{size: size, modTime: modTime}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if o.Checksum && len(tree) > 0 {
+		checksums, err := o.remoteChecksums(spec, "find", spec.File, "-type", "f", "-exec", "sha256sum", "{}", "+")
+		if err != nil {
+			return nil, err
+		}
+		for name, checksum := range checksums {
+			rel := strings.TrimPrefix(name, spec.File+"/")
+			file := tree[rel]
+			file.checksum = checksum
+			tree[rel] = file
+		}
+	}
+	return tree, nil
+}
+
+// makeSyncTar writes the files of srcDir to a tar stream, named below
+// destBase the way makeTar names a copied directory
+func makeSyncTar(srcDir, destBase string, files []string, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+	defer tarWriter.Close()
+
+	srcDir = path.Clean(srcDir)
+	for _, name := range files {
+		if err := recursiveTar(srcDir, name, "", path.Join(destBase, name), tarWriter); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
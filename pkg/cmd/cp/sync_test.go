@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanSync(t *testing.T) {
+	src := syncTree{
+		"same":     {size: 1, modTime: 10, checksum: "a"},
+		"touched":  {size: 1, modTime: 20, checksum: "a"},
+		"modified": {size: 1, modTime: 10, checksum: "b"},
+		"resized":  {size: 2, modTime: 10, checksum: "a"},
+		"new":      {size: 1, modTime: 10, checksum: "a"},
+	}
+	dest := syncTree{
+		"same":     {size: 1, modTime: 10, checksum: "a"},
+		"touched":  {size: 1, modTime: 10, checksum: "a"},
+		"modified": {size: 1, modTime: 10, checksum: "a"},
+		"resized":  {size: 1, modTime: 10, checksum: "a"},
+		"extra":    {size: 1, modTime: 10, checksum: "a"},
+	}
+	tests := []struct {
+		name         string
+		checksum     bool
+		deleteExtras bool
+		expected     *syncPlan
+	}{
+		{
+			name:     "size and modification time",
+			expected: &syncPlan{send: []string{"new", "resized", "touched"}, unchanged: 2},
+		},
+		{
+			name:     "checksum",
+			checksum: true,
+			expected: &syncPlan{send: []string{"modified", "new", "resized"}, unchanged: 2},
+		},
+		{
+			name:         "delete",
+			deleteExtras: true,
+			expected:     &syncPlan{send: []string{"new", "resized", "touched"}, delete: []string{"extra"}, unchanged: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := planSync(src, dest, test.checksum, test.deleteExtras)
+			if !reflect.DeepEqual(plan, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, plan)
+			}
+		})
+	}
+}
+
+func newSyncTestDirs(t *testing.T, files map[string]string) (string, func()) {
+	dir, err := ioutil.TempDir("", "cp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		createTmpFile(t, filepath.Join(dir, name), content)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestSyncToPod(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		executor := &localExecutor{}
+		o, errOut := newTransferTestOptions(t, executor)
+		o.Sync = true
+		o.Delete = true
+		o.Checksum = checksum
+		out := &bytes.Buffer{}
+		o.Out = out
+
+		dir, cleanup := newSyncTestDirs(t, map[string]string{
+			"local/a":      "a",
+			"local/sub/b":  "b",
+			"remote/extra": "extra",
+		})
+		defer cleanup()
+		src := filepath.Join(dir, "local")
+		dest := "web-1:" + filepath.Join(dir, "remote")
+
+		if err := o.Run(nil, []string{src, dest}); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+		}
+		cmpFileData(t, filepath.Join(dir, "remote/a"), "a")
+		cmpFileData(t, filepath.Join(dir, "remote/sub/b"), "b")
+		if _, err := os.Stat(filepath.Join(dir, "remote/extra")); !os.IsNotExist(err) {
+			t.Errorf("expected the extra file to be deleted")
+		}
+		if !strings.Contains(out.String(), "2 sent, 1 deleted, 0 unchanged") {
+			t.Errorf("unexpected output %q", out.String())
+		}
+
+		out.Reset()
+		createTmpFile(t, filepath.Join(dir, "local/a"), "changed")
+		if err := o.Run(nil, []string{src, dest}); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+		}
+		cmpFileData(t, filepath.Join(dir, "remote/a"), "changed")
+		if !strings.Contains(out.String(), "sent a\n") || !strings.Contains(out.String(), "1 sent, 0 deleted, 1 unchanged") {
+			t.Errorf("unexpected output %q", out.String())
+		}
+	}
+}
+
+func TestSyncFromPod(t *testing.T) {
+	executor := &localExecutor{}
+	o, errOut := newTransferTestOptions(t, executor)
+	o.Sync = true
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	dir, cleanup := newSyncTestDirs(t, map[string]string{
+		"remote/a":     "a",
+		"remote/sub/b": "b",
+		"local/extra":  "extra",
+	})
+	defer cleanup()
+	src := "web-1:" + filepath.Join(dir, "remote")
+	dest := filepath.Join(dir, "local")
+
+	for _, expected := range []string{"2 sent, 0 deleted, 0 unchanged", "0 sent, 0 deleted, 2 unchanged"} {
+		out.Reset()
+		if err := o.Run(nil, []string{src, dest}); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+		}
+		cmpFileData(t, filepath.Join(dir, "local/a"), "a")
+		cmpFileData(t, filepath.Join(dir, "local/sub/b"), "b")
+		cmpFileData(t, filepath.Join(dir, "local/extra"), "extra")
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q, got %q", expected, out.String())
+		}
+	}
+
+	if err := o.Run(nil, []string{"web-1:" + filepath.Join(dir, "missing"), dest}); err == nil || !strings.Contains(err.Error(), "is not a directory in pod web-1") {
+		t.Errorf("expected a missing source to fail, got %v", err)
+	}
+}
+
+func TestWatchSync(t *testing.T) {
+	executor := &localExecutor{}
+	o, errOut := newTransferTestOptions(t, executor)
+	o.Sync = true
+	o.Delete = true
+	o.WatchInterval = 10 * time.Millisecond
+	o.Out = ioutil.Discard
+
+	dir, cleanup := newSyncTestDirs(t, map[string]string{
+		"local/a":  "a",
+		"remote/a": "a",
+	})
+	defer cleanup()
+	src := fileSpec{File: filepath.Join(dir, "local")}
+	dest := fileSpec{PodName: "web-1", File: filepath.Join(dir, "remote")}
+
+	last, err := o.localSyncTree(src.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- o.watchSync(src, dest, last, stop)
+	}()
+
+	createTmpFile(t, filepath.Join(dir, "local/b"), "b")
+	os.Remove(filepath.Join(dir, "local/a"))
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, removed := os.Stat(filepath.Join(dir, "remote/a"))
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "remote/b"))
+		if os.IsNotExist(removed) && string(data) == "b" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the changes to be synced\n%s", errOut.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,380 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	uexec "github.com/Angus-F/client-go/util/exec"
+	"github.com/Angus-F/kubectl/pkg/cmd/exec"
+)
+
+const (
+	defaultRetries = 3
+)
+
+// retryBudget counts the retries of a single copy. A negative max allows
+// unlimited retries.
+type retryBudget struct {
+	max  int
+	used int
+}
+
+func (r *retryBudget) next() bool {
+	if r.max >= 0 && r.used >= r.max {
+		return false
+	}
+	r.used++
+	return true
+}
+
+func (r *retryBudget) String() string {
+	if r.max < 0 {
+		return strconv.Itoa(r.used)
+	}
+	return fmt.Sprintf("%d/%d", r.used, r.max)
+}
+
+// checksumError is returned if files copied from a pod don't match their
+// checksums computed in the container
+type checksumError struct {
+	files []string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s", strings.Join(e.files, ", "))
+}
+
+// isExitError returns true if err is the exit code of a remote command that
+// ran to completion, as opposed to a failed stream
+func isExitError(err error) bool {
+	_, ok := err.(uexec.ExitError)
+	return ok
+}
+
+// remoteExec runs command in the container of spec
+func (o *CopyOptions) remoteExec(spec fileSpec, out, errOut io.Writer, command ...string) error {
+	return o.remoteExecWithStdin(spec, nil, out, errOut, command...)
+}
+
+// remoteExecWithStdin runs command in the container of spec, streaming in to
+// its stdin unless in is nil
+func (o *CopyOptions) remoteExecWithStdin(spec fileSpec, in io.Reader, out, errOut io.Writer, command ...string) error {
+	options := &exec.ExecOptions{
+		StreamOptions: exec.StreamOptions{
+			IOStreams: genericclioptions.IOStreams{
+				In:     in,
+				Out:    out,
+				ErrOut: errOut,
+			},
+			Stdin: in != nil,
+
+			Namespace: spec.PodNamespace,
+			PodName:   spec.PodName,
+		},
+
+		Command:  command,
+		Executor: o.executor(),
+	}
+	return o.execute(spec.ClusterName, options)
+}
+
+func (o *CopyOptions) executor() exec.RemoteExecutor {
+	if o.Executor != nil {
+		return o.Executor
+	}
+	return &exec.DefaultRemoteExecutor{}
+}
+
+func (o *CopyOptions) newProgressBar(total int64) *progressBar {
+	if !o.Progress {
+		return nil
+	}
+	return newProgressBar(o.ErrOut, total)
+}
+
+// remoteFileSize returns the size of src if it is a regular file
+func (o *CopyOptions) remoteFileSize(src fileSpec) (int64, bool) {
+	out := &bytes.Buffer{}
+	if err := o.remoteExec(src, out, ioutil.Discard, "sh", "-c", `[ -f "$1" ] && wc -c < "$1"`, "sh", src.File); err != nil {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	return size, err == nil
+}
+
+// copyFileFromPod copies the regular file src. If the stream fails or ends
+// early, it is resumed at the offset copied so far with tail.
+func (o *CopyOptions) copyFileFromPod(src, dest fileSpec, size int64, retries *retryBudget) error {
+	destFile := localDestFile(src, dest)
+	file, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	progress := o.newProgressBar(size)
+	defer progress.finish()
+	out := &countingWriter{writer: io.MultiWriter(file, progress)}
+	for {
+		err := o.remoteExec(src, out, o.ErrOut, "tail", "-c", fmt.Sprintf("+%d", out.count+1), src.File)
+		if err == nil && out.count < size {
+			err = fmt.Errorf("the stream ended after %d of %d bytes", out.count, size)
+		}
+		if err != nil {
+			if isExitError(err) || !retries.next() {
+				return err
+			}
+			fmt.Fprintf(o.ErrOut, "Resuming copy of %s at %d bytes, retry %s: %v\n", src.File, out.count, retries, err)
+			progress.set(out.count)
+			continue
+		}
+
+		if o.Verify {
+			err = o.verifyFile(src, destFile)
+		}
+		if err == nil {
+			return file.Close()
+		}
+		if _, ok := err.(*checksumError); !ok || !retries.next() {
+			return err
+		}
+		fmt.Fprintf(o.ErrOut, "Copying %s again after a %v, retry %s\n", src.File, err, retries)
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		out.count = 0
+		progress.set(0)
+	}
+}
+
+// localDestFile returns the local file a single remote file is copied to,
+// which is in dest if dest is a directory
+func localDestFile(src, dest fileSpec) string {
+	if stat, err := os.Stat(dest.File); err == nil && stat.IsDir() {
+		return filepath.Join(dest.File, path.Base(src.File))
+	}
+	return dest.File
+}
+
+// copyTarFromPod copies src with tar, retrying the whole copy if the tar
+// stream fails or is truncated, or the copied files don't match their checksums
+func (o *CopyOptions) copyTarFromPod(src, dest fileSpec, retries *retryBudget) error {
+	prefix := getPrefix(src.File)
+	prefix = path.Clean(prefix)
+	// remove extraneous path shortcuts - these could occur if a path contained extra "../"
+	// and attempted to navigate beyond "/" in a remote filesystem
+	prefix = stripPathShortcuts(prefix)
+
+	for {
+		progress := o.newProgressBar(0)
+		pipe := o.newTarPipe(src)
+		err := o.untarAll(src, io.TeeReader(pipe, progress), dest.File, prefix)
+		pipe.Close()
+		progress.finish()
+		if err == nil && o.Verify {
+			err = o.verifyDir(src, []string{src.File}, dest.File, prefixRenamer(prefix, ""))
+		}
+
+		if err == nil || !isRetryable(err) || !retries.next() {
+			return err
+		}
+		fmt.Fprintf(o.ErrOut, "Copying %s again after a %v, retry %s\n", src.File, err, retries)
+	}
+}
+
+// tarStreamError is returned if the tar stream of a remote path fails before
+// it ends
+type tarStreamError struct {
+	err error
+}
+
+func (e *tarStreamError) Error() string {
+	return fmt.Sprintf("broken tar stream: %v", e.err)
+}
+
+// isRetryable returns true if a tar copy failed in a way that copying it again
+// may fix. The tar stream of a directory is not the same byte for byte when it
+// is created again, since files may change in between, so a failed stream is
+// copied again from the start rather than resumed at an offset.
+func isRetryable(err error) bool {
+	switch err.(type) {
+	case *checksumError, *tarStreamError:
+		return true
+	}
+	return err == io.ErrUnexpectedEOF
+}
+
+// tarPipe reads the tar stream of remote files
+type tarPipe struct {
+	reader *io.PipeReader
+}
+
+func (o *CopyOptions) newTarPipe(src fileSpec) *tarPipe {
+	return o.newTarPipeFiles(src, []string{src.File})
+}
+
+// newTarPipeFiles reads a single tar stream of several files in the container of src
+func (o *CopyOptions) newTarPipeFiles(src fileSpec, files []string) *tarPipe {
+	reader, writer := io.Pipe()
+
+	// TODO: Improve error messages by first testing if 'tar' is present in the container?
+	command := append([]string{"tar", "cf", "-"}, files...)
+	go func() {
+		writer.CloseWithError(o.remoteExec(src, writer, o.Out, command...))
+	}()
+	return &tarPipe{reader: reader}
+}
+
+func (t *tarPipe) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if err != nil && err != io.EOF && !isExitError(err) {
+		err = &tarStreamError{err: err}
+	}
+	return n, err
+}
+
+// Close stops the remote tar if the stream was not read to the end
+func (t *tarPipe) Close() error {
+	return t.reader.Close()
+}
+
+// verifyFile compares the checksum of a file copied from src with the
+// checksum computed in the container
+func (o *CopyOptions) verifyFile(src fileSpec, localFile string) error {
+	remote, err := o.remoteChecksums(src, "sha256sum", src.File)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "warning: unable to verify the checksum of %s: %v\n", src.File, err)
+		return nil
+	}
+	local, err := fileChecksum(localFile)
+	if err != nil {
+		return err
+	}
+	if remote[src.File] != local {
+		return &checksumError{files: []string{src.File}}
+	}
+	return nil
+}
+
+// verifyDir compares the checksums of the files copied from the container of
+// src to destDir with the checksums computed in the container. rename maps the
+// remote files to the local ones like their tar entries.
+func (o *CopyOptions) verifyDir(src fileSpec, files []string, destDir string, rename tarRenamer) error {
+	command := append(append([]string{"find"}, files...), "-type", "f", "-exec", "sha256sum", "{}", "+")
+	remote, err := o.remoteChecksums(src, command...)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "warning: unable to verify the checksums of %s: %v\n", strings.Join(files, " "), err)
+		return nil
+	}
+
+	mismatched := []string{}
+	for remoteFile, checksum := range remote {
+		name, ok := rename(getPrefix(path.Clean(remoteFile)))
+		if !ok {
+			continue
+		}
+		localFile := filepath.Join(destDir, name)
+		if !isDestRelative(destDir, localFile) {
+			// files outside the destination are skipped by untarAll
+			continue
+		}
+		if local, err := fileChecksum(localFile); err != nil || local != checksum {
+			mismatched = append(mismatched, remoteFile)
+		}
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return &checksumError{files: mismatched}
+	}
+	return nil
+}
+
+// remoteChecksums runs a command printing sha256sum output in the container of
+// src and returns the checksums by file name
+func (o *CopyOptions) remoteChecksums(src fileSpec, command ...string) (map[string]string, error) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	if err := o.remoteExec(src, out, errOut, command...); err != nil {
+		if msg := strings.TrimSpace(errOut.String()); len(msg) > 0 {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// sha256sum escapes names containing a backslash or newline and marks
+		// the line with a leading backslash
+		escaped := strings.HasPrefix(line, `\`)
+		line = strings.TrimPrefix(line, `\`)
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected sha256sum output %q", line)
+		}
+		name := fields[1]
+		if escaped {
+			name = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+		}
+		checksums[name] = fields[0]
+	}
+	return checksums, scanner.Err()
+}
+
+func fileChecksum(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes written to writer
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
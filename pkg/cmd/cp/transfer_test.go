@@ -0,0 +1,407 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/kubernetes/fake"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/tools/remotecommand"
+	uexec "github.com/Angus-F/client-go/util/exec"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+// localExecutor runs the commands of a fake container on the local machine.
+// The first drops transfers are cut off after dropAfter bytes, with an error
+// unless silent is set.
+type localExecutor struct {
+	lock      sync.Mutex
+	commands  [][]string
+	hosts     []string
+	drops     int
+	dropAfter int
+	silent    bool
+	// corrupt replaces the output of the first transfer with garbage of the same size
+	corrupt bool
+	// missing are the commands missing from the container
+	missing map[string]bool
+}
+
+func (e *localExecutor) Execute(method string, url *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	command := url.Query()["command"]
+	e.lock.Lock()
+	e.commands = append(e.commands, command)
+	e.hosts = append(e.hosts, config.Host)
+	if e.missing[command[0]] {
+		e.lock.Unlock()
+		return uexec.CodeExitError{Err: errors.New("command terminated with exit code 126"), Code: 126}
+	}
+	if command[0] == "sh" {
+		for _, word := range strings.FieldsFunc(command[2], func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if e.missing[word] {
+				e.lock.Unlock()
+				return uexec.CodeExitError{Err: errors.New("command terminated with exit code 127"), Code: 127}
+			}
+		}
+	}
+	transfer := command[0] == "tail" || strings.Contains(strings.Join(command, " "), "tar cf")
+	drop := transfer && e.drops > 0
+	if drop {
+		e.drops--
+	}
+	corrupt := transfer && e.corrupt
+	if corrupt {
+		e.corrupt = false
+	}
+	e.lock.Unlock()
+
+	out := &bytes.Buffer{}
+	cmd := osexec.Command(command[0], command[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = out
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*osexec.ExitError); ok {
+		err = uexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code %d", exitErr.ExitCode()), Code: exitErr.ExitCode()}
+	}
+
+	data := out.Bytes()
+	if corrupt {
+		data = bytes.Repeat([]byte("x"), len(data))
+	}
+	if drop && len(data) > e.dropAfter {
+		stdout.Write(data[:e.dropAfter])
+		if e.silent {
+			return nil
+		}
+		return errors.New("stream reset")
+	}
+	stdout.Write(data)
+	return err
+}
+
+func (e *localExecutor) commandLines() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	lines := []string{}
+	for _, command := range e.commands {
+		lines = append(lines, strings.Join(command, " "))
+	}
+	return lines
+}
+
+func newTransferTestOptions(t *testing.T, executor *localExecutor) (*CopyOptions, *bytes.Buffer) {
+	if _, err := osexec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum is required to run the commands of the fake container locally")
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "test"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+	}
+	streams, _, _, errOut := genericclioptions.NewTestIOStreams()
+	o := NewCopyOptions(streams)
+	o.Namespace = "test"
+	o.Clientset = fake.NewSimpleClientset(pod)
+	o.ClientConfig = &restclient.Config{Host: "localhost", APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}}
+	o.Executor = executor
+	o.Verify = true
+	return o, errOut
+}
+
+func TestCopyFileFromPod(t *testing.T) {
+	tests := []struct {
+		name             string
+		executor         *localExecutor
+		retries          int
+		expectedErr      string
+		expectedTransfer []string
+	}{
+		{
+			name:             "complete",
+			executor:         &localExecutor{},
+			retries:          3,
+			expectedTransfer: []string{"+1"},
+		},
+		{
+			name:             "resume after a failed stream",
+			executor:         &localExecutor{drops: 2, dropAfter: 1000},
+			retries:          3,
+			expectedTransfer: []string{"+1", "+1001", "+2001"},
+		},
+		{
+			name:             "resume after a truncated stream",
+			executor:         &localExecutor{drops: 1, dropAfter: 1000, silent: true},
+			retries:          3,
+			expectedTransfer: []string{"+1", "+1001"},
+		},
+		{
+			name:             "restart after a checksum mismatch",
+			executor:         &localExecutor{corrupt: true},
+			retries:          3,
+			expectedTransfer: []string{"+1", "+1"},
+		},
+		{
+			name:        "out of retries",
+			executor:    &localExecutor{drops: 2, dropAfter: 1000},
+			retries:     1,
+			expectedErr: "stream reset",
+		},
+		{
+			name:        "checksum mismatch without retries",
+			executor:    &localExecutor{corrupt: true},
+			expectedErr: "checksum mismatch",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o, errOut := newTransferTestOptions(t, test.executor)
+			o.Retries = test.retries
+
+			dir, err := ioutil.TempDir("", "cp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			content := strings.Repeat("0123456789", 250)
+			remoteFile := filepath.Join(dir, "remote")
+			createTmpFile(t, remoteFile, content)
+			localFile := filepath.Join(dir, "local")
+
+			err = o.copyFromPod(fileSpec{PodName: "web-1", File: remoteFile}, fileSpec{File: localFile})
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+			}
+			cmpFileData(t, localFile, content)
+
+			transfers := []string{}
+			for _, command := range test.executor.commandLines() {
+				if strings.HasPrefix(command, "tail") {
+					transfers = append(transfers, strings.Fields(command)[2])
+				}
+			}
+			if strings.Join(transfers, " ") != strings.Join(test.expectedTransfer, " ") {
+				t.Errorf("expected transfers from offsets %v, got %v", test.expectedTransfer, transfers)
+			}
+		})
+	}
+}
+
+func TestCopyDirFromPod(t *testing.T) {
+	tests := []struct {
+		name              string
+		executor          *localExecutor
+		retries           int
+		expectedErr       string
+		expectedTransfers int
+	}{
+		{
+			name:              "complete",
+			executor:          &localExecutor{},
+			expectedTransfers: 1,
+		},
+		{
+			name:              "restart after a failed stream",
+			executor:          &localExecutor{drops: 1, dropAfter: 3000},
+			retries:           3,
+			expectedTransfers: 2,
+		},
+		{
+			name:              "restart after a truncated stream",
+			executor:          &localExecutor{drops: 1, dropAfter: 3000, silent: true},
+			retries:           3,
+			expectedTransfers: 2,
+		},
+		{
+			name:        "failed stream without retries",
+			executor:    &localExecutor{drops: 1, dropAfter: 3000},
+			expectedErr: "broken tar stream: stream reset",
+		},
+		{
+			name:        "truncated stream without retries",
+			executor:    &localExecutor{drops: 1, dropAfter: 3000, silent: true},
+			expectedErr: "unexpected EOF",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o, errOut := newTransferTestOptions(t, test.executor)
+			o.Retries = test.retries
+
+			dir, err := ioutil.TempDir("", "cp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			remoteDir := filepath.Join(dir, "remote")
+			files := map[string]string{
+				"a":        strings.Repeat("a", 2000),
+				"sub/b":    strings.Repeat("b", 2000),
+				"sub/c.go": "package c\n",
+			}
+			for name, content := range files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(remoteDir, name)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				createTmpFile(t, filepath.Join(remoteDir, name), content)
+			}
+			localDir := filepath.Join(dir, "local")
+
+			err = o.copyFromPod(fileSpec{PodName: "web-1", File: remoteDir}, fileSpec{File: localDir})
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+			}
+			for name, content := range files {
+				cmpFileData(t, filepath.Join(localDir, name), content)
+			}
+
+			// a directory is never resumed at an offset, every transfer is a whole tar stream
+			transfers := 0
+			for _, command := range test.executor.commandLines() {
+				if strings.Contains(command, "tail") {
+					t.Errorf("unexpected resumed transfer %q", command)
+				}
+				if strings.HasPrefix(command, "tar cf - ") {
+					transfers++
+				}
+			}
+			if transfers != test.expectedTransfers {
+				t.Errorf("expected %d transfers, got %d", test.expectedTransfers, transfers)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutSha256sum(t *testing.T) {
+	executor := &localExecutor{}
+	o, errOut := newTransferTestOptions(t, executor)
+	o.Executor = &renamingExecutor{executor: executor, from: "sha256sum", to: "sha256sum-missing"}
+
+	dir, err := ioutil.TempDir("", "cp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	remoteFile := filepath.Join(dir, "remote")
+	createTmpFile(t, remoteFile, "data")
+
+	if err := o.copyFromPod(fileSpec{PodName: "web-1", File: remoteFile}, fileSpec{File: filepath.Join(dir, "local")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(errOut.String(), "warning: unable to verify the checksum of "+remoteFile) {
+		t.Errorf("expected a warning, got %q", errOut.String())
+	}
+}
+
+// renamingExecutor replaces a command before running it
+type renamingExecutor struct {
+	executor *localExecutor
+	from, to string
+}
+
+func (e *renamingExecutor) Execute(method string, u *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	query := u.Query()
+	command := query["command"]
+	for i := range command {
+		if command[i] == e.from {
+			command[i] = e.to
+		}
+	}
+	query["command"] = command
+	renamed := *u
+	renamed.RawQuery = query.Encode()
+	return e.executor.Execute(method, &renamed, config, stdin, stdout, stderr, tty, terminalSizeQueue)
+}
+
+func TestProgressBar(t *testing.T) {
+	out := &bytes.Buffer{}
+	now := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	p := newProgressBar(out, 4*1024*1024)
+	p.start = now
+	p.now = func() time.Time { return now }
+
+	now = now.Add(time.Second)
+	p.Write(make([]byte, 1024*1024))
+	now = now.Add(time.Second)
+	p.Write(make([]byte, 1024*1024))
+	p.finish()
+
+	expected := fmt.Sprintf("\r[%s>%s] 1.0MiB / 4.0MiB  1.0MiB/s", strings.Repeat("=", 7), strings.Repeat(" ", 22)) +
+		fmt.Sprintf("\r[%s>%s] 2.0MiB / 4.0MiB  1.0MiB/s", strings.Repeat("=", 15), strings.Repeat(" ", 14)) +
+		fmt.Sprintf("\r[%s>%s] 2.0MiB / 4.0MiB  1.0MiB/s\n", strings.Repeat("=", 15), strings.Repeat(" ", 14))
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+
+	var disabled *progressBar
+	disabled.Write([]byte("ignored"))
+	disabled.finish()
+}
+
+func TestProgressBarFinishedWhileWriting(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newProgressBar(out, 0)
+	p.now = func() time.Time { return p.start.Add(time.Hour) }
+
+	// like a tar goroutine still writing when the copy fails
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			p.Write([]byte("data"))
+		}
+	}()
+	p.finish()
+	wg.Wait()
+	p.Write([]byte("data"))
+	p.finish()
+
+	if !strings.HasSuffix(out.String(), "\n") || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("expected nothing to be printed after the final line, got %q", out.String())
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	uexec "github.com/Angus-F/client-go/util/exec"
+	"github.com/Angus-F/kubectl/pkg/cmd/exec"
+)
+
+const (
+	transportAuto      = "auto"
+	transportTar       = "tar"
+	transportCat       = "cat"
+	transportBase64    = "base64"
+	transportEphemeral = "ephemeral"
+
+	// base64ChunkSize is the number of bytes copied to a container per exec by
+	// the base64 transport. Chunks are passed as arguments, which end up in the
+	// URL of the exec request.
+	base64ChunkSize = 32 * 1024
+)
+
+// copyTransport copies files between the local filesystem and a container
+// with the tools available in the container image
+type copyTransport interface {
+	// name identifies the transport in --transport and in error messages
+	name() string
+	// probe returns an error if the container of spec lacks the commands
+	// needed to copy to it, or from it if toPod is false
+	probe(o *CopyOptions, spec fileSpec, toPod bool) error
+	copyToPod(o *CopyOptions, src, dest fileSpec) error
+	copyFromPod(o *CopyOptions, src, dest fileSpec) error
+}
+
+// transports are the transports of --transport. tar is used by default, the
+// ones listed in fallbackTransports are probed in order if tar is missing.
+var (
+	transports = map[string]copyTransport{
+		transportTar:       tarTransport{},
+		transportCat:       catTransport{},
+		transportBase64:    base64Transport{},
+		transportEphemeral: ephemeralTransport{},
+	}
+	fallbackTransports = []copyTransport{catTransport{}, base64Transport{}}
+)
+
+// isCommandMissing returns true if err is the exit code a container runtime
+// or shell reports for a command that can't be found or executed
+func isCommandMissing(err error) bool {
+	var exitErr uexec.ExitError
+	return errors.As(err, &exitErr) && (exitErr.ExitStatus() == 126 || exitErr.ExitStatus() == 127)
+}
+
+// copyWithTransport copies between the local filesystem and a container with
+// --transport. In auto mode tar is tried first, and the fallback transports
+// are probed if tar or the tools used alongside it are missing.
+func (o *CopyOptions) copyWithTransport(src, dest fileSpec) error {
+	if o.Transport != transportAuto {
+		return transportCopy(transports[o.Transport], o, src, dest)
+	}
+
+	err := transportCopy(tarTransport{}, o, src, dest)
+	if !isCommandMissing(err) {
+		return err
+	}
+	tried := []string{err.Error()}
+
+	spec, toPod := src, false
+	if len(dest.PodName) != 0 {
+		spec, toPod = dest, true
+	}
+	for _, transport := range fallbackTransports {
+		if err := transport.probe(o, spec, toPod); err != nil {
+			if !isExitError(err) {
+				return err
+			}
+			tried = append(tried, fmt.Sprintf("%s transport: %v", transport.name(), err))
+			continue
+		}
+		fmt.Fprintf(o.ErrOut, "tar is not available in the container, copying with the %s transport\n", transport.name())
+		return transportCopy(transport, o, src, dest)
+	}
+	return fmt.Errorf("no copy transport is available in the container (%s), --transport=%s copies with a helper container", strings.Join(tried, "; "), transportEphemeral)
+}
+
+func transportCopy(transport copyTransport, o *CopyOptions, src, dest fileSpec) error {
+	var err error
+	if len(dest.PodName) != 0 {
+		err = transport.copyToPod(o, src, dest)
+	} else {
+		err = transport.copyFromPod(o, src, dest)
+	}
+	if err != nil {
+		return fmt.Errorf("%s transport: %w", transport.name(), err)
+	}
+	return nil
+}
+
+// tarTransport streams tar archives, resuming single files with tail
+type tarTransport struct{}
+
+func (tarTransport) name() string { return transportTar }
+
+func (tarTransport) probe(o *CopyOptions, spec fileSpec, toPod bool) error {
+	return o.remoteExec(spec, ioutil.Discard, ioutil.Discard, "tar", "cf", "-", "/dev/null")
+}
+
+func (tarTransport) copyToPod(o *CopyOptions, src, dest fileSpec) error {
+	return o.copyToPod(src, dest, &exec.ExecOptions{})
+}
+
+func (tarTransport) copyFromPod(o *CopyOptions, src, dest fileSpec) error {
+	return o.copyFromPod(src, dest)
+}
+
+// catTransport copies single files with cat, and shell redirection to write
+// them in the container
+type catTransport struct{}
+
+func (catTransport) name() string { return transportCat }
+
+func (catTransport) probe(o *CopyOptions, spec fileSpec, toPod bool) error {
+	if toPod {
+		return o.remoteExec(spec, ioutil.Discard, ioutil.Discard, "sh", "-c", "cat /dev/null")
+	}
+	return o.remoteExec(spec, ioutil.Discard, ioutil.Discard, "cat", "/dev/null")
+}
+
+func (t catTransport) copyToPod(o *CopyOptions, src, dest fileSpec) error {
+	file, dest, err := o.openSingleFile(t, src, dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	progress := o.newProgressBar(fileSize(file))
+	defer progress.finish()
+	return o.remoteExecWithStdin(dest, io.TeeReader(file, progress), o.Out, o.ErrOut, "sh", "-c", `cat > "$1"`, "sh", dest.File)
+}
+
+func (catTransport) copyFromPod(o *CopyOptions, src, dest fileSpec) error {
+	if len(src.File) == 0 || len(dest.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+	destFile := localDestFile(src, dest)
+	file, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	progress := o.newProgressBar(0)
+	defer progress.finish()
+	if err := o.remoteExec(src, io.MultiWriter(file, progress), o.ErrOut, "cat", src.File); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if o.Verify {
+		return o.verifyFile(src, destFile)
+	}
+	return nil
+}
+
+// base64Transport copies single files base64 encoded. Files are copied to a
+// container in chunks passed as arguments, without streaming stdin.
+type base64Transport struct{}
+
+func (base64Transport) name() string { return transportBase64 }
+
+func (base64Transport) probe(o *CopyOptions, spec fileSpec, toPod bool) error {
+	if toPod {
+		return o.remoteExec(spec, ioutil.Discard, ioutil.Discard, "sh", "-c", "base64 -d < /dev/null")
+	}
+	return o.remoteExec(spec, ioutil.Discard, ioutil.Discard, "base64", "/dev/null")
+}
+
+func (t base64Transport) copyToPod(o *CopyOptions, src, dest fileSpec) error {
+	file, dest, err := o.openSingleFile(t, src, dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	progress := o.newProgressBar(fileSize(file))
+	defer progress.finish()
+	chunk := make([]byte, base64ChunkSize)
+	redirect := ">"
+	for {
+		n, err := io.ReadFull(file, chunk)
+		if err == io.EOF && redirect == ">>" {
+			return nil
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		encoded := base64.StdEncoding.EncodeToString(chunk[:n])
+		script := fmt.Sprintf(`printf %%s "$1" | base64 -d %s "$2"`, redirect)
+		if err := o.remoteExec(dest, o.Out, o.ErrOut, "sh", "-c", script, "sh", encoded, dest.File); err != nil {
+			return err
+		}
+		progress.Write(chunk[:n])
+		if n < len(chunk) {
+			return nil
+		}
+		redirect = ">>"
+	}
+}
+
+func (base64Transport) copyFromPod(o *CopyOptions, src, dest fileSpec) error {
+	if len(src.File) == 0 || len(dest.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+	destFile := localDestFile(src, dest)
+	file, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(o.remoteExec(src, writer, o.ErrOut, "base64", src.File))
+	}()
+	defer reader.Close()
+
+	progress := o.newProgressBar(0)
+	defer progress.finish()
+	if _, err := io.Copy(io.MultiWriter(file, progress), base64.NewDecoder(base64.StdEncoding, reader)); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if o.Verify {
+		return o.verifyFile(src, destFile)
+	}
+	return nil
+}
+
+// openSingleFile opens the local file copied by a transport that can't copy
+// directories, and returns the remote dest it is copied to
+func (o *CopyOptions) openSingleFile(transport copyTransport, src, dest fileSpec) (*os.File, fileSpec, error) {
+	if len(src.File) == 0 || len(dest.File) == 0 {
+		return nil, dest, errFileCannotBeEmpty
+	}
+	stat, err := os.Stat(src.File)
+	if err != nil {
+		return nil, dest, fmt.Errorf("%s doesn't exist in local filesystem", src.File)
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, dest, fmt.Errorf("only regular files can be copied with the %s transport, %s is not one", transport.name(), src.File)
+	}
+
+	// strip trailing slash (if any)
+	if dest.File != "/" && strings.HasSuffix(dest.File, "/") {
+		dest.File = dest.File[:len(dest.File)-1]
+	}
+	// test is a shell builtin, unlike in checkDestinationIsDir
+	if err := o.remoteExec(dest, ioutil.Discard, ioutil.Discard, "sh", "-c", `test -d "$1"`, "sh", dest.File); err == nil {
+		dest.File = dest.File + "/" + path.Base(src.File)
+	}
+
+	file, err := os.Open(src.File)
+	return file, dest, err
+}
+
+func fileSize(file *os.File) int64 {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
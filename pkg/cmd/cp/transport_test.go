@@ -0,0 +1,213 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/client-go/kubernetes/fake"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCopyWithTransport(t *testing.T) {
+	content := strings.Repeat("0123456789", 5000)
+	tests := []struct {
+		name              string
+		transport         string
+		missing           []string
+		toPod             bool
+		expectedTransport string
+		expectedErr       []string
+	}{
+		{
+			name:              "tar from pod",
+			transport:         transportAuto,
+			expectedTransport: "tail",
+		},
+		{
+			name:              "cat from pod without tar",
+			transport:         transportAuto,
+			missing:           []string{"sh", "tar"},
+			expectedTransport: "cat",
+		},
+		{
+			name:              "base64 from pod without tar and cat",
+			transport:         transportAuto,
+			missing:           []string{"sh", "tar", "cat"},
+			expectedTransport: "base64",
+		},
+		{
+			name:              "tar to pod",
+			transport:         transportAuto,
+			toPod:             true,
+			expectedTransport: "tar",
+		},
+		{
+			name:              "cat to pod without tar",
+			transport:         transportAuto,
+			missing:           []string{"tar"},
+			toPod:             true,
+			expectedTransport: "sh",
+		},
+		{
+			name:              "base64 to pod",
+			transport:         transportBase64,
+			toPod:             true,
+			expectedTransport: "sh",
+		},
+		{
+			name:      "nothing available",
+			transport: transportAuto,
+			missing:   []string{"sh", "tar", "cat", "base64"},
+			expectedErr: []string{
+				"tar transport: command terminated with exit code 126",
+				"cat transport: command terminated with exit code 126",
+				"base64 transport: command terminated with exit code 126",
+				"--transport=ephemeral",
+			},
+		},
+		{
+			name:        "explicit transport",
+			transport:   transportCat,
+			missing:     []string{"cat"},
+			expectedErr: []string{"cat transport: command terminated with exit code 126"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executor := &localExecutor{missing: map[string]bool{}}
+			for _, command := range test.missing {
+				executor.missing[command] = true
+			}
+			o, errOut := newTransferTestOptions(t, executor)
+			o.Transport = test.transport
+
+			dir, err := ioutil.TempDir("", "cp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.Mkdir(filepath.Join(dir, "dest"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			createTmpFile(t, filepath.Join(dir, "file"), content)
+
+			src := fileSpec{PodName: "web-1", File: filepath.Join(dir, "file")}
+			dest := fileSpec{File: filepath.Join(dir, "dest")}
+			if test.toPod {
+				src.PodName, dest.PodName = "", "web-1"
+			}
+			err = o.copyWithTransport(src, dest)
+			if len(test.expectedErr) > 0 {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				for _, expected := range test.expectedErr {
+					if !strings.Contains(err.Error(), expected) {
+						t.Errorf("expected error %q to contain %q", err.Error(), expected)
+					}
+				}
+				// the ephemeral transport changes the pod, it is never a fallback
+				for _, action := range o.Clientset.(*fake.Clientset).Actions() {
+					if action.GetVerb() == "patch" || action.GetVerb() == "update" {
+						t.Errorf("unexpected change to the pod: %v", action)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, errOut.String())
+			}
+			cmpFileData(t, filepath.Join(dir, "dest", "file"), content)
+
+			commands := executor.commandLines()
+			last := strings.Fields(commands[len(commands)-1])[0]
+			if o.Verify && !test.toPod {
+				// the checksum is verified after copying
+				last = strings.Fields(commands[len(commands)-2])[0]
+			}
+			if last != test.expectedTransport {
+				t.Errorf("expected to copy with %s, got %v", test.expectedTransport, commands)
+			}
+		})
+	}
+}
+
+func TestBase64TransportChunks(t *testing.T) {
+	executor := &localExecutor{}
+	o, errOut := newTransferTestOptions(t, executor)
+	o.Transport = transportBase64
+
+	dir, err := ioutil.TempDir("", "cp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, size := range map[string]int{"empty": 0, "exact": 2 * base64ChunkSize, "partial": base64ChunkSize + 10} {
+		content := strings.Repeat("x", size)
+		createTmpFile(t, filepath.Join(dir, name), content)
+		dest := filepath.Join(dir, name+".copy")
+		if err := o.copyWithTransport(fileSpec{File: filepath.Join(dir, name)}, fileSpec{PodName: "web-1", File: dest}); err != nil {
+			t.Fatalf("%s: unexpected error: %v\n%s", name, err, errOut.String())
+		}
+		cmpFileData(t, dest, content)
+	}
+
+	if err := o.copyWithTransport(fileSpec{File: dir}, fileSpec{PodName: "web-1", File: "/tmp"}); err == nil || !strings.Contains(err.Error(), "only regular files can be copied with the base64 transport") {
+		t.Errorf("expected directories to be rejected, got %v", err)
+	}
+}
+
+func TestHelperContainer(t *testing.T) {
+	if spec := helperFileSpec(fileSpec{File: "/var/data/"}); spec.File != "/proc/1/root/var/data/" {
+		t.Errorf("unexpected helper path %s", spec.File)
+	}
+	if spec := helperFileSpec(fileSpec{File: "data"}); spec.File != "/proc/1/root/data" {
+		t.Errorf("unexpected helper path %s", spec.File)
+	}
+
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "kesctl-cp-stopped", Image: defaultHelperImage}, TargetContainerName: "app"},
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger", Image: defaultHelperImage}, TargetContainerName: "app"},
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "kesctl-cp-other", Image: "alpine"}, TargetContainerName: "app"},
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "kesctl-cp-sidecar", Image: defaultHelperImage}, TargetContainerName: "sidecar"},
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "kesctl-cp-running", Image: defaultHelperImage}, TargetContainerName: "app"},
+			},
+		},
+		Status: corev1.PodStatus{
+			EphemeralContainerStatuses: []corev1.ContainerStatus{
+				{Name: "kesctl-cp-stopped", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+				{Name: "debugger", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "kesctl-cp-other", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "kesctl-cp-sidecar", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "kesctl-cp-running", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			},
+		},
+	}
+	if name := runningHelper(pod, "app", defaultHelperImage); name != "kesctl-cp-running" {
+		t.Errorf("expected to reuse kesctl-cp-running, got %q", name)
+	}
+	if name := runningHelper(pod, "app", "debian"); name != "" {
+		t.Errorf("expected no helper for another image, got %q", name)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"errors"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+	"github.com/Angus-F/kubectl/pkg/util/interrupt"
+	"github.com/Angus-F/kubectl/pkg/util/term"
+)
+
+// errInterrupted is recorded for the sessions ended by a signal
+var errInterrupted = errors.New("interrupted")
+
+// auditedSession logs a session to the audit log once it ends, counting the
+// bytes sent to and received from the container
+type auditedSession struct {
+	logger audit.Logger
+	// warnings is where a failure to write the audit log is reported
+	warnings io.Writer
+	entry    *audit.Entry
+
+	in     *audit.CountingReader
+	out    *audit.CountingWriter
+	errOut *audit.CountingWriter
+}
+
+func (p *ExecOptions) startAudit(pod *corev1.Pod, containerName string) *auditedSession {
+	entry := audit.NewEntry("exec", p.ClusterName, pod.Namespace)
+	entry.Pod, entry.Container, entry.Command = pod.Name, containerName, p.Command
+	return &auditedSession{logger: p.Auditor, warnings: p.ErrOut, entry: entry}
+}
+
+// wrap returns the streams of the session, counting what goes through them.
+// Missing streams stay nil.
+func (s *auditedSession) wrap(in io.Reader, out, errOut io.Writer) (io.Reader, io.Writer, io.Writer) {
+	if in != nil {
+		s.in = &audit.CountingReader{Reader: in}
+		in = s.in
+	}
+	if out != nil {
+		s.out = &audit.CountingWriter{Writer: out}
+		out = s.out
+	}
+	if errOut != nil {
+		s.errOut = &audit.CountingWriter{Writer: errOut}
+		errOut = s.errOut
+	}
+	return in, out, errOut
+}
+
+// run runs fn in the terminal t and logs the session when fn returns, or when
+// the process is interrupted
+func (s *auditedSession) run(t term.TTY, fn term.SafeFunc) error {
+	err := errInterrupted
+	handler := interrupt.Chain(t.Parent, func() { s.finish(err) })
+	t.Parent = handler
+	return handler.Run(func() error {
+		err = t.Safe(fn)
+		return err
+	})
+}
+
+func (s *auditedSession) finish(err error) {
+	s.entry.BytesIn = s.in.Count()
+	s.entry.BytesOut = s.out.Count() + s.errOut.Count()
+	s.entry.Finish(err)
+	audit.Record(s.logger, s.warnings, s.entry)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	kubefake "github.com/Angus-F/client-go/kubernetes/fake"
+	restclient "github.com/Angus-F/client-go/rest"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+type fakeAuditor struct {
+	entries []*audit.Entry
+	err     error
+}
+
+func (a *fakeAuditor) Log(entry *audit.Entry) error {
+	a.entries = append(a.entries, entry)
+	return a.err
+}
+
+func TestExecAudit(t *testing.T) {
+	tests := []struct {
+		name            string
+		executor        RemoteExecutor
+		auditErr        error
+		expectedErr     string
+		expectedExit    int
+		expectedWarning string
+	}{
+		{
+			name:     "success",
+			executor: outputExecutor{},
+		},
+		{
+			name:         "remote failure",
+			executor:     &podRecordingExecutor{exitCodes: map[string]int{"foo": 2}},
+			expectedErr:  "command terminated with exit code 2",
+			expectedExit: 2,
+		},
+		{
+			name:            "broken audit log",
+			executor:        outputExecutor{},
+			auditErr:        errors.New("disk full"),
+			expectedWarning: "warning: unable to write the audit log: disk full",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auditor := &fakeAuditor{err: test.auditErr}
+			streams, in, out, errOut := genericclioptions.NewTestIOStreams()
+			in.WriteString("exit\n")
+			options := &ExecOptions{
+				StreamOptions: StreamOptions{
+					Namespace: "test",
+					PodName:   "foo",
+					Stdin:     true,
+					IOStreams: streams,
+				},
+				ClusterName: "dev",
+				Command:     []string{"sh", "-c", "cat"},
+				Executor:    test.executor,
+				Auditor:     auditor,
+				Config:      &restclient.Config{APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}},
+				PodClient:   kubefake.NewSimpleClientset(execPod()).CoreV1(),
+			}
+			err := options.Run()
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(auditor.entries) != 1 {
+				t.Fatalf("expected one audit entry, got %d", len(auditor.entries))
+			}
+			entry := auditor.entries[0]
+			if entry.Action != "exec" || entry.ClusterName != "dev" || entry.Namespace != "test" || entry.Pod != "foo" || entry.Container != "bar" {
+				t.Errorf("unexpected entry %#v", entry)
+			}
+			if strings.Join(entry.Command, " ") != "sh -c cat" || entry.ExitCode != test.expectedExit {
+				t.Errorf("unexpected command or exit code in %#v", entry)
+			}
+			if entry.BytesOut != int64(out.Len()) {
+				t.Errorf("expected %d bytes received, got %d", out.Len(), entry.BytesOut)
+			}
+			if _, ok := test.executor.(outputExecutor); ok && entry.BytesIn != int64(len("exit\n")) {
+				t.Errorf("expected the stdin to be counted, got %d", entry.BytesIn)
+			}
+			if !strings.Contains(errOut.String(), test.expectedWarning) {
+				t.Errorf("expected warning %q, got %q", test.expectedWarning, errOut.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Angus-F/cli-runtime/pkg/printers"
+	uexec "github.com/Angus-F/client-go/util/exec"
+)
+
+// execResult is the outcome of running the command in one cluster or pod
+type execResult struct {
+	name     string
+	exitCode int
+	err      error
+}
+
+// runInClusters runs the command in every cluster target, at most MaxParallel at
+// a time. Output lines are prefixed with the cluster name and a summary of exit
+// codes is written to ErrOut once all clusters are done.
+func (p *ExecOptions) runInClusters() error {
+	return p.runInTargets("cluster", p.clusterTargets,
+		func(target *ExecOptions) string { return target.ClusterName },
+		func(target *ExecOptions) error { return target.Run() })
+}
+
+// runInTargets runs run for every target, at most MaxParallel at a time, with
+// output lines prefixed with kind and the name of the target. A summary of
+// exit codes is written to ErrOut once all targets are done.
+func (p *ExecOptions) runInTargets(kind string, targets []*ExecOptions, name func(*ExecOptions) string, run func(*ExecOptions) error) error {
+	out := &lockedWriter{writer: p.Out}
+	errOut := &lockedWriter{writer: p.ErrOut}
+
+	results := make([]execResult, len(targets))
+	sem := make(chan struct{}, p.MaxParallel)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(targets))
+	for i, target := range targets {
+		go func(i int, target *ExecOptions) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if p.Output == outputJSON {
+				// every result is written as a single line
+				target.Out, target.ErrOut = out, errOut
+				results[i] = newExecResult(name(target), run(target))
+				return
+			}
+			prefix := []byte(fmt.Sprintf("[%s/%s] ", kind, name(target)))
+			stdout := &linePrefixWriter{prefix: prefix, writer: out}
+			stderr := &linePrefixWriter{prefix: prefix, writer: errOut}
+			target.Out, target.ErrOut = stdout, stderr
+
+			err := run(target)
+			stdout.Flush()
+			stderr.Flush()
+			results[i] = newExecResult(name(target), err)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return p.printSummary(kind, results)
+}
+
+func newExecResult(name string, err error) execResult {
+	return execResult{name: name, exitCode: exitCode(err), err: err}
+}
+
+// printSummary prints one line per cluster or pod, unless the results were
+// printed as JSON, and returns an exit error with the highest remote exit
+// code if the command failed anywhere.
+func (p *ExecOptions) printSummary(kind string, results []execResult) error {
+	if p.Output != outputJSON {
+		w := printers.GetNewTabWriter(p.ErrOut)
+		fmt.Fprintf(w, "%s\tEXIT CODE\tERROR\n", strings.ToUpper(kind))
+		for _, result := range results {
+			switch {
+			case result.err == nil:
+				fmt.Fprintf(w, "%s\t0\t\n", result.name)
+			case result.exitCode < 0:
+				fmt.Fprintf(w, "%s\t-\t%v\n", result.name, result.err)
+			default:
+				fmt.Fprintf(w, "%s\t%d\t%v\n", result.name, result.exitCode, result.err)
+			}
+		}
+		w.Flush()
+	}
+
+	failed, exitCode := 0, 0
+	for _, result := range results {
+		if result.err == nil {
+			continue
+		}
+		failed++
+		if result.exitCode > exitCode {
+			exitCode = result.exitCode
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	err := fmt.Errorf("command failed in %d of %d %ss", failed, len(results), kind)
+	if exitCode > 0 {
+		return exitError(uexec.CodeExitError{Err: err, Code: exitCode}, p.Output == outputJSON)
+	}
+	return err
+}
+
+// lockedWriter serializes writes of concurrent producers
+type lockedWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Write(p)
+}
+
+// linePrefixWriter buffers output until a newline and writes every complete line
+// with a prefix in a single call, so lines of concurrent writers don't interleave.
+type linePrefixWriter struct {
+	prefix []byte
+	writer io.Writer
+	buf    []byte
+}
+
+func (w *linePrefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes a trailing line that was not terminated by a newline.
+func (w *linePrefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *linePrefixWriter) writeLine(line []byte) error {
+	out := make([]byte, 0, len(w.prefix)+len(line))
+	out = append(out, w.prefix...)
+	out = append(out, line...)
+	_, err := w.writer.Write(out)
+	return err
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/rest/fake"
+	"github.com/Angus-F/client-go/tools/remotecommand"
+	uexec "github.com/Angus-F/client-go/util/exec"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+// fakeOutputExecutor writes output and returns execErr on every call
+type fakeOutputExecutor struct {
+	lock    sync.Mutex
+	calls   int
+	stdout  string
+	execErr error
+}
+
+func (f *fakeOutputExecutor) Execute(method string, url *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	f.lock.Lock()
+	f.calls++
+	f.lock.Unlock()
+	stdout.Write([]byte(f.stdout))
+	return f.execErr
+}
+
+func TestExecInClusters(t *testing.T) {
+	tests := []struct {
+		name             string
+		clusterName      string
+		allClusters      bool
+		execErr          error
+		expectedClusters []string
+		expectedExitCode int
+		expectedErr      string
+	}{
+		{
+			name:             "glob",
+			clusterName:      "prod-*",
+			expectedClusters: []string{"prod-east", "prod-west"},
+		},
+		{
+			name:             "list",
+			clusterName:      "dev,prod-east",
+			expectedClusters: []string{"dev", "prod-east"},
+		},
+		{
+			name:             "all clusters",
+			allClusters:      true,
+			expectedClusters: []string{"dev", "prod-east", "prod-west"},
+		},
+		{
+			name:             "remote failure",
+			clusterName:      "prod-*",
+			execErr:          uexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 3"), Code: 3},
+			expectedClusters: []string{"prod-east", "prod-west"},
+			expectedExitCode: 3,
+			expectedErr:      "command failed in 2 of 2 clusters",
+		},
+		{
+			name:        "no match",
+			clusterName: "staging-*",
+			expectedErr: `no cluster matches the clusterName pattern "staging-*"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.ClusterNamesVal = []string{"dev", "prod-east", "prod-west"}
+
+			codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+			tf.Client = &fake.RESTClient{
+				GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					if req.URL.Path == "/api/v1/namespaces/test/pods/foo" && req.Method == "GET" {
+						return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, execPod())}, nil
+					}
+					t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+					return nil, fmt.Errorf("unexpected request")
+				}),
+			}
+			tf.ClientConfigVal = &restclient.Config{APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}}
+
+			ex := &fakeOutputExecutor{stdout: "line one\nline two", execErr: test.execErr}
+			streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+			options := &ExecOptions{
+				StreamOptions: StreamOptions{
+					PodName:   "foo",
+					IOStreams: streams,
+				},
+				ClusterName: test.clusterName,
+				AllClusters: test.allClusters,
+				MaxParallel: 2,
+				Executor:    ex,
+			}
+			cmd := NewCmdExec(tf, streams)
+			err := options.Complete(tf, cmd, []string{"foo", "date"}, 1)
+			if err == nil {
+				err = options.Validate()
+			}
+			if err == nil {
+				err = options.Run()
+			}
+
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedExitCode > 0 {
+				exitErr, ok := err.(uexec.ExitError)
+				if !ok || exitErr.ExitStatus() != test.expectedExitCode {
+					t.Errorf("expected exit code %d, got %v", test.expectedExitCode, err)
+				}
+			}
+			if ex.calls != len(test.expectedClusters) {
+				t.Errorf("expected %d executions, got %d", len(test.expectedClusters), ex.calls)
+			}
+
+			expectedLines := []string{}
+			for _, cluster := range test.expectedClusters {
+				expectedLines = append(expectedLines,
+					fmt.Sprintf("[cluster/%s] line one", cluster),
+					fmt.Sprintf("[cluster/%s] line two", cluster))
+				if !strings.Contains(errOut.String(), cluster) {
+					t.Errorf("expected summary for cluster %s, got %q", cluster, errOut.String())
+				}
+			}
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(expectedLines) == 0 {
+				lines = []string{}
+			}
+			sort.Strings(lines)
+			sort.Strings(expectedLines)
+			if strings.Join(lines, "\n") != strings.Join(expectedLines, "\n") {
+				t.Errorf("expected output:\n%s\ngot:\n%s", strings.Join(expectedLines, "\n"), out.String())
+			}
+		})
+	}
+}
+
+func TestExecInClustersRejectsTTY(t *testing.T) {
+	options := &ExecOptions{
+		StreamOptions: StreamOptions{
+			PodName:   "foo",
+			Stdin:     true,
+			IOStreams: genericclioptions.NewTestIOStreamsDiscard(),
+		},
+		Command:        []string{"sh"},
+		MaxParallel:    1,
+		clusterTargets: []*ExecOptions{{}, {}},
+	}
+	if err := options.Validate(); err == nil || !strings.Contains(err.Error(), "-i and -t") {
+		t.Errorf("expected stdin to be rejected for several clusters, got %v", err)
+	}
+}
+
+func TestLinePrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := &linePrefixWriter{prefix: []byte("[p] "), writer: out}
+	for _, chunk := range []string{"a", "b\nc\n", "", "d\ne"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[p] ab\n[p] c\n[p] d\n[p] e\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
@@ -17,11 +17,14 @@ limitations under the License.
 package exec
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	clientcmdapi "github.com/Angus-F/client-go/tools/clientcmd/api"
 	"io"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
@@ -33,16 +36,19 @@ import (
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
+	"github.com/Angus-F/kubectl/pkg/audit"
 	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
 	"github.com/Angus-F/kubectl/pkg/cmd/util/podcmd"
 	"github.com/Angus-F/kubectl/pkg/polymorphichelpers"
 	"github.com/Angus-F/kubectl/pkg/scheme"
+	"github.com/Angus-F/kubectl/pkg/util/completion"
 	"github.com/Angus-F/kubectl/pkg/util/i18n"
 	"github.com/Angus-F/kubectl/pkg/util/interrupt"
 	"github.com/Angus-F/kubectl/pkg/util/templates"
 	"github.com/Angus-F/kubectl/pkg/util/term"
-	"github.com/Angus-F/kubectl/pkg/configs"
 )
 
 var (
@@ -71,12 +77,32 @@ var (
 
 		# Get output from running 'date' command from the first pod of the service myservice, using the first container by default
 		kubectl exec svc/myservice -- date
+
+		# Record an interactive session in pod mypod to session.cast, and play it back
+		kubectl exec mypod -i -t --record session.cast -- bash -il
+		kubectl replay session.cast
+
+		# Get the output, exit code and duration of 'date' in pod mypod as JSON
+		kubectl exec mypod -o json -- date
+
+		# Get output from running 'date' command from the ready pod labeled app=web with the fewest restarts
+		kubectl exec -l app=web --pick=least-restarts -- date
+
+		# Get output from running 'date' command from every ready pod of the deployment mydeployment
+		kubectl exec deploy/mydeployment --pick=all -- date
+
+		# Get output from running 'date' command from pod mypod in every cluster whose name starts with prod-
+		kubectl exec mypod -C 'prod-*' -- date
+
+		# Get output from running 'date' command from pod mypod in every registered cluster, 10 clusters at a time
+		kubectl exec mypod --all-clusters --max-parallel=10 -- date
 		`))
 )
 
 
 const (
 	defaultPodExecTimeout = 60 * time.Second
+	defaultMaxParallel    = 5
 )
 
 func NewCmdExec(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
@@ -84,14 +110,17 @@ func NewCmdExec(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 		StreamOptions: StreamOptions{
 			IOStreams: streams,
 		},
-		Executor: &DefaultRemoteExecutor{},
+		Executor:    &DefaultRemoteExecutor{},
+		MaxParallel: defaultMaxParallel,
+		Auditor:     audit.NewDefaultLogger(),
 	}
 	cmd := &cobra.Command{
-		Use:                   "exec (POD | TYPE/NAME) [-c CONTAINER] [-C CLUSTER] [flags] -- COMMAND [args...]",
+		Use:                   "exec (POD | TYPE/NAME | -l SELECTOR) [-c CONTAINER] [-C CLUSTER] [flags] -- COMMAND [args...]",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Execute a command in a container"),
 		Long:                  i18n.T("Execute a command in a container."),
 		Example:               execExample,
+		ValidArgsFunction:     completion.PodNameCompletionFunc(f, configs.CommandExec),
 		Run: func(cmd *cobra.Command, args []string) {
 			argsLenAtDash := cmd.ArgsLenAtDash()
 			cmdutil.CheckErr(options.Complete(f, cmd, args, argsLenAtDash))
@@ -103,10 +132,16 @@ func NewCmdExec(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 	cmdutil.AddJsonFilenameFlag(cmd.Flags(), &options.FilenameOptions.Filenames, "to use to exec into the resource")
 	// TODO support UID
 	cmdutil.AddContainerVarFlags(cmd, &options.ContainerName, options.ContainerName)
-	cmdutil.AddClusterVarFlags(cmd, &options.ClusterName, options.ClusterName)
+	cmdutil.AddClusterListVarFlags(cmd, &options.ClusterName, options.ClusterName, &options.AllClusters)
+	cmd.Flags().IntVar(&options.MaxParallel, "max-parallel", options.MaxParallel, "Maximum number of clusters to execute the command in concurrently when several clusters are selected")
 	cmd.Flags().BoolVarP(&options.Stdin, "stdin", "i", options.Stdin, "Pass stdin to the container")
 	cmd.Flags().BoolVarP(&options.TTY, "tty", "t", options.TTY, "Stdin is a TTY")
 	cmd.Flags().BoolVarP(&options.Quiet, "quiet", "q", options.Quiet, "Only print output from the remote session")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", options.Selector, "Selector (label query) of the pods to execute the command in, instead of a pod or TYPE/NAME")
+	cmd.Flags().StringVar(&options.Record, "record", options.Record, "Record the session, its input, output and terminal resizes, to this file in the asciicast v2 format. Play it back with 'kubectl replay'.")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", options.Output, "Output format. One of: json. json prints the stdout, stderr, exit code and duration of the command as a JSON object instead of streaming its output, one per line when executing in several clusters or pods.")
+	cmd.Flags().StringVar(&options.Pick, "pick", options.Pick, "How to choose among the ready pods of --selector or TYPE/NAME, one of: "+strings.Join(pickPolicies, "|")+". all executes the command in every pod, prefixing the output lines with the pod names. Defaults to first-ready with --selector.")
+	cmdutil.CheckErr(cmd.RegisterFlagCompletionFunc("container", completion.ContainerCompletionFunc(f, configs.CommandExec)))
 	return cmd
 }
 
@@ -156,10 +191,23 @@ type ExecOptions struct {
 	resource.FilenameOptions
 
 	ClusterName      string
+	AllClusters      bool
+	MaxParallel      int
 	ResourceName     string
 	Command          []string
 	EnforceNamespace bool
 
+	// Selector selects the pods to pick from instead of a pod or TYPE/NAME
+	Selector string
+	// Pick is the policy choosing among the ready pods of Selector or TYPE/NAME
+	Pick string
+	// Record is the file the session is recorded to in the asciicast v2 format
+	Record string
+	// Output is json to print the output and exit code of the command as JSON
+	Output string
+	// Auditor, if set, logs every execution to the audit log
+	Auditor audit.Logger
+
 	Builder          func() *resource.Builder
 	ExecutablePodFn  polymorphichelpers.AttachablePodForObjectFunc
 	restClientGetter genericclioptions.RESTClientGetter
@@ -169,7 +217,12 @@ type ExecOptions struct {
 	PodClient     coreclient.PodsGetter
 	GetPodTimeout time.Duration
 	Config        *restclient.Config
-	Configs map[string]string
+
+	// clusterTargets holds one completed ExecOptions per cluster when
+	// several clusters were selected
+	clusterTargets []*ExecOptions
+	// randIntn picks a pod with --pick=random
+	randIntn func(n int) int
 }
 
 // Complete verifies command line arguments and loads data from the command environment
@@ -192,54 +245,52 @@ func (p *ExecOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, argsIn []s
 		p.ResourceName = ""
 	}
 
-	p.Configs = make(map[string]string)
-	if len(configs.ClusterName) != len(configs.ConfigContent) {
-		return fmt.Errorf("the numbers of ClusterName and the ConfigContent is unmatched")
-	}
-	if len(configs.ClusterName) == 0 || len(configs.ConfigContent) == 0 {
-		return fmt.Errorf("fail to find configs to set")
+	var err error
+	p.ExecutablePodFn = polymorphichelpers.AttachablePodForObjectFn
+	p.GetPodTimeout, err = cmdutil.GetPodRunningTimeoutFlag(cmd)
+	if err != nil {
+		return cmdutil.UsageErrorf(cmd, err.Error())
 	}
 
-	for i := 0; i < len(configs.ClusterName); i++ {
-		p.Configs[configs.ClusterName[i]] = configs.ConfigContent[i]
+	if !p.AllClusters && !cmdutil.IsClusterList(p.ClusterName) {
+		return p.completeCluster(f, p.ClusterName)
 	}
 
-	if len(p.ClusterName) > 0 {
-		flag := false
-		for filename := range p.Configs {
-			if filename == p.ClusterName {
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			return fmt.Errorf("the clusterName can not be found")
+	clusterNames, err := cmdutil.ResolveClusterNames(f, p.ClusterName, p.AllClusters)
+	if err != nil {
+		return err
+	}
+	p.clusterTargets = make([]*ExecOptions, 0, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		target := *p
+		target.clusterTargets = nil
+		if err := target.completeCluster(f, clusterName); err != nil {
+			return fmt.Errorf("cluster %s: %v", clusterName, err)
 		}
-	} else {
-		return fmt.Errorf("Please set the clusterName")
+		p.clusterTargets = append(p.clusterTargets, &target)
 	}
+	return nil
+}
 
-	ClientConfig, _ := f.NewClientConfigFromBytesWithConfigFlags([]byte(p.Configs[p.ClusterName]))
-	f.SetClientConfig(&ClientConfig)
-	var err error
-	p.Namespace, p.EnforceNamespace, err = f.ToRawKubeConfigLoader().Namespace()
+// completeCluster binds the options to the clients of a single cluster
+func (p *ExecOptions) completeCluster(f cmdutil.Factory, clusterName string) error {
+	client, err := f.ClientForCluster(clusterName)
 	if err != nil {
 		return err
 	}
-	p.ExecutablePodFn = polymorphichelpers.AttachablePodForObjectFn
-
-	p.GetPodTimeout, err = cmdutil.GetPodRunningTimeoutFlag(cmd)
-	if err != nil {
-		return cmdutil.UsageErrorf(cmd, err.Error())
+	p.ClusterName = client.Name
+	p.Namespace, p.EnforceNamespace = client.Namespace, client.EnforceNamespace
+	if err := client.CheckAccess(configs.Access{Command: configs.CommandExec, Namespace: p.Namespace, TTY: p.Stdin && p.TTY}); err != nil {
+		return err
 	}
-	p.Builder = f.NewBuilder
-	p.restClientGetter = f
+	p.Builder = client.NewBuilder
+	p.restClientGetter = client
 
-	p.Config, err = f.ToRESTConfig()
+	p.Config, err = client.ToRESTConfig()
 	if err != nil {
 		return err
 	}
-	clientset, err := f.KubernetesClientSet()
+	clientset, err := client.KubernetesClientSet()
 	if err != nil {
 		return err
 	}
@@ -249,8 +300,26 @@ func (p *ExecOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, argsIn []s
 
 // Validate checks that the provided exec options are specified.
 func (p *ExecOptions) Validate() error {
-	if len(p.PodName) == 0 && len(p.ResourceName) == 0 && len(p.FilenameOptions.Filenames) == 0 {
-		return fmt.Errorf("pod, type/name or --filename must be specified")
+	if len(p.PodName) == 0 && len(p.ResourceName) == 0 && len(p.FilenameOptions.Filenames) == 0 && len(p.Selector) == 0 {
+		return fmt.Errorf("pod, type/name, --selector or --filename must be specified")
+	}
+	if len(p.Selector) > 0 && (len(p.ResourceName) > 0 || len(p.FilenameOptions.Filenames) > 0) {
+		return fmt.Errorf("--selector can not be used with a pod, type/name or --filename")
+	}
+	if len(p.Pick) > 0 && !isPickPolicy(p.Pick) {
+		return fmt.Errorf("--pick must be one of %s, got %q", strings.Join(pickPolicies, "|"), p.Pick)
+	}
+	if p.Pick == pickAll && (p.Stdin || p.TTY) {
+		return fmt.Errorf("-i and -t can not be used with --pick=all")
+	}
+	if len(p.Record) > 0 && (p.Pick == pickAll || len(p.clusterTargets) > 0) {
+		return fmt.Errorf("--record can not be used when executing in several clusters or pods")
+	}
+	if len(p.Output) > 0 && p.Output != outputJSON {
+		return fmt.Errorf("unexpected -o output mode: %s, the flag 'output' must be json", p.Output)
+	}
+	if p.Output == outputJSON && (p.TTY || len(p.Record) > 0) {
+		return fmt.Errorf("-t and --record can not be used with -o json")
 	}
 	if len(p.Command) == 0 {
 		return fmt.Errorf("you must specify at least one command for the container")
@@ -258,6 +327,12 @@ func (p *ExecOptions) Validate() error {
 	if p.Out == nil || p.ErrOut == nil {
 		return fmt.Errorf("both output and error output must be provided")
 	}
+	if len(p.clusterTargets) > 0 && (p.Stdin || p.TTY) {
+		return fmt.Errorf("-i and -t can not be used when executing in several clusters")
+	}
+	if (p.Pick == pickAll || len(p.clusterTargets) > 0) && p.MaxParallel < 1 {
+		return fmt.Errorf("--max-parallel must be greater than 0")
+	}
 	return nil
 }
 
@@ -315,6 +390,10 @@ func (o *StreamOptions) SetupTTY() term.TTY {
 
 // Run executes a validated remote execution against a pod.
 func (p *ExecOptions) Run() error {
+	if len(p.clusterTargets) > 0 {
+		return p.runInClusters()
+	}
+
 	var err error
 	// we still need legacy pod getter when PodName in ExecOptions struct is provided,
 	// since there are any other command run this function by providing Podname with PodsGetter
@@ -324,15 +403,17 @@ func (p *ExecOptions) Run() error {
 		if err != nil {
 			return err
 		}
-	} else {
-		builder := p.Builder().
-			WithScheme(scheme.Scheme, scheme.Scheme.PrioritizedVersionsAllGroups()...).
-			FilenameParam(p.EnforceNamespace, &p.FilenameOptions).
-			NamespaceParam(p.Namespace).DefaultNamespace()
-		if len(p.ResourceName) > 0 {
-			builder = builder.ResourceNames("pods", p.ResourceName)
+	} else if len(p.Selector) > 0 || len(p.Pick) > 0 {
+		pods, err := p.pickPods()
+		if err != nil {
+			return err
+		}
+		if len(pods) > 1 {
+			return p.runInPods(pods)
 		}
-		obj, err := builder.Do().Object()
+		p.Pod = pods[0]
+	} else {
+		obj, err := p.resourceObject()
 		if err != nil {
 			return err
 		}
@@ -341,8 +422,23 @@ func (p *ExecOptions) Run() error {
 			return err
 		}
 	}
-	pod := p.Pod
+	return p.execInPod(p.Pod)
+}
+
+// resourceObject gets the object named by the arguments or --filename
+func (p *ExecOptions) resourceObject() (runtime.Object, error) {
+	builder := p.Builder().
+		WithScheme(scheme.Scheme, scheme.Scheme.PrioritizedVersionsAllGroups()...).
+		FilenameParam(p.EnforceNamespace, &p.FilenameOptions).
+		NamespaceParam(p.Namespace).DefaultNamespace()
+	if len(p.ResourceName) > 0 {
+		builder = builder.ResourceNames("pods", p.ResourceName)
+	}
+	return builder.Do().Object()
+}
 
+// execInPod runs the command in a container of pod
+func (p *ExecOptions) execInPod(pod *corev1.Pod) error {
 	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
 		return fmt.Errorf("cannot exec into a container in a completed pod; current phase is %s", pod.Status.Phase)
 	}
@@ -365,6 +461,25 @@ func (p *ExecOptions) Run() error {
 		// true
 		p.ErrOut = nil
 	}
+	in, out, errOut := p.In, p.Out, p.ErrOut
+	var stdout, stderr *bytes.Buffer
+	if p.Output == outputJSON {
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		out, errOut = stdout, stderr
+	}
+	var rec *recorder
+	if len(p.Record) > 0 {
+		var err error
+		if rec, err = p.startRecording(t, pod, containerName); err != nil {
+			return err
+		}
+		in, out, errOut, sizeQueue = rec.wrap(in, out, errOut, sizeQueue)
+	}
+	var session *auditedSession
+	if p.Auditor != nil {
+		session = p.startAudit(pod, containerName)
+		in, out, errOut = session.wrap(in, out, errOut)
+	}
 	fn := func() error {
 		restClient, err := restclient.RESTClientFor(p.Config)
 		if err != nil {
@@ -381,17 +496,53 @@ func (p *ExecOptions) Run() error {
 			Container: containerName,
 			Command:   p.Command,
 			Stdin:     p.Stdin,
-			Stdout:    p.Out != nil,
-			Stderr:    p.ErrOut != nil,
+			Stdout:    out != nil,
+			Stderr:    errOut != nil,
 			TTY:       t.Raw,
 		}, scheme.ParameterCodec)
 
-		return p.Executor.Execute("POST", req.URL(), p.Config, p.In, p.Out, p.ErrOut, t.Raw, sizeQueue)
+		return p.Executor.Execute("POST", req.URL(), p.Config, in, out, errOut, t.Raw, sizeQueue)
 	}
-	if err := t.Safe(fn); err != nil {
-		return err
+	start := time.Now()
+	var err error
+	if session != nil {
+		err = session.run(t, fn)
+	} else {
+		err = t.Safe(fn)
 	}
-	return nil
+	if rec != nil {
+		if recErr := rec.Close(); recErr != nil && err == nil {
+			err = fmt.Errorf("unable to write the recording %s: %v", p.Record, recErr)
+		}
+	}
+	if p.Output == outputJSON {
+		if printErr := p.printResult(pod, containerName, stdout, stderr, time.Since(start), err); printErr != nil {
+			return printErr
+		}
+		return exitError(err, true)
+	}
+	return exitError(err, false)
+}
+
+// startRecording creates the --record file of a session in the container of pod
+func (p *ExecOptions) startRecording(t term.TTY, pod *corev1.Pod, containerName string) (*recorder, error) {
+	header := recordHeader{
+		Width:   defaultRecordWidth,
+		Height:  defaultRecordHeight,
+		Command: strings.Join(p.Command, " "),
+		Title:   fmt.Sprintf("%s/%s/%s -c %s", p.ClusterName, pod.Namespace, pod.Name, containerName),
+		Env:     map[string]string{"TERM": os.Getenv("TERM")},
+	}
+	if t.Raw {
+		if size := t.GetSize(); size != nil {
+			header.Width, header.Height = size.Width, size.Height
+		}
+	}
+	rec, err := newRecorder(p.Record, header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to record the session: %v", err)
+	}
+	return rec, nil
 }
 
 func isClusterInContext(ClusterName string, config *clientcmdapi.Config) (string, bool) {
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Angus-F/kubectl/pkg/polymorphichelpers"
+	"github.com/Angus-F/kubectl/pkg/util/podutils"
+)
+
+const (
+	pickFirstReady    = "first-ready"
+	pickRandom        = "random"
+	pickLeastRestarts = "least-restarts"
+	pickAll           = "all"
+)
+
+// pickPolicies are the values of --pick
+var pickPolicies = []string{pickFirstReady, pickRandom, pickLeastRestarts, pickAll}
+
+func isPickPolicy(pick string) bool {
+	for _, policy := range pickPolicies {
+		if pick == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// pickPods lists the ready pods of --selector, or of the object named by the
+// arguments, and returns the ones chosen by --pick
+func (p *ExecOptions) pickPods() ([]*corev1.Pod, error) {
+	namespace, selector := p.Namespace, p.Selector
+	if len(selector) == 0 {
+		obj, err := p.resourceObject()
+		if err != nil {
+			return nil, err
+		}
+		if pod, ok := obj.(*corev1.Pod); ok {
+			return []*corev1.Pod{pod}, nil
+		}
+		objNamespace, objSelector, err := polymorphichelpers.SelectorsForObject(obj)
+		if err != nil {
+			return nil, fmt.Errorf("cannot exec into %T: %v", obj, err)
+		}
+		namespace, selector = objNamespace, objSelector.String()
+	}
+
+	podList, err := p.PodClient.Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	ready := []*corev1.Pod{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning && podutils.IsPodReady(pod) {
+			ready = append(ready, pod)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, fmt.Errorf("no ready pods match the selector %q in namespace %s", selector, namespace)
+	}
+	// the pods attachablePodForObject would pick come first
+	sort.Sort(sort.Reverse(podutils.ActivePods(ready)))
+
+	randIntn := p.randIntn
+	if randIntn == nil {
+		randIntn = rand.Intn
+	}
+	return pickFrom(ready, p.Pick, randIntn), nil
+}
+
+// pickFrom returns the pods chosen by pick among the sorted ready pods
+func pickFrom(pods []*corev1.Pod, pick string, randIntn func(n int) int) []*corev1.Pod {
+	switch pick {
+	case pickAll:
+		return pods
+	case pickRandom:
+		i := randIntn(len(pods))
+		return pods[i : i+1]
+	case pickLeastRestarts:
+		best := pods[0]
+		for _, pod := range pods[1:] {
+			if containerRestarts(pod) < containerRestarts(best) {
+				best = pod
+			}
+		}
+		return []*corev1.Pod{best}
+	}
+	return pods[:1]
+}
+
+// containerRestarts is the number of restarts of all the containers of pod
+func containerRestarts(pod *corev1.Pod) int32 {
+	restarts := int32(0)
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
+// runInPods runs the command in every pod, at most MaxParallel at a time, the
+// way runInClusters runs it in every cluster
+func (p *ExecOptions) runInPods(pods []*corev1.Pod) error {
+	targets := make([]*ExecOptions, len(pods))
+	for i, pod := range pods {
+		target := *p
+		target.Pod = pod
+		targets[i] = &target
+	}
+	return p.runInTargets("pod", targets,
+		func(target *ExecOptions) string { return target.Pod.Name },
+		func(target *ExecOptions) error { return target.execInPod(target.Pod) })
+}
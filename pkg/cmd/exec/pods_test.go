@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/rest/fake"
+	"github.com/Angus-F/client-go/tools/remotecommand"
+	uexec "github.com/Angus-F/client-go/util/exec"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func selectedPod(name string, ready bool, restarts int32, readySince time.Time) corev1.Pod {
+	pod := execPod()
+	pod.Name = name
+	pod.Labels = map[string]string{"app": "web"}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status, LastTransitionTime: metav1.NewTime(readySince)}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "bar", RestartCount: restarts}}
+	return *pod
+}
+
+// podRecordingExecutor records the pods the command runs in and fails in the ones of exitCodes
+type podRecordingExecutor struct {
+	lock      sync.Mutex
+	pods      []string
+	exitCodes map[string]int
+}
+
+func (f *podRecordingExecutor) Execute(method string, url *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	parts := strings.Split(url.Path, "/")
+	pod := parts[len(parts)-2]
+	f.lock.Lock()
+	f.pods = append(f.pods, pod)
+	f.lock.Unlock()
+	fmt.Fprintf(stdout, "hello from %s\n", pod)
+	if code := f.exitCodes[pod]; code != 0 {
+		return uexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code %d", code), Code: code}
+	}
+	return nil
+}
+
+func TestExecPickPods(t *testing.T) {
+	now := time.Now()
+	pods := &corev1.PodList{
+		ListMeta: metav1.ListMeta{ResourceVersion: "10"},
+		Items: []corev1.Pod{
+			selectedPod("web-old", true, 4, now.Add(-time.Hour)),
+			selectedPod("web-new", true, 0, now.Add(-time.Minute)),
+			selectedPod("web-unready", false, 0, now),
+		},
+	}
+	tests := []struct {
+		name         string
+		pick         string
+		output       string
+		randIntn     func(int) int
+		exitCodes    map[string]int
+		expectedPods []string
+		expectedErr  string
+	}{
+		{
+			name:         "first ready by default",
+			expectedPods: []string{"web-old"},
+		},
+		{
+			name:         "random",
+			pick:         pickRandom,
+			randIntn:     func(n int) int { return n - 1 },
+			expectedPods: []string{"web-new"},
+		},
+		{
+			name:         "least restarts",
+			pick:         pickLeastRestarts,
+			expectedPods: []string{"web-new"},
+		},
+		{
+			name:         "all",
+			pick:         pickAll,
+			expectedPods: []string{"web-new", "web-old"},
+		},
+		{
+			name:         "all with a failure",
+			pick:         pickAll,
+			exitCodes:    map[string]int{"web-new": 2},
+			expectedPods: []string{"web-new", "web-old"},
+			expectedErr:  "command failed in 1 of 2 pods",
+		},
+		{
+			name:         "all as json",
+			pick:         pickAll,
+			output:       outputJSON,
+			exitCodes:    map[string]int{"web-new": 2},
+			expectedPods: []string{"web-new", "web-old"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+
+			codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+			tf.Client = &fake.RESTClient{
+				GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					if req.URL.Path == "/api/v1/namespaces/test/pods" && req.Method == "GET" && req.URL.Query().Get("labelSelector") == "app=web" {
+						return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, pods)}, nil
+					}
+					t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+					return nil, fmt.Errorf("unexpected request")
+				}),
+			}
+			tf.ClientConfigVal = &restclient.Config{APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}}
+
+			ex := &podRecordingExecutor{exitCodes: test.exitCodes}
+			streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+			options := &ExecOptions{
+				StreamOptions: StreamOptions{IOStreams: streams},
+				Selector:      "app=web",
+				Pick:          test.pick,
+				Output:        test.output,
+				MaxParallel:   2,
+				Executor:      ex,
+				randIntn:      test.randIntn,
+			}
+			cmd := NewCmdExec(tf, streams)
+			err := options.Complete(tf, cmd, []string{"date"}, 0)
+			if err == nil {
+				err = options.Validate()
+			}
+			if err == nil {
+				err = options.Run()
+			}
+			if test.output == outputJSON {
+				if exitErr, ok := err.(uexec.ExitError); !ok || exitErr.ExitStatus() != 2 || len(err.Error()) > 0 {
+					t.Fatalf("expected a silent exit error with the exit code of the failed pod, got %v", err)
+				}
+				if len(errOut.String()) > 0 {
+					t.Errorf("expected no summary, got %q", errOut.String())
+				}
+				lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+				for _, line := range lines {
+					result := execOutput{}
+					if err := json.Unmarshal([]byte(line), &result); err != nil {
+						t.Fatalf("expected one JSON result per line, got %q", out.String())
+					}
+					if result.Stdout != fmt.Sprintf("hello from %s\n", result.Pod) || result.ExitCode != test.exitCodes[result.Pod] {
+						t.Errorf("unexpected result %#v", result)
+					}
+				}
+				if len(lines) != 2 {
+					t.Errorf("expected a result per pod, got %q", out.String())
+				}
+			} else if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				if exitErr, ok := err.(uexec.ExitError); !ok || exitErr.ExitStatus() != 2 {
+					t.Errorf("expected the exit code of the failed pod, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sort.Strings(ex.pods)
+			if strings.Join(ex.pods, ",") != strings.Join(test.expectedPods, ",") {
+				t.Errorf("expected the command to run in %v, got %v", test.expectedPods, ex.pods)
+			}
+			if test.pick == pickAll && test.output != outputJSON {
+				for _, pod := range test.expectedPods {
+					if !strings.Contains(out.String(), fmt.Sprintf("[pod/%s] hello from %s\n", pod, pod)) {
+						t.Errorf("expected prefixed output of %s, got %q", pod, out.String())
+					}
+					if !strings.Contains(errOut.String(), pod) {
+						t.Errorf("expected summary for pod %s, got %q", pod, errOut.String())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExecPickValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *ExecOptions
+		expectedErr string
+	}{
+		{
+			name:        "unknown policy",
+			options:     &ExecOptions{Selector: "app=web", Pick: "fastest"},
+			expectedErr: "--pick must be one of",
+		},
+		{
+			name:        "selector and resource",
+			options:     &ExecOptions{Selector: "app=web", ResourceName: "deploy/web"},
+			expectedErr: "--selector can not be used with",
+		},
+		{
+			name:        "all with stdin",
+			options:     &ExecOptions{StreamOptions: StreamOptions{Stdin: true}, Selector: "app=web", Pick: pickAll},
+			expectedErr: "-i and -t can not be used with --pick=all",
+		},
+		{
+			name:        "all without parallelism",
+			options:     &ExecOptions{Selector: "app=web", Pick: pickAll, MaxParallel: 0},
+			expectedErr: "--max-parallel must be greater than 0",
+		},
+		{
+			name:        "all with negative parallelism",
+			options:     &ExecOptions{Selector: "app=web", Pick: pickAll, MaxParallel: -1},
+			expectedErr: "--max-parallel must be greater than 0",
+		},
+		{
+			name:        "clusters without parallelism",
+			options:     &ExecOptions{StreamOptions: StreamOptions{PodName: "foo"}, clusterTargets: []*ExecOptions{{}, {}}},
+			expectedErr: "--max-parallel must be greater than 0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.IOStreams = genericclioptions.NewTestIOStreamsDiscard()
+			test.options.Command = []string{"date"}
+			if err := test.options.Validate(); err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/rest/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func TestExecPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		clusterName string
+		tty         bool
+		expectedErr string
+	}{
+		{
+			name:        "allowed",
+			policy:      "clusters:\n- name: prod\n  commands: [exec]\n",
+			clusterName: "prod",
+		},
+		{
+			name:        "command denied",
+			policy:      "clusters:\n- name: prod\n  commands: [logs]\n",
+			clusterName: "prod",
+			expectedErr: `access denied by the access policy test for cluster "prod": exec is not allowed`,
+		},
+		{
+			name:        "namespace denied",
+			policy:      "clusters:\n- name: prod\n  namespaces:\n    deny: [te*]\n",
+			clusterName: "prod",
+			expectedErr: `exec is not allowed in namespace "test"`,
+		},
+		{
+			name:        "tty denied",
+			policy:      "clusters:\n- name: prod\n  tty: false\n",
+			clusterName: "prod",
+			tty:         true,
+			expectedErr: "interactive TTY sessions are not allowed",
+		},
+		{
+			name:        "denied in one of several clusters",
+			policy:      "clusters:\n- name: prod-west\n  commands: [logs]\n",
+			clusterName: "prod-east,prod-west",
+			expectedErr: `cluster prod-west: access denied by the access policy test for cluster "prod-west"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := configs.ParsePolicy([]byte(test.policy), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.ClusterNamesVal = []string{"prod", "prod-east", "prod-west"}
+			tf.PolicyVal = policy
+
+			requests := 0
+			codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+			tf.Client = &fake.RESTClient{
+				GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					requests++
+					if req.URL.Path == "/api/v1/namespaces/test/pods/foo" && req.Method == "GET" {
+						return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, execPod())}, nil
+					}
+					t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+					return nil, fmt.Errorf("unexpected request")
+				}),
+			}
+			tf.ClientConfigVal = &restclient.Config{APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}}
+
+			ex := &fakeOutputExecutor{stdout: "ok"}
+			streams := genericclioptions.NewTestIOStreamsDiscard()
+			options := &ExecOptions{
+				StreamOptions: StreamOptions{
+					PodName:   "foo",
+					Stdin:     test.tty,
+					TTY:       test.tty,
+					IOStreams: streams,
+				},
+				ClusterName: test.clusterName,
+				Executor:    ex,
+			}
+			cmd := NewCmdExec(tf, streams)
+			err = options.Complete(tf, cmd, []string{"foo", "date"}, 1)
+			if err == nil {
+				err = options.Validate()
+			}
+			if err == nil {
+				err = options.Run()
+			}
+
+			if len(test.expectedErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if ex.calls != 1 {
+					t.Errorf("expected the command to run, got %d calls", ex.calls)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+			}
+			if requests != 0 || ex.calls != 0 {
+				t.Errorf("expected no request before the denial, got %d requests and %d calls", requests, ex.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Angus-F/client-go/tools/remotecommand"
+)
+
+const (
+	// defaultRecordWidth and defaultRecordHeight are the size recorded when
+	// the session has no terminal
+	defaultRecordWidth  = 80
+	defaultRecordHeight = 24
+)
+
+// recordHeader is the first line of an asciicast v2 recording
+type recordHeader struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recorder writes the events of a session to an asciicast v2 recording: one
+// JSON array per line with the seconds since the start of the session, the
+// event code and its data. Output is recorded as "o", input as "i" and
+// terminal resizes as "r" events.
+type recorder struct {
+	lock   sync.Mutex
+	writer io.WriteCloser
+	start  time.Time
+	now    func() time.Time
+	size   remotecommand.TerminalSize
+	err    error
+}
+
+// newRecorder creates the recording file and writes its header. Recordings
+// may contain secrets typed in the session, so only the owner can read them.
+func newRecorder(filename string, header recordHeader) (*recorder, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return startRecorder(file, header, time.Now)
+}
+
+func startRecorder(writer io.WriteCloser, header recordHeader, now func() time.Time) (*recorder, error) {
+	r := &recorder{
+		writer: writer,
+		start:  now(),
+		now:    now,
+		size:   remotecommand.TerminalSize{Width: header.Width, Height: header.Height},
+	}
+	header.Version = 2
+	header.Timestamp = r.start.Unix()
+	if err := r.writeLine(header); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *recorder) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = r.writer.Write(append(data, '\n'))
+	return err
+}
+
+// event records data with code. The first error is kept and returned by
+// Close, so that a full disk doesn't interrupt the session.
+func (r *recorder) event(code, data string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return
+	}
+	elapsed := math.Round(r.now().Sub(r.start).Seconds()*1e6) / 1e6
+	r.err = r.writeLine([]interface{}{elapsed, code, data})
+}
+
+func (r *recorder) resize(size remotecommand.TerminalSize) {
+	r.lock.Lock()
+	changed := size != r.size
+	r.size = size
+	r.lock.Unlock()
+	if changed {
+		r.event("r", fmt.Sprintf("%dx%d", size.Width, size.Height))
+	}
+}
+
+// Close closes the recording and returns the first error writing it
+func (r *recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.writer.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// wrap returns the streams and the size queue of a session, recording
+// what goes through them. Missing streams stay nil.
+func (r *recorder) wrap(in io.Reader, out, errOut io.Writer, sizeQueue remotecommand.TerminalSizeQueue) (io.Reader, io.Writer, io.Writer, remotecommand.TerminalSizeQueue) {
+	if in != nil {
+		in = &recordingReader{reader: in, stream: recordStream{recorder: r, code: "i"}}
+	}
+	if out != nil {
+		out = &recordingWriter{writer: out, stream: recordStream{recorder: r, code: "o"}}
+	}
+	if errOut != nil {
+		errOut = &recordingWriter{writer: errOut, stream: recordStream{recorder: r, code: "o"}}
+	}
+	if sizeQueue != nil {
+		sizeQueue = &recordingSizeQueue{queue: sizeQueue, recorder: r}
+	}
+	return in, out, errOut, sizeQueue
+}
+
+// recordStream records the data of one stream. Recorded data must be valid
+// UTF-8, so a rune split between two reads or writes is recorded once complete.
+type recordStream struct {
+	recorder *recorder
+	code     string
+	pending  []byte
+}
+
+func (s *recordStream) record(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	data = append(s.pending, data...)
+	s.pending = nil
+	// the last bytes may start an incomplete rune
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				s.pending = append([]byte{}, data[i:]...)
+				data = data[:i]
+			}
+			break
+		}
+	}
+	if len(data) > 0 {
+		s.recorder.event(s.code, strings.ToValidUTF8(string(data), string(utf8.RuneError)))
+	}
+}
+
+type recordingReader struct {
+	reader io.Reader
+	stream recordStream
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.stream.record(p[:n])
+	return n, err
+}
+
+type recordingWriter struct {
+	writer io.Writer
+	stream recordStream
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.stream.record(p[:n])
+	return n, err
+}
+
+type recordingSizeQueue struct {
+	queue    remotecommand.TerminalSizeQueue
+	recorder *recorder
+}
+
+func (q *recordingSizeQueue) Next() *remotecommand.TerminalSize {
+	size := q.queue.Next()
+	if size != nil {
+		q.recorder.resize(*size)
+	}
+	return size
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	kubefake "github.com/Angus-F/client-go/kubernetes/fake"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/tools/remotecommand"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type fakeSizeQueue struct {
+	sizes []remotecommand.TerminalSize
+}
+
+func (q *fakeSizeQueue) Next() *remotecommand.TerminalSize {
+	if len(q.sizes) == 0 {
+		return nil
+	}
+	size := q.sizes[0]
+	q.sizes = q.sizes[1:]
+	return &size
+}
+
+func TestRecorder(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	now := start
+	clock := func() time.Time { return now }
+
+	recording := &bytes.Buffer{}
+	rec, err := startRecorder(nopWriteCloser{recording}, recordHeader{Width: 80, Height: 24, Command: "bash"}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := &fakeSizeQueue{sizes: []remotecommand.TerminalSize{{Width: 80, Height: 24}, {Width: 120, Height: 40}}}
+	in, out, errOut, sizeQueue := rec.wrap(strings.NewReader("ls\n"), ioutil.Discard, nil, sizes)
+	if errOut != nil {
+		t.Errorf("expected a missing stream to stay missing")
+	}
+
+	now = start.Add(500 * time.Millisecond)
+	if _, err := ioutil.ReadAll(in); err != nil {
+		t.Fatal(err)
+	}
+	now = start.Add(time.Second)
+	// a rune split between two writes is recorded once complete
+	out.Write([]byte("caf\xc3"))
+	out.Write([]byte("\xa9\n"))
+	now = start.Add(1500 * time.Millisecond)
+	for sizeQueue.Next() != nil {
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(recording.String(), "\n"), "\n")
+	header := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header["version"] != float64(2) || header["width"] != float64(80) || header["timestamp"] != float64(1600000000) || header["command"] != "bash" {
+		t.Errorf("unexpected header %s", lines[0])
+	}
+	expected := []string{
+		`[0.5,"i","ls\n"]`,
+		`[1,"o","caf"]`,
+		`[1,"o","é\n"]`,
+		`[1.5,"r","120x40"]`,
+	}
+	if strings.Join(lines[1:], "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected events:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines[1:], "\n"))
+	}
+}
+
+// outputExecutor writes output and reads stdin like a remote shell
+type outputExecutor struct{}
+
+func (outputExecutor) Execute(method string, url *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	stdout.Write([]byte("$ "))
+	ioutil.ReadAll(stdin)
+	stdout.Write([]byte("bye\n"))
+	return nil
+}
+
+func TestExecRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "session.cast")
+
+	streams, in, out, _ := genericclioptions.NewTestIOStreams()
+	in.WriteString("exit\n")
+	options := &ExecOptions{
+		StreamOptions: StreamOptions{
+			Namespace: "test",
+			PodName:   "foo",
+			Stdin:     true,
+			IOStreams: streams,
+		},
+		ClusterName: "dev",
+		Command:     []string{"sh"},
+		Record:      filename,
+		Executor:    outputExecutor{},
+		Config:      &restclient.Config{APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}},
+		PodClient:   kubefake.NewSimpleClientset(execPod()).CoreV1(),
+	}
+	if err := options.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := options.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "$ bye\n" {
+		t.Errorf("expected the output to be written, got %q", out.String())
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the recording to be readable by its owner only, got %v", info.Mode())
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if !strings.Contains(lines[0], `"title":"dev/test/foo -c bar"`) || !strings.Contains(lines[0], `"command":"sh"`) {
+		t.Errorf("unexpected header %s", lines[0])
+	}
+	events := []string{}
+	for _, line := range lines[1:] {
+		event := []interface{}{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event[1].(string)+" "+event[2].(string))
+	}
+	expected := []string{"o $ ", "i exit\n", "o bye\n"}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %q, got %q", expected, events)
+	}
+}
+
+func TestExecRecordRejectsSeveralTargets(t *testing.T) {
+	options := &ExecOptions{
+		StreamOptions: StreamOptions{IOStreams: genericclioptions.NewTestIOStreamsDiscard()},
+		Selector:      "app=web",
+		Pick:          pickAll,
+		Command:       []string{"date"},
+		Record:        "session.cast",
+	}
+	if err := options.Validate(); err == nil || !strings.Contains(err.Error(), "--record can not be used") {
+		t.Errorf("expected --record to be rejected with --pick=all, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	uexec "github.com/Angus-F/client-go/util/exec"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// outputJSON prints the result of every execution as a JSON object
+const outputJSON = "json"
+
+// execOutput is the result of an execution printed with -o json
+type execOutput struct {
+	Cluster    string `json:"cluster"`
+	Namespace  string `json:"namespace"`
+	Pod        string `json:"pod"`
+	Container  string `json:"container"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+	// Error is set if the command could not be run, the exit code is -1 then
+	Error string `json:"error,omitempty"`
+}
+
+// exitCode returns the exit code of the remote command that failed with err,
+// 0 if err is nil and -1 if the command could not be run
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr uexec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+// exitError returns err as an exit error with the exact exit code of the
+// remote command, even if it is wrapped, which cmdutil.CheckErr exits with.
+// The message is dropped if quiet, when the result was already printed.
+func exitError(err error, quiet bool) error {
+	code := exitCode(err)
+	if code <= 0 {
+		return err
+	}
+	if quiet {
+		return uexec.CodeExitError{Err: errors.New(""), Code: code}
+	}
+	if _, ok := err.(uexec.ExitError); ok {
+		return err
+	}
+	return uexec.CodeExitError{Err: err, Code: code}
+}
+
+// printResult writes the result of the execution of the command in the
+// container of pod to Out as a single line of JSON
+func (p *ExecOptions) printResult(pod *corev1.Pod, containerName string, stdout, stderr *bytes.Buffer, duration time.Duration, err error) error {
+	result := execOutput{
+		Cluster:    p.ClusterName,
+		Namespace:  pod.Namespace,
+		Pod:        pod.Name,
+		Container:  containerName,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		ExitCode:   exitCode(err),
+		DurationMs: duration.Milliseconds(),
+	}
+	if result.ExitCode < 0 {
+		result.Error = err.Error()
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	// a single write, so that results of concurrent executions don't interleave
+	_, err = p.Out.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	kubefake "github.com/Angus-F/client-go/kubernetes/fake"
+	restclient "github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/tools/remotecommand"
+	uexec "github.com/Angus-F/client-go/util/exec"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+// exitingExecutor writes to stdout and stderr and fails with err
+type exitingExecutor struct {
+	err error
+}
+
+func (e exitingExecutor) Execute(method string, url *url.URL, config *restclient.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	fmt.Fprint(stdout, "out\n")
+	fmt.Fprint(stderr, "err\n")
+	return e.err
+}
+
+func TestExecExitCode(t *testing.T) {
+	tests := []struct {
+		name            string
+		output          string
+		err             error
+		expectedCode    int
+		expectedMessage string
+		expectedOut     string
+		expectedResult  *execOutput
+	}{
+		{
+			name:         "success",
+			expectedOut:  "out\n",
+			expectedCode: -1,
+		},
+		{
+			name:            "remote exit code",
+			err:             uexec.CodeExitError{Err: errors.New("command terminated with exit code 3"), Code: 3},
+			expectedOut:     "out\n",
+			expectedCode:    3,
+			expectedMessage: "command terminated with exit code 3",
+		},
+		{
+			name:            "wrapped remote exit code",
+			err:             fmt.Errorf("stream failed: %w", uexec.CodeExitError{Err: errors.New("exit code 42"), Code: 42}),
+			expectedOut:     "out\n",
+			expectedCode:    42,
+			expectedMessage: "stream failed: exit code 42",
+		},
+		{
+			name:           "json",
+			output:         outputJSON,
+			expectedCode:   -1,
+			expectedResult: &execOutput{Cluster: "dev", Namespace: "test", Pod: "foo", Container: "bar", Stdout: "out\n", Stderr: "err\n"},
+		},
+		{
+			name:           "json with a remote exit code",
+			output:         outputJSON,
+			err:            uexec.CodeExitError{Err: errors.New("command terminated with exit code 3"), Code: 3},
+			expectedCode:   3,
+			expectedResult: &execOutput{Cluster: "dev", Namespace: "test", Pod: "foo", Container: "bar", Stdout: "out\n", Stderr: "err\n", ExitCode: 3},
+		},
+		{
+			name:            "json with a failure to run the command",
+			output:          outputJSON,
+			err:             errors.New("upgrade request required"),
+			expectedCode:    cmdutil.DefaultErrorExitCode,
+			expectedMessage: "error: upgrade request required",
+			expectedResult:  &execOutput{Cluster: "dev", Namespace: "test", Pod: "foo", Container: "bar", Stdout: "out\n", Stderr: "err\n", ExitCode: -1, Error: "upgrade request required"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, message := -1, ""
+			cmdutil.BehaviorOnFatal(func(msg string, c int) {
+				code, message = c, msg
+			})
+			defer cmdutil.DefaultBehaviorOnFatal()
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			options := &ExecOptions{
+				StreamOptions: StreamOptions{
+					Namespace: "test",
+					PodName:   "foo",
+					IOStreams: streams,
+				},
+				ClusterName: "dev",
+				Command:     []string{"date"},
+				Output:      test.output,
+				Executor:    exitingExecutor{err: test.err},
+				Config:      &restclient.Config{APIPath: "/api", ContentConfig: restclient.ContentConfig{NegotiatedSerializer: scheme.Codecs, GroupVersion: &schema.GroupVersion{Version: "v1"}}},
+				PodClient:   kubefake.NewSimpleClientset(execPod()).CoreV1(),
+			}
+			if err := options.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			cmdutil.CheckErr(options.Run())
+
+			if code != test.expectedCode || message != test.expectedMessage {
+				t.Errorf("expected exit code %d and message %q, got %d and %q", test.expectedCode, test.expectedMessage, code, message)
+			}
+			if test.expectedResult == nil {
+				if out.String() != test.expectedOut {
+					t.Errorf("expected output %q, got %q", test.expectedOut, out.String())
+				}
+				return
+			}
+			result := execOutput{}
+			if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+				t.Fatalf("expected a JSON result, got %q: %v", out.String(), err)
+			}
+			result.DurationMs = 0
+			if result != *test.expectedResult {
+				t.Errorf("expected result %#v, got %#v", *test.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestExecJSONValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *ExecOptions
+		expectedErr string
+	}{
+		{
+			name:        "unknown output",
+			options:     &ExecOptions{StreamOptions: StreamOptions{PodName: "foo"}, Output: "yaml"},
+			expectedErr: "the flag 'output' must be json",
+		},
+		{
+			name:        "tty",
+			options:     &ExecOptions{StreamOptions: StreamOptions{PodName: "foo", Stdin: true, TTY: true}, Output: outputJSON},
+			expectedErr: "-t and --record can not be used with -o json",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.IOStreams = genericclioptions.NewTestIOStreamsDiscard()
+			test.options.Command = []string{"date"}
+			if err := test.options.Validate(); err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Angus-F/client-go/rest"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+)
+
+// errInterrupted is recorded when following the logs is ended by a signal
+var errInterrupted = errors.New("interrupted")
+
+// auditedLogs logs a run of the logs command to the audit log, with the
+// bytes of logs received from every container
+type auditedLogs struct {
+	logger   audit.Logger
+	warnings io.Writer
+	entry    *audit.Entry
+	bytes    int64
+	err      error
+}
+
+// startAudit starts the audit log entry of the run and counts the bytes
+// consumed by ConsumeRequestFn, for every cluster target if there are several
+func (o *LogsOptions) startAudit() *auditedLogs {
+	a := &auditedLogs{
+		logger:   o.Auditor,
+		warnings: o.ErrOut,
+		entry:    audit.NewEntry("logs", o.ClusterName, o.Namespace),
+		err:      errInterrupted,
+	}
+	a.entry.Pod, a.entry.Container = o.ResourceArg, o.Container
+	o.ConsumeRequestFn = a.count(o.ConsumeRequestFn)
+
+	if len(o.clusterTargets) > 0 {
+		clusterNames := make([]string, 0, len(o.clusterTargets))
+		for _, target := range o.clusterTargets {
+			clusterNames = append(clusterNames, target.ClusterName)
+			target.ConsumeRequestFn = a.count(target.ConsumeRequestFn)
+		}
+		a.entry.ClusterName = strings.Join(clusterNames, ",")
+		a.entry.Namespace = o.clusterTargets[0].Namespace
+	}
+	return a
+}
+
+func (a *auditedLogs) count(consume func(rest.ResponseWrapper, io.Writer) error) func(rest.ResponseWrapper, io.Writer) error {
+	return func(request rest.ResponseWrapper, out io.Writer) error {
+		return consume(request, &countingWriter{writer: out, n: &a.bytes})
+	}
+}
+
+// countingWriter adds the bytes written through it to n as they are written,
+// so that streams interrupted while followed are counted too
+type countingWriter struct {
+	writer io.Writer
+	n      *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	atomic.AddInt64(w.n, int64(n))
+	return n, err
+}
+
+// track returns run, keeping its error for the entry
+func (a *auditedLogs) track(run func() error) func() error {
+	return func() error {
+		a.err = run()
+		return a.err
+	}
+}
+
+// finish logs the entry once the logs are received, or following them is
+// interrupted
+func (a *auditedLogs) finish() {
+	a.entry.BytesOut = atomic.LoadInt64(&a.bytes)
+	a.entry.Finish(a.err)
+	audit.Record(a.logger, a.warnings, a.entry)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+)
+
+type fakeAuditor struct {
+	entries []*audit.Entry
+}
+
+func (a *fakeAuditor) Log(entry *audit.Entry) error {
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+func TestLogAudit(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectedBytes int64
+		expectedExit  int
+	}{
+		{
+			name:          "success",
+			expectedBytes: int64(len("line 1\nline 2\n")),
+		},
+		{
+			name:          "failure",
+			err:           errors.New("container is waiting to start"),
+			expectedBytes: int64(len("line 1\nline 2\n")),
+			expectedExit:  -1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			mock := &logTestMock{
+				logsForObjectRequests: map[corev1.ObjectReference]restclient.ResponseWrapper{
+					{Kind: "Pod", Namespace: "test", Name: "foo", FieldPath: "spec.containers{c1}"}: &responseWrapperMock{data: strings.NewReader("line 1\nline 2\n")},
+				},
+			}
+			auditor := &fakeAuditor{}
+			o := NewLogsOptions(streams, false)
+			o.ClusterName = "dev"
+			o.Namespace = "test"
+			o.ResourceArg = "foo"
+			o.Auditor = auditor
+			o.LogsForObject = mock.mockLogsForObject
+			o.ConsumeRequestFn = func(request restclient.ResponseWrapper, w io.Writer) error {
+				if err := mock.mockConsumeRequest(request, w); err != nil {
+					return err
+				}
+				return test.err
+			}
+			o.Options = &corev1.PodLogOptions{}
+			o.Object = testPod()
+
+			err := o.RunLogs()
+			if (err != nil) != (test.err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(auditor.entries) != 1 {
+				t.Fatalf("expected one audit entry, got %d", len(auditor.entries))
+			}
+			entry := auditor.entries[0]
+			if entry.Action != "logs" || entry.ClusterName != "dev" || entry.Namespace != "test" || entry.Pod != "foo" {
+				t.Errorf("unexpected entry %#v", entry)
+			}
+			if entry.BytesOut != test.expectedBytes || entry.ExitCode != test.expectedExit {
+				t.Errorf("expected %d bytes and exit code %d, got %#v (output %q)", test.expectedBytes, test.expectedExit, entry, out.String())
+			}
+		})
+	}
+}
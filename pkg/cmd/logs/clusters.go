@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"context"
+	"fmt"
+)
+
+// runLogsInClusters gets the logs of every cluster target and merges them into
+// a single output. Lines are prefixed with the cluster, pod and container they
+// come from. When following, every cluster may open up to MaxFollowConcurrency
+// streams.
+func (o LogsOptions) runLogsInClusters() error {
+	if o.followsSelector() {
+		return o.followPodsInClusters()
+	}
+
+	requests := []logRequest{}
+	for _, target := range o.clusterTargets {
+		clusterRequests, err := target.LogsForObject(target.RESTClientGetter, target.Object, target.Options, target.GetPodTimeout, target.AllContainers)
+		if err != nil {
+			if !o.IgnoreLogErrors {
+				return fmt.Errorf("cluster %s: %v", target.ClusterName, err)
+			}
+
+			fmt.Fprintf(o.errorWriter(o.Out), "error: cluster %s: %v\n", target.ClusterName, err)
+			continue
+		}
+
+		if o.Follow && len(clusterRequests) > o.MaxFollowConcurrency {
+			return fmt.Errorf(
+				"you are attempting to follow %d log streams in cluster %s, but maximum allowed concurrency is %d per cluster, use --max-log-requests to increase the limit",
+				len(clusterRequests), target.ClusterName, o.MaxFollowConcurrency,
+			)
+		}
+		requests = append(requests, target.logRequests(clusterRequests)...)
+	}
+
+	return o.consumeRequests(requests)
+}
+
+// followPodsInClusters follows the pods matching the selector in every cluster
+// target until one of them fails
+func (o LogsOptions) followPodsInClusters() error {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	out := &syncWriter{writer: o.Out}
+	errs := make(chan error, len(o.clusterTargets))
+	for _, target := range o.clusterTargets {
+		go func(target *LogsOptions) {
+			if err := target.followPods(ctx, out); err != nil {
+				errs <- fmt.Errorf("cluster %s: %v", target.ClusterName, err)
+			}
+		}(target)
+	}
+	return <-errs
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newClusterTarget(o *LogsOptions, clusterName string, mock *logTestMock) *LogsOptions {
+	target := *o
+	target.ClusterName = clusterName
+	target.Prefix = true
+	target.prefixCluster = true
+	target.Object = testPod()
+	target.LogsForObject = mock.mockLogsForObject
+	return &target
+}
+
+func clusterLogRequests(clusterName string, pods ...string) map[corev1.ObjectReference]restclient.ResponseWrapper {
+	requests := map[corev1.ObjectReference]restclient.ResponseWrapper{}
+	for _, pod := range pods {
+		requests[corev1.ObjectReference{
+			Kind:      "Pod",
+			Name:      pod,
+			FieldPath: "spec.containers{app}",
+		}] = &responseWrapperMock{data: strings.NewReader(fmt.Sprintf("log from %s/%s\n", clusterName, pod))}
+	}
+	return requests
+}
+
+func TestLogInClusters(t *testing.T) {
+	tests := []struct {
+		name                  string
+		follow                bool
+		maxFollowConcurrency  int
+		clusters              map[string][]string
+		expectedErr           string
+		expectedOutSubstrings []string
+	}{
+		{
+			name: "snapshot",
+			clusters: map[string][]string{
+				"east": {"web-1"},
+				"west": {"web-2"},
+			},
+			expectedOutSubstrings: []string{
+				"[east/web-1/app] log from east/web-1\n",
+				"[west/web-2/app] log from west/web-2\n",
+			},
+		},
+		{
+			name:                 "follow",
+			follow:               true,
+			maxFollowConcurrency: 2,
+			clusters: map[string][]string{
+				"east": {"web-1", "web-2"},
+				"west": {"web-1", "web-2"},
+			},
+			expectedOutSubstrings: []string{
+				"[east/web-1/app] log from east/web-1\n",
+				"[east/web-2/app] log from east/web-2\n",
+				"[west/web-1/app] log from west/web-1\n",
+				"[west/web-2/app] log from west/web-2\n",
+			},
+		},
+		{
+			name:                 "follow over the per cluster limit",
+			follow:               true,
+			maxFollowConcurrency: 1,
+			clusters: map[string][]string{
+				"east": {"web-1"},
+				"west": {"web-1", "web-2"},
+			},
+			expectedErr: "you are attempting to follow 2 log streams in cluster west, but maximum allowed concurrency is 1 per cluster",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+			o := NewLogsOptions(streams, false)
+			o.Follow = test.follow
+			o.Options = &corev1.PodLogOptions{}
+			if test.maxFollowConcurrency > 0 {
+				o.MaxFollowConcurrency = test.maxFollowConcurrency
+			}
+
+			total := 0
+			for _, pods := range test.clusters {
+				total += len(pods)
+			}
+			wg := &sync.WaitGroup{}
+			if test.follow && len(test.expectedErr) == 0 {
+				// every stream blocks until all streams are consumed, which only
+				// finishes when they are consumed concurrently
+				wg.Add(total)
+			} else {
+				wg = nil
+			}
+			for _, clusterName := range []string{"east", "west"} {
+				mock := &logTestMock{logsForObjectRequests: clusterLogRequests(clusterName, test.clusters[clusterName]...), wg: wg}
+				o.ConsumeRequestFn = mock.mockConsumeRequest
+				o.clusterTargets = append(o.clusterTargets, newClusterTarget(o, clusterName, mock))
+			}
+
+			err := o.RunLogs()
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, substr := range test.expectedOutSubstrings {
+				if !strings.Contains(buf.String(), substr) {
+					t.Errorf("expected to contain %q. Output: %q", substr, buf.String())
+				}
+			}
+		})
+	}
+}
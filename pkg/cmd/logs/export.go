@@ -0,0 +1,276 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	archiveGzip  = "gzip"
+	archiveTarGz = "tar.gz"
+
+	logFileExtension = ".log"
+)
+
+var errSinkClosed = errors.New("the log files are already closed")
+
+// logFileSink writes the logs of every container to its own file in
+// <dir>/<cluster>/<namespace>/<pod>/<container>.log. Files are rotated when
+// they reach rotateSize bytes or are older than rotateInterval, rotated files
+// are named <container>.1.log, <container>.2.log and so on.
+type logFileSink struct {
+	dir            string
+	rotateSize     int64
+	rotateInterval time.Duration
+	archive        string
+	now            func() time.Time
+
+	lock   sync.Mutex
+	files  map[string]*rotatingFile
+	paths  []string
+	closed bool
+	// result is the file or directory the logs were exported to once closed
+	result string
+	err    error
+}
+
+func newLogFileSink(dir string, rotateSize int64, rotateInterval time.Duration, archive string) *logFileSink {
+	return &logFileSink{
+		dir:            dir,
+		rotateSize:     rotateSize,
+		rotateInterval: rotateInterval,
+		archive:        archive,
+		now:            time.Now,
+		files:          map[string]*rotatingFile{},
+	}
+}
+
+// writer returns the writer for the log file of the container ref points to
+func (s *logFileSink) writer(clusterName, containerName string, ref corev1.ObjectReference) io.Writer {
+	name := filepath.Join(s.dir, clusterName, ref.Namespace, ref.Name, containerName)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if file, ok := s.files[name]; ok {
+		return file
+	}
+	file := &rotatingFile{sink: s, name: name}
+	s.files[name] = file
+	return file
+}
+
+// create creates the next file of a rotatingFile. It is called with the sink locked.
+func (s *logFileSink) create(name string, index int) (*os.File, error) {
+	if s.closed {
+		return nil, errSinkClosed
+	}
+	filename := name + logFileExtension
+	if index > 0 {
+		filename = fmt.Sprintf("%s.%d%s", name, index, logFileExtension)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	s.paths = append(s.paths, filename)
+	return file, nil
+}
+
+// Close closes all files and archives them. It is safe to call more than once,
+// the error is kept in s.err.
+func (s *logFileSink) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	for _, file := range s.files {
+		if err := file.close(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+	if s.err != nil {
+		return
+	}
+
+	s.result = s.dir
+	switch s.archive {
+	case archiveGzip:
+		s.err = gzipFiles(s.paths)
+	case archiveTarGz:
+		s.result = strings.TrimSuffix(filepath.Clean(s.dir), string(filepath.Separator)) + ".tar.gz"
+		s.err = tarGzFiles(s.result, s.dir, s.paths)
+	}
+}
+
+// rotatingFile is the log file of a single container
+type rotatingFile struct {
+	sink *logFileSink
+	name string
+
+	file    *os.File
+	index   int
+	size    int64
+	created time.Time
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.sink.lock.Lock()
+	defer f.sink.lock.Unlock()
+
+	if f.file != nil && f.needsRotation(len(p)) {
+		if err := f.close(); err != nil {
+			return 0, err
+		}
+		f.index++
+	}
+	if f.file == nil {
+		file, err := f.sink.create(f.name, f.index)
+		if err != nil {
+			return 0, err
+		}
+		f.file, f.size, f.created = file, 0, f.sink.now()
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) needsRotation(size int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.sink.rotateSize > 0 && f.size+int64(size) > f.sink.rotateSize {
+		return true
+	}
+	return f.sink.rotateInterval > 0 && f.sink.now().Sub(f.created) >= f.sink.rotateInterval
+}
+
+func (f *rotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// gzipFiles replaces every file with a gzip compressed <file>.gz
+func gzipFiles(paths []string) error {
+	for _, path := range paths {
+		if err := gzipFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := gzip.NewWriter(out)
+	w.Name = filepath.Base(path)
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// tarGzFiles writes the files to a gzip compressed tar archive, named relative to dir
+func tarGzFiles(archive, dir string, paths []string) error {
+	out, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for _, path := range paths {
+		if err := addToTar(tw, dir, path); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func addToTar(tw *tar.Writer, dir, path string) error {
+	name, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
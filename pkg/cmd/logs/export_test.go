@@ -0,0 +1,252 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLogFileSinkRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	sink := newLogFileSink(dir, 8, time.Minute, "")
+	sink.now = func() time.Time { return now }
+	ref := corev1.ObjectReference{Namespace: "test", Name: "web-1"}
+	w := sink.writer("east", "app", ref)
+	if sink.writer("east", "app", ref) != w {
+		t.Errorf("expected the same writer for the same container")
+	}
+
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		w.Write([]byte(line))
+	}
+	now = now.Add(time.Minute)
+	w.Write([]byte("four\n"))
+	sink.Close()
+	if sink.err != nil {
+		t.Fatal(sink.err)
+	}
+	if _, err := w.Write([]byte("five\n")); err != errSinkClosed {
+		t.Errorf("expected writes after closing to fail, got %v", err)
+	}
+
+	base := filepath.Join(dir, "east", "test", "web-1")
+	expected := map[string]string{
+		"app.log":   "one\ntwo\n",
+		"app.1.log": "three\n",
+		"app.2.log": "four\n",
+	}
+	for name, content := range expected {
+		if actual := readFile(t, filepath.Join(base, name)); actual != content {
+			t.Errorf("%s: expected %q, got %q", name, content, actual)
+		}
+	}
+	if sink.result != dir {
+		t.Errorf("expected the result %s, got %s", dir, sink.result)
+	}
+}
+
+func TestLogExport(t *testing.T) {
+	tests := []struct {
+		name          string
+		archive       string
+		expectedFiles map[string]string
+	}{
+		{
+			name: "files",
+			expectedFiles: map[string]string{
+				"east/test/web-1/app.log":     "app log\n",
+				"east/test/web-1/sidecar.log": "sidecar log\n",
+			},
+		},
+		{
+			name:    "gzip",
+			archive: archiveGzip,
+			expectedFiles: map[string]string{
+				"east/test/web-1/app.log.gz":     "app log\n",
+				"east/test/web-1/sidecar.log.gz": "sidecar log\n",
+			},
+		},
+		{
+			name:    "tar.gz",
+			archive: archiveTarGz,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmp, err := ioutil.TempDir("", "logs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmp)
+			dir := filepath.Join(tmp, "incident")
+
+			streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+			mock := &logTestMock{
+				logsForObjectRequests: map[corev1.ObjectReference]restclient.ResponseWrapper{
+					{Kind: "Pod", Namespace: "test", Name: "web-1", FieldPath: "spec.containers{app}"}:     &responseWrapperMock{data: strings.NewReader("app log\n")},
+					{Kind: "Pod", Namespace: "test", Name: "web-1", FieldPath: "spec.containers{sidecar}"}: &responseWrapperMock{data: strings.NewReader("sidecar log\n")},
+				},
+			}
+			o := NewLogsOptions(streams, true)
+			o.ClusterName = "east"
+			o.Object = testPod()
+			o.Options = &corev1.PodLogOptions{}
+			o.LogsForObject = mock.mockLogsForObject
+			o.ConsumeRequestFn = mock.mockConsumeRequest
+			o.sink = newLogFileSink(dir, 0, 0, test.archive)
+
+			if err := o.RunLogs(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if errOut.Len() > 0 {
+				t.Errorf("unexpected error output: %q", errOut.String())
+			}
+
+			for name, content := range test.expectedFiles {
+				path := filepath.Join(dir, name)
+				if test.archive == archiveGzip {
+					file, err := os.Open(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer file.Close()
+					gz, err := gzip.NewReader(file)
+					if err != nil {
+						t.Fatal(err)
+					}
+					data, _ := ioutil.ReadAll(gz)
+					if string(data) != content {
+						t.Errorf("%s: expected %q, got %q", name, content, string(data))
+					}
+					if _, err := os.Stat(strings.TrimSuffix(path, ".gz")); !os.IsNotExist(err) {
+						t.Errorf("expected %s to be removed", strings.TrimSuffix(name, ".gz"))
+					}
+				} else if actual := readFile(t, path); actual != content {
+					t.Errorf("%s: expected %q, got %q", name, content, actual)
+				}
+			}
+
+			expectedResult := dir
+			if test.archive == archiveTarGz {
+				expectedResult = dir + ".tar.gz"
+				entries := readTarGz(t, expectedResult)
+				expected := map[string]string{
+					"east/test/web-1/app.log":     "app log\n",
+					"east/test/web-1/sidecar.log": "sidecar log\n",
+				}
+				if len(entries) != len(expected) {
+					t.Errorf("expected %d entries, got %v", len(expected), entries)
+				}
+				for name, content := range expected {
+					if entries[name] != content {
+						t.Errorf("%s: expected %q, got %q", name, content, entries[name])
+					}
+				}
+			}
+			if out.String() != "Logs exported to "+expectedResult+"\n" {
+				t.Errorf("unexpected output: %q", out.String())
+			}
+		})
+	}
+}
+
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		entries[header.Name] = string(data)
+	}
+}
+
+func TestValidateLogExportOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     func(o *LogsOptions)
+		expected string
+	}{
+		{
+			name:     "archive without output dir",
+			opts:     func(o *LogsOptions) { o.Archive = archiveGzip },
+			expected: "can only be used with --output-dir",
+		},
+		{
+			name: "unknown archive",
+			opts: func(o *LogsOptions) {
+				o.OutputDir = "incident"
+				o.Archive = "zip"
+			},
+			expected: "--archive must be one of gzip|tar.gz",
+		},
+	}
+	for _, test := range tests {
+		o := NewLogsOptions(genericclioptions.NewTestIOStreamsDiscard(), false)
+		test.opts(o)
+		var err error
+		o.Options, err = o.ToLogOptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Validate(); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.expected, err)
+		}
+	}
+}
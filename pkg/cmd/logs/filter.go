@@ -0,0 +1,222 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+type logLevel int
+
+const (
+	levelUnknown logLevel = iota
+	levelTrace
+	levelDebug
+	levelInfo
+	levelWarn
+	levelError
+	levelFatal
+)
+
+var (
+	logLevels = map[string]logLevel{
+		"trace":    levelTrace,
+		"debug":    levelDebug,
+		"info":     levelInfo,
+		"warn":     levelWarn,
+		"warning":  levelWarn,
+		"error":    levelError,
+		"err":      levelError,
+		"fatal":    levelFatal,
+		"panic":    levelFatal,
+		"critical": levelFatal,
+		"crit":     levelFatal,
+	}
+
+	// klogLevels maps the first character of a klog header like "E0601 10:00:01.000000"
+	klogLevels = map[byte]logLevel{
+		'I': levelInfo,
+		'W': levelWarn,
+		'E': levelError,
+		'F': levelFatal,
+	}
+
+	// jsonLevelKeys are the fields structured loggers commonly keep the level in
+	jsonLevelKeys = []string{"level", "lvl", "severity"}
+
+	klogHeaderRegexp = regexp.MustCompile(`^[IWEF]\d{4} `)
+	logfmtRegexp     = regexp.MustCompile(`(?i)\blevel=["']?(\w+)`)
+	levelWordRegexp  = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|PANIC|CRITICAL)\b`)
+)
+
+// logFilter selects the log lines to print. It is shared by all streams, the
+// state of a single stream is kept by its filteringWriter.
+type logFilter struct {
+	grep     *regexp.Regexp
+	exclude  *regexp.Regexp
+	minLevel logLevel
+	before   int
+	after    int
+	// timestamps is true if the lines start with the timestamp added by the
+	// kubelet, which is not matched against
+	timestamps bool
+}
+
+func newLogFilter(grep, exclude, level string, before, after int, timestamps bool) (*logFilter, error) {
+	if len(grep) == 0 && len(exclude) == 0 && len(level) == 0 {
+		return nil, nil
+	}
+
+	filter := &logFilter{before: before, after: after, timestamps: timestamps}
+	var err error
+	if len(grep) > 0 {
+		if filter.grep, err = regexp.Compile(grep); err != nil {
+			return nil, fmt.Errorf("invalid --grep pattern %q: %v", grep, err)
+		}
+	}
+	if len(exclude) > 0 {
+		if filter.exclude, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("invalid --exclude pattern %q: %v", exclude, err)
+		}
+	}
+	if len(level) > 0 {
+		var ok bool
+		if filter.minLevel, ok = logLevels[strings.ToLower(level)]; !ok {
+			return nil, fmt.Errorf("invalid --level %q, must be one of trace|debug|info|warn|error|fatal", level)
+		}
+	}
+	return filter, nil
+}
+
+func (f *logFilter) matches(message []byte, level logLevel) bool {
+	if f.grep != nil && !f.grep.Match(message) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.Match(message) {
+		return false
+	}
+	return level >= f.minLevel
+}
+
+// filterIfNeeded wraps the writer of a single stream with the --grep,
+// --exclude and --level filters
+func (o LogsOptions) filterIfNeeded(writer io.Writer) io.Writer {
+	if o.filter == nil {
+		return writer
+	}
+	return &filteringWriter{filter: o.filter, writer: writer}
+}
+
+// filteringWriter drops the lines of a single stream that don't match the
+// filter, except for the context lines around matches. Like the prefixingWriter
+// it expects to be given whole lines.
+type filteringWriter struct {
+	filter *logFilter
+	writer io.Writer
+
+	// level is the level of the last line with a level, which lines without
+	// one, e.g. stack traces, inherit
+	level logLevel
+	// before holds up to filter.before lines preceding the next match
+	before [][]byte
+	// afterLeft is the number of lines to print after the last match
+	afterLeft int
+}
+
+func (w *filteringWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *filteringWriter) writeLine(line []byte) error {
+	message := line
+	if w.filter.timestamps {
+		if _, withoutTimestamp, ok := parseTimestamp(line); ok {
+			message = withoutTimestamp
+		}
+	}
+	message = bytes.TrimRight(message, "\r\n")
+	if level := parseLevel(message); level != levelUnknown {
+		w.level = level
+	}
+
+	switch {
+	case w.filter.matches(message, w.level):
+		for _, previous := range w.before {
+			if _, err := w.writer.Write(previous); err != nil {
+				return err
+			}
+		}
+		w.before = w.before[:0]
+		w.afterLeft = w.filter.after
+		_, err := w.writer.Write(line)
+		return err
+	case w.afterLeft > 0:
+		w.afterLeft--
+		_, err := w.writer.Write(line)
+		return err
+	case w.filter.before > 0:
+		if len(w.before) == w.filter.before {
+			w.before = append(w.before[:0], w.before[1:]...)
+		}
+		w.before = append(w.before, append([]byte(nil), line...))
+	}
+	return nil
+}
+
+// parseLevel returns the level of a log line. JSON lines are expected to keep
+// it in one of the jsonLevelKeys, text lines may start with a klog header, use
+// logfmt or contain an upper case level like "ERROR".
+func parseLevel(message []byte) logLevel {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(trimmed, &fields); err == nil {
+			for _, key := range jsonLevelKeys {
+				if value, ok := fields[key].(string); ok {
+					return logLevels[strings.ToLower(value)]
+				}
+			}
+			return levelUnknown
+		}
+	}
+
+	if klogHeaderRegexp.Match(message) {
+		return klogLevels[message[0]]
+	}
+	if matches := logfmtRegexp.FindSubmatch(message); matches != nil {
+		if level, ok := logLevels[strings.ToLower(string(matches[1]))]; ok {
+			return level
+		}
+	}
+	if matches := levelWordRegexp.FindSubmatch(message); matches != nil {
+		return logLevels[strings.ToLower(string(matches[1]))]
+	}
+	return levelUnknown
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected logLevel
+	}{
+		{message: `{"level":"WARN","msg":"slow"}`, expected: levelWarn},
+		{message: `{"severity":"error"}`, expected: levelError},
+		{message: `{"msg":"no level","text":"ERROR"}`, expected: levelUnknown},
+		{message: `E0601 10:00:01.000000       1 controller.go:42] failed`, expected: levelError},
+		{message: `I0601 10:00:01.000000       1 controller.go:42] synced`, expected: levelInfo},
+		{message: `time=2021-06-01T10:00:01Z level=debug msg="cache miss"`, expected: levelDebug},
+		{message: `2021/06/01 10:00:01 [WARNING] disk almost full`, expected: levelWarn},
+		{message: `request failed with an error`, expected: levelUnknown},
+		{message: `    at com.example.Main.run(Main.java:42)`, expected: levelUnknown},
+	}
+	for _, test := range tests {
+		if level := parseLevel([]byte(test.message)); level != test.expected {
+			t.Errorf("%q: expected level %d, got %d", test.message, test.expected, level)
+		}
+	}
+}
+
+func TestFilteringWriter(t *testing.T) {
+	lines := []string{
+		"INFO starting\n",
+		"DEBUG config loaded\n",
+		"ERROR connection timeout\n",
+		"    at connect()\n",
+		"INFO retrying\n",
+		"INFO connected\n",
+		"WARN request timeout\n",
+		"INFO done",
+	}
+	tests := []struct {
+		name       string
+		grep       string
+		exclude    string
+		level      string
+		before     int
+		after      int
+		timestamps bool
+		input      []string
+		expected   string
+	}{
+		{
+			name:     "grep",
+			grep:     "timeout",
+			input:    lines,
+			expected: "ERROR connection timeout\nWARN request timeout\n",
+		},
+		{
+			name:     "exclude",
+			exclude:  "^INFO",
+			input:    lines,
+			expected: "DEBUG config loaded\nERROR connection timeout\n    at connect()\nWARN request timeout\n",
+		},
+		{
+			name:     "level inherited by continuation lines",
+			level:    "warn",
+			input:    lines,
+			expected: "ERROR connection timeout\n    at connect()\nWARN request timeout\n",
+		},
+		{
+			name:     "grep and level",
+			grep:     "timeout",
+			level:    "error",
+			input:    lines,
+			expected: "ERROR connection timeout\n",
+		},
+		{
+			name:     "context",
+			grep:     "timeout",
+			before:   1,
+			after:    1,
+			input:    lines,
+			expected: "DEBUG config loaded\nERROR connection timeout\n    at connect()\nINFO connected\nWARN request timeout\nINFO done",
+		},
+		{
+			name:     "overlapping context is printed once",
+			grep:     "INFO (retrying|connected)",
+			before:   2,
+			after:    2,
+			input:    lines,
+			expected: "ERROR connection timeout\n    at connect()\nINFO retrying\nINFO connected\nWARN request timeout\nINFO done",
+		},
+		{
+			name:       "timestamps are not matched",
+			grep:       "^ERROR",
+			timestamps: true,
+			input:      []string{"2021-06-01T10:00:01Z INFO ok\n2021-06-01T10:00:02Z ERROR failed\n"},
+			expected:   "2021-06-01T10:00:02Z ERROR failed\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := newLogFilter(test.grep, test.exclude, test.level, test.before, test.after, test.timestamps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := NewLogsOptions(genericclioptions.NewTestIOStreamsDiscard(), false)
+			o.filter = filter
+			out := &bytes.Buffer{}
+			w := o.filterIfNeeded(out)
+			for _, input := range test.input {
+				if n, err := w.Write([]byte(input)); err != nil || n != len(input) {
+					t.Fatalf("unexpected write result: %d, %v", n, err)
+				}
+			}
+			if out.String() != test.expected {
+				t.Errorf("expected:\n%q\ngot:\n%q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestNewLogFilter(t *testing.T) {
+	if filter, err := newLogFilter("", "", "", 3, 3, false); filter != nil || err != nil {
+		t.Errorf("expected no filter without --grep, --exclude or --level, got %v, %v", filter, err)
+	}
+	for _, args := range [][]string{{"(", "", ""}, {"", "[", ""}, {"", "", "loud"}} {
+		if _, err := newLogFilter(args[0], args[1], args[2], 0, 0, false); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+}
+
+func TestLogFilterFollow(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewLogsOptions(streams, false)
+	o.Follow = true
+	o.Prefix = true
+	o.IgnoreLogErrors = true
+	o.Object = testPod()
+	o.Options = &corev1.PodLogOptions{}
+
+	var err error
+	o.filter, err = newLogFilter("timeout", "", "", 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := &logTestMock{
+		logsForObjectRequests: map[corev1.ObjectReference]restclient.ResponseWrapper{
+			{Kind: "Pod", Name: "web-1", FieldPath: "spec.containers{app}"}: &responseWrapperMock{data: strings.NewReader("ok\nread timeout\n")},
+			{Kind: "Pod", Name: "web-2", FieldPath: "spec.containers{app}"}: &responseWrapperMock{err: errors.New("stream closed")},
+		},
+	}
+	o.LogsForObject = mock.mockLogsForObject
+	o.ConsumeRequestFn = mock.mockConsumeRequest
+
+	if err := o.RunLogs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// errors are not filtered
+	for _, expected := range []string{"[pod/web-1/app] read timeout\n", "error: stream closed\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected to contain %q. Output: %q", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "ok") {
+		t.Errorf("expected lines not matching --grep to be dropped. Output: %q", out.String())
+	}
+}
@@ -0,0 +1,448 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// followRetryInterval is how long to wait before reopening a stream that
+	// ended, and the initial backoff after an error
+	followRetryInterval = time.Second
+	followMaxBackoff    = 30 * time.Second
+)
+
+// followsSelector returns true if the pods matching the selector are tracked
+// while following. Merging by timestamp needs a fixed set of streams, so it
+// keeps following the pods found on start.
+func (o LogsOptions) followsSelector() bool {
+	return o.Follow && len(o.Selector) > 0 && !o.MergeByTimestamp
+}
+
+// syncWriter serializes the writes of concurrent streams. Every write is a
+// whole line, so lines don't interleave.
+type syncWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Write(p)
+}
+
+// streamKey identifies the log stream of a container across its restarts
+type streamKey struct {
+	pod       types.UID
+	container string
+}
+
+// streamState is the state of the log stream of a single container. It is
+// owned by the stream goroutine while active and by the follower otherwise.
+type streamState struct {
+	active bool
+	// restartCount is the restart count of the container when the stream was opened
+	restartCount int32
+	// ended is true if the stream of the restartCount instance reached its end
+	ended    bool
+	retryAt  time.Time
+	failures int
+
+	// last is the timestamp of the last line written and seenAtLast the number
+	// of lines written with that timestamp, which are skipped when resuming
+	last       time.Time
+	seenAtLast int
+}
+
+type streamResult struct {
+	key streamKey
+	err error
+}
+
+// podFollower follows the logs of the pods matching the selector. It watches
+// the pods to attach to pods and containers as they appear, and reopens the
+// streams of restarted containers and streams that failed. Streams are resumed
+// with SinceTime and the lines already written are skipped.
+type podFollower struct {
+	o             *LogsOptions
+	out           io.Writer
+	selector      labels.Selector
+	retryInterval time.Duration
+
+	// initial holds the pods found on start, whose logs are requested with
+	// the given --tail, --since and --since-time. The logs of pods created
+	// later are followed from their beginning.
+	initial    map[types.UID]bool
+	pods       map[types.UID]*corev1.Pod
+	containers map[types.UID][]string
+	streams    map[streamKey]*streamState
+	results    chan streamResult
+	active     int
+	limited    bool
+}
+
+func (o LogsOptions) newPodFollower(out io.Writer) (*podFollower, error) {
+	selector, err := labels.Parse(o.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return &podFollower{
+		o:             &o,
+		out:           out,
+		selector:      selector,
+		retryInterval: followRetryInterval,
+		pods:          map[types.UID]*corev1.Pod{},
+		containers:    map[types.UID][]string{},
+		streams:       map[streamKey]*streamState{},
+		results:       make(chan streamResult),
+	}, nil
+}
+
+// followPods follows the logs of the pods matching the selector until the context is done
+func (o LogsOptions) followPods(ctx context.Context, out io.Writer) error {
+	if o.PodClient == nil {
+		return fmt.Errorf("unable to watch the pods matching %q", o.Selector)
+	}
+	follower, err := o.newPodFollower(out)
+	if err != nil {
+		return err
+	}
+	return follower.run(ctx)
+}
+
+func (f *podFollower) run(ctx context.Context) error {
+	listOptions := metav1.ListOptions{LabelSelector: f.o.Selector}
+	for {
+		list, err := f.o.PodClient.Pods(f.o.Namespace).List(ctx, listOptions)
+		if err != nil {
+			return err
+		}
+		// start watching before any stream, so that no pod created after the
+		// first lines were written is missed
+		watchOptions := listOptions
+		watchOptions.ResourceVersion = list.ResourceVersion
+		w, err := f.o.PodClient.Pods(f.o.Namespace).Watch(ctx, watchOptions)
+		if err != nil {
+			return err
+		}
+
+		listed := map[types.UID]bool{}
+		for i := range list.Items {
+			listed[list.Items[i].UID] = true
+		}
+		if f.initial == nil {
+			f.initial = listed
+		}
+		for uid := range f.pods {
+			if !listed[uid] {
+				f.removePod(uid)
+			}
+		}
+		for i := range list.Items {
+			if err := f.setPod(&list.Items[i]); err != nil {
+				w.Stop()
+				return err
+			}
+		}
+		f.reconcile(ctx)
+
+		err = f.watch(ctx, w)
+		w.Stop()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watch handles the pod events and the ended streams until the watch expires
+func (f *podFollower) watch(ctx context.Context, w watch.Interface) error {
+	ticker := time.NewTicker(f.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				pod, ok := event.Object.(*corev1.Pod)
+				if !ok {
+					continue
+				}
+				if !f.selector.Matches(labels.Set(pod.Labels)) {
+					f.removePod(pod.UID)
+					continue
+				}
+				if err := f.setPod(pod); err != nil {
+					return err
+				}
+			case watch.Deleted:
+				if pod, ok := event.Object.(*corev1.Pod); ok {
+					f.removePod(pod.UID)
+				}
+			case watch.Error:
+				// the watch expired, list the pods again
+				klog.V(2).Infof("Watching the pods matching %q failed: %v", f.o.Selector, event.Object)
+				return nil
+			}
+		case result := <-f.results:
+			f.streamEnded(result)
+		case <-ticker.C:
+		}
+		f.reconcile(ctx)
+	}
+}
+
+// setPod stores the latest state of a pod and looks up the containers to follow
+func (f *podFollower) setPod(pod *corev1.Pod) error {
+	f.pods[pod.UID] = pod
+	if _, ok := f.containers[pod.UID]; ok {
+		return nil
+	}
+
+	requests, err := f.o.LogsForObject(f.o.RESTClientGetter, pod, f.logOptions(pod, ""), f.o.GetPodTimeout, f.o.AllContainers)
+	if err != nil {
+		if !f.o.IgnoreLogErrors {
+			return err
+		}
+		fmt.Fprintf(f.o.errorWriter(f.out), "error: %v\n", err)
+	}
+	names := []string{}
+	for ref := range requests {
+		names = append(names, f.o.containerName(ref))
+	}
+	sort.Strings(names)
+	f.containers[pod.UID] = names
+	return nil
+}
+
+func (f *podFollower) removePod(uid types.UID) {
+	delete(f.pods, uid)
+	delete(f.containers, uid)
+	for key, state := range f.streams {
+		if key.pod == uid && !state.active {
+			delete(f.streams, key)
+		}
+	}
+}
+
+func (f *podFollower) streamEnded(result streamResult) {
+	f.active--
+	state := f.streams[result.key]
+	state.active = false
+	if _, ok := f.pods[result.key.pod]; !ok {
+		delete(f.streams, result.key)
+		return
+	}
+
+	if result.err != nil {
+		klog.V(2).Infof("Following the logs of container %s failed, retrying: %v", result.key.container, result.err)
+		state.failures++
+		backoff := f.retryInterval << uint(state.failures-1)
+		if backoff > followMaxBackoff || backoff <= 0 {
+			backoff = followMaxBackoff
+		}
+		state.retryAt = time.Now().Add(backoff)
+		return
+	}
+	state.failures = 0
+	state.ended = true
+	state.retryAt = time.Now().Add(f.retryInterval)
+}
+
+// reconcile opens the streams of all followed containers that have logs and
+// no open stream, up to MaxFollowConcurrency
+func (f *podFollower) reconcile(ctx context.Context) {
+	uids := make([]string, 0, len(f.pods))
+	for uid := range f.pods {
+		uids = append(uids, string(uid))
+	}
+	sort.Strings(uids)
+
+	now := time.Now()
+	waiting := false
+	for _, uid := range uids {
+		pod := f.pods[types.UID(uid)]
+		for _, container := range f.containers[pod.UID] {
+			key := streamKey{pod: pod.UID, container: container}
+			state, ok := f.streams[key]
+			if !ok {
+				state = &streamState{}
+				f.streams[key] = state
+			}
+
+			status := containerStatus(pod, container)
+			if state.active || status == nil || (status.State.Running == nil && status.State.Terminated == nil) {
+				continue
+			}
+			// the stream of a terminated container already reached its end
+			if state.ended && status.RestartCount == state.restartCount && status.State.Running == nil {
+				continue
+			}
+			if now.Before(state.retryAt) {
+				continue
+			}
+			if f.active >= f.o.MaxFollowConcurrency {
+				waiting = true
+				continue
+			}
+
+			f.start(ctx, pod, container, key, state, status.RestartCount)
+		}
+	}
+
+	if waiting && !f.limited {
+		fmt.Fprintf(f.o.ErrOut, "not following all containers, the maximum allowed concurrency of %d is reached, use --max-log-requests to increase the limit\n", f.o.MaxFollowConcurrency)
+	}
+	f.limited = waiting
+}
+
+func (f *podFollower) start(ctx context.Context, pod *corev1.Pod, container string, key streamKey, state *streamState, restartCount int32) {
+	state.active = true
+	state.ended = false
+	state.restartCount = restartCount
+	f.active++
+
+	options := f.logOptions(pod, container)
+	if !state.last.IsZero() {
+		options.SinceTime = &metav1.Time{Time: state.last}
+		options.SinceSeconds = nil
+		options.TailLines = nil
+	}
+
+	go func() {
+		err := f.stream(pod, options, state)
+		select {
+		case f.results <- streamResult{key: key, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (f *podFollower) stream(pod *corev1.Pod, options *corev1.PodLogOptions, state *streamState) error {
+	requests, err := f.o.LogsForObject(f.o.RESTClientGetter, pod, options, f.o.GetPodTimeout, false)
+	if err != nil {
+		return err
+	}
+	for ref, request := range requests {
+		out := &resumeWriter{
+			state:           state,
+			skip:            state.seenAtLast,
+			stripTimestamps: !f.o.requestTimestamps(),
+			writer:          f.o.filterIfNeeded(f.o.logWriter(ref, f.out)),
+		}
+		if err := f.o.ConsumeRequestFn(request, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logOptions returns the options to request the logs of a container of pod with.
+// The lines are always requested with timestamps to resume streams.
+func (f *podFollower) logOptions(pod *corev1.Pod, container string) *corev1.PodLogOptions {
+	options := f.o.Options.(*corev1.PodLogOptions).DeepCopy()
+	options.Follow = true
+	options.Timestamps = true
+	if len(container) > 0 {
+		options.Container = container
+	}
+	if !f.initial[pod.UID] {
+		options.SinceTime = nil
+		options.SinceSeconds = nil
+		options.TailLines = nil
+	}
+	return options
+}
+
+func containerStatus(pod *corev1.Pod, container string) *corev1.ContainerStatus {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses, pod.Status.EphemeralContainerStatuses} {
+		for i := range statuses {
+			if statuses[i].Name == container {
+				return &statuses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// resumeWriter skips the lines of a resumed stream that were already written
+// and keeps track of the last timestamp written. SinceTime has a precision of
+// seconds, so a resumed stream repeats the lines of the last second.
+type resumeWriter struct {
+	state           *streamState
+	skip            int
+	stripTimestamps bool
+	writer          io.Writer
+}
+
+func (w *resumeWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *resumeWriter) writeLine(line []byte) error {
+	timestamp, message, ok := parseTimestamp(line)
+	if ok {
+		switch {
+		case timestamp.Before(w.state.last):
+			return nil
+		case timestamp.Equal(w.state.last):
+			if w.skip > 0 {
+				w.skip--
+				return nil
+			}
+			w.state.seenAtLast++
+		default:
+			w.skip = 0
+			w.state.last = timestamp
+			w.state.seenAtLast = 1
+		}
+		if w.stripTimestamps {
+			line = message
+		}
+	}
+
+	_, err := w.writer.Write(line)
+	return err
+}
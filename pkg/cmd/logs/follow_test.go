@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/kubernetes/fake"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type safeBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+// fakeContainerLogs serves the logs of containers like the kubelet does:
+// filtered by SinceTime with a precision of seconds and by TailLines
+type fakeContainerLogs struct {
+	lock sync.Mutex
+	// lines holds the timestamped lines of every pod/container
+	lines map[string][]string
+}
+
+func (l *fakeContainerLogs) append(container string, lines ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines[container] = append(l.lines[container], lines...)
+}
+
+func (l *fakeContainerLogs) logsForObject(restClientGetter genericclioptions.RESTClientGetter, object, options runtime.Object, timeout time.Duration, allContainers bool) (map[corev1.ObjectReference]restclient.ResponseWrapper, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	pod := object.(*corev1.Pod)
+	opts := options.(*corev1.PodLogOptions)
+	containers := []string{pod.Spec.Containers[0].Name}
+	if len(opts.Container) > 0 {
+		containers = []string{opts.Container}
+	}
+
+	requests := map[corev1.ObjectReference]restclient.ResponseWrapper{}
+	for _, container := range containers {
+		lines := []string{}
+		for _, line := range l.lines[pod.Name+"/"+container] {
+			timestamp, _, _ := parseTimestamp([]byte(line))
+			if opts.SinceTime != nil && timestamp.Before(opts.SinceTime.Time.Truncate(time.Second)) {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if opts.TailLines != nil && int(*opts.TailLines) < len(lines) {
+			lines = lines[len(lines)-int(*opts.TailLines):]
+		}
+		ref := corev1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, FieldPath: fmt.Sprintf("spec.containers{%s}", container)}
+		requests[ref] = &responseWrapperMock{data: strings.NewReader(strings.Join(lines, ""))}
+	}
+	return requests, nil
+}
+
+func followTestPod(name string, running bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", UID: types.UID(name), Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "app",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+		}}},
+	}
+	if running {
+		pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	}
+	return pod
+}
+
+func waitForOutput(t *testing.T, out *safeBuffer, expected string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected to contain %q. Output: %q", expected, out.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollowPods(t *testing.T) {
+	logs := &fakeContainerLogs{lines: map[string][]string{
+		"web-1/app": {
+			"2021-06-01T10:00:01.1Z a\n",
+			"2021-06-01T10:00:01.5Z b\n",
+		},
+	}}
+	other := followTestPod("other", true)
+	other.Labels = map[string]string{"app": "db"}
+	client := fake.NewSimpleClientset(followTestPod("web-1", true), other)
+
+	tail := int64(1)
+	o := NewLogsOptions(genericclioptions.NewTestIOStreamsDiscard(), false)
+	o.Follow = true
+	o.Prefix = true
+	o.Selector = "app=web"
+	o.Namespace = "test"
+	o.Options = &corev1.PodLogOptions{TailLines: &tail}
+	o.PodClient = client.CoreV1()
+	o.LogsForObject = logs.logsForObject
+	o.ConsumeRequestFn = DefaultConsumeRequest
+
+	out := &safeBuffer{}
+	follower, err := o.newPodFollower(&syncWriter{writer: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	follower.retryInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- follower.run(ctx)
+	}()
+
+	// the initial pods are followed with --tail
+	waitForOutput(t, out, "[pod/web-1/app] b\n")
+
+	// a line with the same timestamp as the last one is written once the stream is reopened
+	logs.append("web-1/app", "2021-06-01T10:00:01.5Z c\n", "2021-06-01T10:00:02Z d\n")
+	waitForOutput(t, out, "[pod/web-1/app] d\n")
+
+	// new pods are followed from their beginning once their containers start
+	logs.append("web-2/app", "2021-06-01T10:00:03Z e\n", "2021-06-01T10:00:04Z f\n")
+	pods := client.CoreV1().Pods("test")
+	if _, err := pods.Create(context.TODO(), followTestPod("web-2", false), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if strings.Contains(out.String(), "web-2") {
+		t.Errorf("expected a waiting container not to be followed. Output: %q", out.String())
+	}
+	if _, err := pods.UpdateStatus(context.TODO(), followTestPod("web-2", true), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, out, "[pod/web-2/app] f\n")
+
+	// give the follower time to reopen the streams a few more times
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"[pod/web-1/app] b\n",
+		"[pod/web-1/app] c\n",
+		"[pod/web-1/app] d\n",
+		"[pod/web-2/app] e\n",
+		"[pod/web-2/app] f\n",
+	}
+	lines := strings.SplitAfter(out.String(), "\n")
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("expected every line exactly once, got %q", out.String())
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected to contain %q. Output: %q", line, out.String())
+		}
+	}
+}
+
+func TestResumeWriter(t *testing.T) {
+	state := &streamState{}
+	out := &bytes.Buffer{}
+	first := &resumeWriter{state: state, stripTimestamps: true, writer: out}
+	for _, line := range []string{"2021-06-01T10:00:01.5Z a\n", "2021-06-01T10:00:02Z b\n", "2021-06-01T10:00:02Z c\n"} {
+		first.Write([]byte(line))
+	}
+
+	resumed := &resumeWriter{state: state, skip: state.seenAtLast, stripTimestamps: true, writer: out}
+	resumed.Write([]byte("2021-06-01T10:00:01.5Z a\n2021-06-01T10:00:02Z b\n2021-06-01T10:00:02Z c\n2021-06-01T10:00:02Z d\n2021-06-01T10:00:03Z e\n"))
+
+	expected := "a\nb\nc\nd\ne\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
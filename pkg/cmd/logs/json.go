@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// jsonOutput returns true if the log lines should be printed as JSON lines
+func (o LogsOptions) jsonOutput() bool {
+	return o.Output == "json" || o.Output == "jsonl"
+}
+
+// logEntry is a single log line printed with -o json
+type logEntry struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Timestamp string `json:"timestamp,omitempty"`
+	// Message is the log line as a string, or the parsed object if the
+	// container logs JSON objects
+	Message interface{} `json:"message"`
+}
+
+func (o LogsOptions) newJSONLineWriter(ref corev1.ObjectReference, writer io.Writer) io.Writer {
+	return &jsonLineWriter{
+		entry: logEntry{
+			Cluster:   o.ClusterName,
+			Namespace: ref.Namespace,
+			Pod:       ref.Name,
+			Container: o.containerName(ref),
+		},
+		writer: writer,
+	}
+}
+
+// jsonLineWriter encodes every line written to it as a logEntry. Like the
+// prefixingWriter it expects to be given whole lines.
+type jsonLineWriter struct {
+	entry  logEntry
+	writer io.Writer
+}
+
+func (w *jsonLineWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *jsonLineWriter) writeLine(line []byte) error {
+	entry := w.entry
+	entry.Timestamp = ""
+	if timestamp, message, ok := parseTimestamp(line); ok {
+		entry.Timestamp = timestamp.Format(time.RFC3339Nano)
+		line = message
+	}
+
+	message := bytes.TrimRight(line, "\r\n")
+	if trimmed := bytes.TrimSpace(message); len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		entry.Message = json.RawMessage(trimmed)
+	} else {
+		entry.Message = string(message)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// write the entry at once so that it doesn't interleave with other
+	// entries when used concurrently with io.PipeWriter
+	_, err = w.writer.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestJSONLineWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected string
+	}{
+		{
+			name:     "plain message",
+			input:    []string{"2021-06-01T10:00:01.5Z hello \"world\"\n"},
+			expected: `{"cluster":"east","namespace":"test","pod":"web-1","container":"app","timestamp":"2021-06-01T10:00:01.5Z","message":"hello \"world\""}` + "\n",
+		},
+		{
+			name:     "json message",
+			input:    []string{"2021-06-01T10:00:01Z {\"level\": \"error\", \"msg\": \"boom\"}\r\n"},
+			expected: `{"cluster":"east","namespace":"test","pod":"web-1","container":"app","timestamp":"2021-06-01T10:00:01Z","message":{"level":"error","msg":"boom"}}` + "\n",
+		},
+		{
+			name:     "invalid json message",
+			input:    []string{"{not json\n"},
+			expected: `{"cluster":"east","namespace":"test","pod":"web-1","container":"app","message":"{not json"}` + "\n",
+		},
+		{
+			name:  "several lines in one write and a trailing line without newline",
+			input: []string{"a\nb\n", "c"},
+			expected: `{"cluster":"east","namespace":"test","pod":"web-1","container":"app","message":"a"}` + "\n" +
+				`{"cluster":"east","namespace":"test","pod":"web-1","container":"app","message":"b"}` + "\n" +
+				`{"cluster":"east","namespace":"test","pod":"web-1","container":"app","message":"c"}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := NewLogsOptions(genericclioptions.NewTestIOStreamsDiscard(), false)
+			o.ClusterName = "east"
+			o.Output = "json"
+			out := &bytes.Buffer{}
+			w := o.logWriter(corev1.ObjectReference{Kind: "Pod", Namespace: "test", Name: "web-1", FieldPath: "spec.containers{app}"}, out)
+			for _, input := range test.input {
+				if n, err := w.Write([]byte(input)); err != nil || n != len(input) {
+					t.Fatalf("unexpected write result: %d, %v", n, err)
+				}
+			}
+			if out.String() != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestLogJSONOutput(t *testing.T) {
+	streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+	mock := &logTestMock{
+		logsForObjectRequests: map[corev1.ObjectReference]restclient.ResponseWrapper{
+			{Kind: "Pod", Namespace: "test", Name: "web-1", FieldPath: "spec.containers{app}"}: &responseWrapperMock{data: strings.NewReader("2021-06-01T10:00:01Z started\n")},
+			{Kind: "Pod", Namespace: "test", Name: "web-2", FieldPath: "spec.containers{app}"}: &responseWrapperMock{data: strings.NewReader("")},
+		},
+	}
+	o := NewLogsOptions(streams, false)
+	o.Output = "jsonl"
+	o.Prefix = true
+	o.IgnoreLogErrors = true
+	o.LogsForObject = mock.mockLogsForObject
+	o.ConsumeRequestFn = func(request restclient.ResponseWrapper, out io.Writer) error {
+		if err := mock.mockConsumeRequest(request, out); err != nil {
+			return err
+		}
+		if out.(*jsonLineWriter).entry.Pod == "web-2" {
+			return errors.New("container is waiting to start")
+		}
+		return nil
+	}
+
+	logOptions, err := o.ToLogOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !logOptions.Timestamps {
+		t.Errorf("expected -o jsonl to request timestamps")
+	}
+	o.Options = logOptions
+	o.Object = testPod()
+
+	if err := o.RunLogs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"namespace":"test","pod":"web-1","container":"app","timestamp":"2021-06-01T10:00:01Z","message":"started"}` + "\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if errOut.String() != "error: container is waiting to start\n" {
+		t.Errorf("expected the ignored error on stderr, got %q", errOut.String())
+	}
+}
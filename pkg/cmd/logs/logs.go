@@ -29,17 +29,22 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	coreclient "github.com/Angus-F/client-go/kubernetes/typed/core/v1"
 	"github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/kubectl/pkg/audit"
 	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
 	"github.com/Angus-F/kubectl/pkg/polymorphichelpers"
 	"github.com/Angus-F/kubectl/pkg/scheme"
 	"github.com/Angus-F/kubectl/pkg/util"
+	"github.com/Angus-F/kubectl/pkg/util/completion"
 	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/interrupt"
 	"github.com/Angus-F/kubectl/pkg/util/templates"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"github.com/Angus-F/kubectl/pkg/configs"
 )
 
 const (
@@ -84,7 +89,22 @@ var (
 		kubectl logs job/hello
 
 		# Return snapshot logs from container nginx-1 of a deployment named nginx
-		kubectl logs deployment/nginx -c nginx-1`))
+		kubectl logs deployment/nginx -c nginx-1
+
+		# Begin streaming the logs of pods defined by label app=nginx in every cluster whose name starts with prod-
+		kubectl logs -f -lapp=nginx -C 'prod-*'
+
+		# Print the logs of pods defined by label app=nginx as JSON lines and keep the errors only
+		kubectl logs -lapp=nginx -o jsonl | jq 'select(.message.level == "error")'
+
+		# Begin streaming the warnings and errors of pod nginx that mention a timeout, with 3 lines of context after each
+		kubectl logs -f nginx --level=warn --grep=timeout -A 3
+
+		# Write the logs of pods defined by label app=nginx to one file per container and bundle them into incident.tar.gz
+		kubectl logs -lapp=nginx --all-containers --output-dir=incident --archive=tar.gz
+
+		# Begin streaming the logs of pods defined by label app=nginx to files that are rotated every 100Mi
+		kubectl logs -f -lapp=nginx --output-dir=/var/log/nginx --rotate-size=100Mi`))
 
 	selectorTail    int64 = 10
 	logsUsageErrStr       = fmt.Sprintf("expected '%s'.\nPOD or TYPE/NAME is a required argument for the logs command", logsUsageStr)
@@ -120,11 +140,29 @@ type LogsOptions struct {
 	Selector               string
 	MaxFollowConcurrency   int
 	Prefix                 bool
+	MergeByTimestamp       bool
+	ReorderWindow          time.Duration
+	Output                 string
+
+	// client-side filters, see logFilter
+	Grep          string
+	Exclude       string
+	Level         string
+	BeforeContext int
+	AfterContext  int
+
+	// export to files, see logFileSink
+	OutputDir      string
+	RotateSize     string
+	RotateInterval time.Duration
+	Archive        string
 
 	Object           runtime.Object
 	GetPodTimeout    time.Duration
 	RESTClientGetter genericclioptions.RESTClientGetter
 	LogsForObject    polymorphichelpers.LogsForObjectFunc
+	// PodClient watches the pods matching the selector while following
+	PodClient coreclient.PodsGetter
 
 	genericclioptions.IOStreams
 
@@ -133,7 +171,20 @@ type LogsOptions struct {
 	containerNameFromRefSpecRegexp *regexp.Regexp
 
 	ClusterName string
-	Configs map[string]string
+	AllClusters bool
+
+	// clusterTargets holds one completed LogsOptions per cluster when
+	// several clusters were selected
+	clusterTargets []*LogsOptions
+	// prefixCluster adds the cluster name to the prefix of every log line
+	prefixCluster bool
+	// filter is built from the filter flags, nil if none is set
+	filter *logFilter
+	// sink writes the logs to files with --output-dir
+	sink *logFileSink
+
+	// Auditor, if set, logs every run to the audit log
+	Auditor audit.Logger
 }
 
 func NewLogsOptions(streams genericclioptions.IOStreams, allContainers bool) *LogsOptions {
@@ -142,6 +193,7 @@ func NewLogsOptions(streams genericclioptions.IOStreams, allContainers bool) *Lo
 		AllContainers:        allContainers,
 		Tail:                 -1,
 		MaxFollowConcurrency: 5,
+		ReorderWindow:        defaultReorderWindow,
 
 		containerNameFromRefSpecRegexp: regexp.MustCompile(`spec\.(?:initContainers|containers|ephemeralContainers){(.+)}`),
 	}
@@ -150,6 +202,7 @@ func NewLogsOptions(streams genericclioptions.IOStreams, allContainers bool) *Lo
 // NewCmdLogs creates a new pod logs command
 func NewCmdLogs(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
 	o := NewLogsOptions(streams, false)
+	o.Auditor = audit.NewDefaultLogger()
 
 	cmd := &cobra.Command{
 		Use:                   logsUsageStr,
@@ -157,6 +210,7 @@ func NewCmdLogs(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 		Short:                 i18n.T("Print the logs for a container in a pod"),
 		Long:                  logsLong,
 		Example:               logsExample,
+		ValidArgsFunction:     completion.PodNameCompletionFunc(f, configs.CommandLogs),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd, args))
 			cmdutil.CheckErr(o.Validate())
@@ -164,6 +218,7 @@ func NewCmdLogs(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 		},
 	}
 	o.AddFlags(cmd)
+	cmdutil.CheckErr(cmd.RegisterFlagCompletionFunc("container", completion.ContainerCompletionFunc(f, configs.CommandLogs)))
 	return cmd
 }
 
@@ -182,9 +237,27 @@ func (o *LogsOptions) AddFlags(cmd *cobra.Command) {
 		"Skip verifying the identity of the kubelet that logs are requested from.  In theory, an attacker could provide invalid log content back. You might want to use this if your kubelet serving certificates have expired.")
 	cmdutil.AddPodRunningTimeoutFlag(cmd, defaultPodLogsTimeout)
 	cmd.Flags().StringVarP(&o.Selector, "selector", "l", o.Selector, "Selector (label query) to filter on.")
-	cmd.Flags().IntVar(&o.MaxFollowConcurrency, "max-log-requests", o.MaxFollowConcurrency, "Specify maximum number of concurrent logs to follow when using by a selector. Defaults to 5.")
+	cmd.Flags().IntVar(&o.MaxFollowConcurrency, "max-log-requests", o.MaxFollowConcurrency, "Specify maximum number of concurrent logs to follow when using by a selector, per cluster when several clusters are selected. Defaults to 5.")
 	cmd.Flags().BoolVar(&o.Prefix, "prefix", o.Prefix, "Prefix each log line with the log source (pod name and container name)")
-	cmdutil.AddClusterVarFlags(cmd, &o.ClusterName, o.ClusterName)
+	cmd.Flags().BoolVar(&o.MergeByTimestamp, "merge-by-timestamp", o.MergeByTimestamp, "If true, merge the lines of several log streams in timestamp order. Timestamps are only printed if --timestamps is set. When following by selector, only the pods found on start are followed.")
+	cmd.Flags().DurationVar(&o.ReorderWindow, "reorder-window", o.ReorderWindow, "How long to hold back lines of a followed stream while waiting for older lines of other streams when using --merge-by-timestamp")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format. One of: json|jsonl. Both print one JSON object with the cluster, namespace, pod, container, timestamp and message of every log line.")
+	cmd.Flags().StringVar(&o.Grep, "grep", o.Grep, "Only print log lines matching this regular expression.")
+	cmd.Flags().StringVar(&o.Exclude, "exclude", o.Exclude, "Do not print log lines matching this regular expression.")
+	cmd.Flags().StringVar(&o.Level, "level", o.Level, "Only print log lines of this level or a more severe one. One of: trace|debug|info|warn|error|fatal. The level is read from the level, lvl or severity field of JSON lines and from the text otherwise. Lines without a level, e.g. stack traces, take the level of the line before them.")
+	cmd.Flags().IntVarP(&o.BeforeContext, "before-context", "B", o.BeforeContext, "Print this many lines before every line selected by --grep, --exclude or --level.")
+	cmd.Flags().IntVarP(&o.AfterContext, "after-context", "A", o.AfterContext, "Print this many lines after every line selected by --grep, --exclude or --level.")
+	cmd.Flags().StringVar(&o.OutputDir, "output-dir", o.OutputDir, "If set, write the logs of every container to <output-dir>/<cluster>/<namespace>/<pod>/<container>.log instead of stdout.")
+	cmd.Flags().StringVar(&o.RotateSize, "rotate-size", o.RotateSize, "Start a new file when a file written with --output-dir reaches this size, e.g. 100Mi. Rotated files are named <container>.1.log, <container>.2.log and so on.")
+	cmd.Flags().DurationVar(&o.RotateInterval, "rotate-interval", o.RotateInterval, "Start a new file when a file written with --output-dir is older than this duration, e.g. 1h.")
+	cmd.Flags().StringVar(&o.Archive, "archive", o.Archive, "Compress the files written with --output-dir when done. One of: gzip|tar.gz. gzip compresses every file, tar.gz bundles them into <output-dir>.tar.gz.")
+	cmdutil.AddClusterListVarFlags(cmd, &o.ClusterName, o.ClusterName, &o.AllClusters)
+}
+
+// requestTimestamps returns true if the kubelet should add timestamps to the
+// log lines, which are also needed to merge streams and for JSON output
+func (o *LogsOptions) requestTimestamps() bool {
+	return o.Timestamps || o.MergeByTimestamp || o.jsonOutput()
 }
 
 func (o *LogsOptions) ToLogOptions() (*corev1.PodLogOptions, error) {
@@ -192,7 +265,7 @@ func (o *LogsOptions) ToLogOptions() (*corev1.PodLogOptions, error) {
 		Container:                    o.Container,
 		Follow:                       o.Follow,
 		Previous:                     o.Previous,
-		Timestamps:                   o.Timestamps,
+		Timestamps:                   o.requestTimestamps(),
 		InsecureSkipTLSVerifyBackend: o.InsecureSkipTLSVerifyBackend,
 	}
 
@@ -246,60 +319,86 @@ func (o *LogsOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 		return cmdutil.UsageErrorf(cmd, "%s", logsUsageErrStr)
 	}
 
-	o.Configs = make(map[string]string)
-	if len(configs.ClusterName) != len(configs.ConfigContent) {
-		return fmt.Errorf("the numbers of ClusterName and the ConfigContent is unmatched")
+	var err error
+	o.ConsumeRequestFn = DefaultConsumeRequest
+
+	o.GetPodTimeout, err = cmdutil.GetPodRunningTimeoutFlag(cmd)
+	if err != nil {
+		return err
 	}
-	if len(configs.ClusterName) == 0 || len(configs.ConfigContent) == 0 {
-		return fmt.Errorf("fail to find configs to set")
+
+	o.Options, err = o.ToLogOptions()
+	if err != nil {
+		return err
 	}
 
-	for i := 0; i < len(configs.ClusterName); i++ {
-		o.Configs[configs.ClusterName[i]] = configs.ConfigContent[i]
+	o.filter, err = newLogFilter(o.Grep, o.Exclude, o.Level, o.BeforeContext, o.AfterContext, o.requestTimestamps())
+	if err != nil {
+		return err
 	}
 
+	o.LogsForObject = polymorphichelpers.LogsForObjectFn
 
-	if len(o.ClusterName) > 0 {
-		flag := false
-		for filename := range o.Configs {
-			if filename == o.ClusterName {
-				flag = true
-				break
+	if len(o.OutputDir) > 0 {
+		var rotateSize int64
+		if len(o.RotateSize) > 0 {
+			quantity, err := resource.ParseQuantity(o.RotateSize)
+			if err != nil {
+				return fmt.Errorf("invalid --rotate-size %q: %v", o.RotateSize, err)
+			}
+			rotateSize = quantity.Value()
+			if rotateSize <= 0 {
+				return fmt.Errorf("--rotate-size must be greater than 0")
 			}
 		}
-		if !flag {
-			return fmt.Errorf("the clusterName can not be found")
-		}
-	} else {
-		return fmt.Errorf("Please set the clusterName")
+		o.sink = newLogFileSink(o.OutputDir, rotateSize, o.RotateInterval, o.Archive)
 	}
 
-	ClientConfig, _ := f.NewClientConfigFromBytesWithConfigFlags([]byte(o.Configs[o.ClusterName]))
-	f.SetClientConfig(&ClientConfig)
+	if !o.AllClusters && !cmdutil.IsClusterList(o.ClusterName) {
+		return o.completeCluster(f, o.ClusterName)
+	}
 
-	var err error
-	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	clusterNames, err := cmdutil.ResolveClusterNames(f, o.ClusterName, o.AllClusters)
 	if err != nil {
 		return err
 	}
+	o.clusterTargets = make([]*LogsOptions, 0, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		target := *o
+		target.clusterTargets = nil
+		target.Prefix = true
+		target.prefixCluster = true
+		if err := target.completeCluster(f, clusterName); err != nil {
+			return fmt.Errorf("cluster %s: %v", clusterName, err)
+		}
+		o.clusterTargets = append(o.clusterTargets, &target)
+	}
+	return nil
+}
 
-	o.ConsumeRequestFn = DefaultConsumeRequest
-
-	o.GetPodTimeout, err = cmdutil.GetPodRunningTimeoutFlag(cmd)
+// completeCluster binds the options to a single cluster and looks up the object to get logs from
+func (o *LogsOptions) completeCluster(f cmdutil.Factory, clusterName string) error {
+	client, err := f.ClientForCluster(clusterName)
 	if err != nil {
 		return err
 	}
-
-	o.Options, err = o.ToLogOptions()
-	if err != nil {
+	o.ClusterName = client.Name
+	o.Namespace = client.Namespace
+	if err := client.CheckAccess(configs.Access{Command: configs.CommandLogs, Namespace: o.Namespace}); err != nil {
 		return err
 	}
+	o.RESTClientGetter = client
 
-	o.RESTClientGetter = f
-	o.LogsForObject = polymorphichelpers.LogsForObjectFn
+	if o.followsSelector() {
+		clientset, err := client.KubernetesClientSet()
+		if err != nil {
+			return err
+		}
+		o.PodClient = clientset.CoreV1()
+	}
 
 	if o.Object == nil {
-		builder := f.NewBuilder().
+		builder := client.NewBuilder().
 			WithScheme(scheme.Scheme, scheme.Scheme.PrioritizedVersionsAllGroups()...).
 			NamespaceParam(o.Namespace).DefaultNamespace().
 			SingleResourceType()
@@ -318,7 +417,11 @@ func (o *LogsOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 		}
 		o.Object = infos[0].Object
 		if o.Selector != "" && len(o.Object.(*corev1.PodList).Items) == 0 {
-			fmt.Fprintf(o.ErrOut, "No resources found in %s namespace.\n", o.Namespace)
+			if o.prefixCluster {
+				fmt.Fprintf(o.ErrOut, "No resources found in %s namespace of cluster %s.\n", o.Namespace, o.ClusterName)
+			} else {
+				fmt.Fprintf(o.ErrOut, "No resources found in %s namespace.\n", o.Namespace)
+			}
 		}
 	}
 
@@ -346,6 +449,30 @@ func (o LogsOptions) Validate() error {
 		return fmt.Errorf("--limit-bytes must be greater than 0")
 	}
 
+	if len(o.Output) > 0 && !o.jsonOutput() {
+		return fmt.Errorf("unexpected -o output mode: %s, the flag 'output' must be one of json|jsonl", o.Output)
+	}
+
+	if len(o.OutputDir) == 0 && (len(o.RotateSize) > 0 || o.RotateInterval != 0 || len(o.Archive) > 0) {
+		return fmt.Errorf("--rotate-size, --rotate-interval and --archive can only be used with --output-dir")
+	}
+
+	if len(o.Archive) > 0 && o.Archive != archiveGzip && o.Archive != archiveTarGz {
+		return fmt.Errorf("--archive must be one of gzip|tar.gz")
+	}
+
+	if o.RotateInterval < 0 {
+		return fmt.Errorf("--rotate-interval must be greater than or equal to 0")
+	}
+
+	if o.BeforeContext < 0 || o.AfterContext < 0 {
+		return fmt.Errorf("--before-context and --after-context must be greater than or equal to 0")
+	}
+
+	if o.ReorderWindow < 0 {
+		return fmt.Errorf("--reorder-window must be greater than or equal to 0")
+	}
+
 	if logsOptions.SinceSeconds != nil && *logsOptions.SinceSeconds < int64(0) {
 		return fmt.Errorf("--since must be greater than 0")
 	}
@@ -359,34 +486,103 @@ func (o LogsOptions) Validate() error {
 
 // RunLogs retrieves a pod log
 func (o LogsOptions) RunLogs() error {
+	var audited *auditedLogs
+	if o.Auditor != nil {
+		audited = o.startAudit()
+	}
+	// runLogs is bound after startAudit, which wraps ConsumeRequestFn
+	run := o.runLogs
+	notify := []func(){}
+	if audited != nil {
+		run = audited.track(run)
+		notify = append(notify, audited.finish)
+	}
+	if o.sink != nil {
+		// close the files and archive them when done, or when following is interrupted
+		notify = append(notify, func() {
+			o.sink.Close()
+			if o.sink.err == nil {
+				fmt.Fprintf(o.Out, "Logs exported to %s\n", o.sink.result)
+			}
+		})
+	}
+	if len(notify) == 0 {
+		return run()
+	}
+
+	if err := interrupt.New(nil, notify...).Run(run); err != nil {
+		return err
+	}
+	if o.sink != nil {
+		return o.sink.err
+	}
+	return nil
+}
+
+func (o LogsOptions) runLogs() error {
+	if len(o.clusterTargets) > 0 {
+		return o.runLogsInClusters()
+	}
+
+	if o.followsSelector() {
+		return o.followPods(context.TODO(), &syncWriter{writer: o.Out})
+	}
+
 	requests, err := o.LogsForObject(o.RESTClientGetter, o.Object, o.Options, o.GetPodTimeout, o.AllContainers)
 	if err != nil {
 		return err
 	}
 
-	if o.Follow && len(requests) > 1 {
-		if len(requests) > o.MaxFollowConcurrency {
-			return fmt.Errorf(
-				"you are attempting to follow %d log streams, but maximum allowed concurrency is %d, use --max-log-requests to increase the limit",
-				len(requests), o.MaxFollowConcurrency,
-			)
-		}
+	if o.Follow && len(requests) > 1 && len(requests) > o.MaxFollowConcurrency {
+		return fmt.Errorf(
+			"you are attempting to follow %d log streams, but maximum allowed concurrency is %d, use --max-log-requests to increase the limit",
+			len(requests), o.MaxFollowConcurrency,
+		)
+	}
 
+	return o.consumeRequests(o.logRequests(requests))
+}
+
+// consumeRequests merges the requests in timestamp order with --merge-by-timestamp,
+// consumes them concurrently when following and one after another otherwise.
+// A single request is merged too, the merger strips the timestamps requested
+// for merging.
+func (o LogsOptions) consumeRequests(requests []logRequest) error {
+	switch {
+	case o.MergeByTimestamp:
+		return o.mergeConsumeRequest(requests)
+	case o.Follow && len(requests) > 1:
 		return o.parallelConsumeRequest(requests)
+	default:
+		return o.sequentialConsumeRequest(requests)
 	}
+}
 
-	return o.sequentialConsumeRequest(requests)
+// logRequest is the log request of a single container together with the
+// options it was made with, which decide how its lines are prefixed
+type logRequest struct {
+	source  *LogsOptions
+	ref     corev1.ObjectReference
+	request rest.ResponseWrapper
+}
+
+func (o LogsOptions) logRequests(requests map[corev1.ObjectReference]rest.ResponseWrapper) []logRequest {
+	result := make([]logRequest, 0, len(requests))
+	for ref, request := range requests {
+		result = append(result, logRequest{source: &o, ref: ref, request: request})
+	}
+	return result
 }
 
-func (o LogsOptions) parallelConsumeRequest(requests map[corev1.ObjectReference]rest.ResponseWrapper) error {
+func (o LogsOptions) parallelConsumeRequest(requests []logRequest) error {
 	reader, writer := io.Pipe()
 	wg := &sync.WaitGroup{}
 	wg.Add(len(requests))
-	for objRef, request := range requests {
-		go func(objRef corev1.ObjectReference, request rest.ResponseWrapper) {
+	for _, r := range requests {
+		go func(r logRequest) {
 			defer wg.Done()
-			out := o.addPrefixIfNeeded(objRef, writer)
-			if err := o.ConsumeRequestFn(request, out); err != nil {
+			out := r.source.filterIfNeeded(r.source.logWriter(r.ref, writer))
+			if err := o.ConsumeRequestFn(r.request, out); err != nil {
 				if !o.IgnoreLogErrors {
 					writer.CloseWithError(err)
 
@@ -394,10 +590,10 @@ func (o LogsOptions) parallelConsumeRequest(requests map[corev1.ObjectReference]
 					return
 				}
 
-				fmt.Fprintf(writer, "error: %v\n", err)
+				fmt.Fprintf(o.errorWriter(writer), "error: %v\n", err)
 			}
 
-		}(objRef, request)
+		}(r)
 	}
 
 	go func() {
@@ -409,42 +605,71 @@ func (o LogsOptions) parallelConsumeRequest(requests map[corev1.ObjectReference]
 	return err
 }
 
-func (o LogsOptions) sequentialConsumeRequest(requests map[corev1.ObjectReference]rest.ResponseWrapper) error {
-	for objRef, request := range requests {
-		out := o.addPrefixIfNeeded(objRef, o.Out)
-		if err := o.ConsumeRequestFn(request, out); err != nil {
+func (o LogsOptions) sequentialConsumeRequest(requests []logRequest) error {
+	for _, r := range requests {
+		out := r.source.filterIfNeeded(r.source.logWriter(r.ref, o.Out))
+		if err := o.ConsumeRequestFn(r.request, out); err != nil {
 			if !o.IgnoreLogErrors {
 				return err
 			}
 
-			fmt.Fprintf(o.Out, "error: %v\n", err)
+			fmt.Fprintf(o.errorWriter(o.Out), "error: %v\n", err)
 		}
 	}
 
 	return nil
 }
 
+// logWriter returns the writer for the lines of a single container, which
+// encodes them as JSON with -o json and prefixes them if needed otherwise
+func (o LogsOptions) logWriter(ref corev1.ObjectReference, writer io.Writer) io.Writer {
+	if o.sink != nil {
+		writer = o.sink.writer(o.ClusterName, o.containerName(ref), ref)
+	}
+	if o.jsonOutput() {
+		return o.newJSONLineWriter(ref, writer)
+	}
+	return o.addPrefixIfNeeded(ref, writer)
+}
+
+// errorWriter returns the writer for errors that are ignored with --ignore-errors.
+// They go to ErrOut with -o json so that the output stays parseable, and with
+// --output-dir so that they don't end up in the log files.
+func (o LogsOptions) errorWriter(writer io.Writer) io.Writer {
+	if o.jsonOutput() || o.sink != nil {
+		return o.ErrOut
+	}
+	return writer
+}
+
 func (o LogsOptions) addPrefixIfNeeded(ref corev1.ObjectReference, writer io.Writer) io.Writer {
 	if !o.Prefix || ref.FieldPath == "" || ref.Name == "" {
 		return writer
 	}
 
-	// We rely on ref.FieldPath to contain a reference to a container
-	// including a container name (not an index) so we can get a container name
-	// without making an extra API request.
-	var containerName string
-	containerNameMatches := o.containerNameFromRefSpecRegexp.FindStringSubmatch(ref.FieldPath)
-	if len(containerNameMatches) == 2 {
-		containerName = containerNameMatches[1]
-	}
-
+	containerName := o.containerName(ref)
 	prefix := fmt.Sprintf("[pod/%s/%s] ", ref.Name, containerName)
+	if o.prefixCluster {
+		prefix = fmt.Sprintf("[%s/%s/%s] ", o.ClusterName, ref.Name, containerName)
+	}
 	return &prefixingWriter{
 		prefix: []byte(prefix),
 		writer: writer,
 	}
 }
 
+// containerName returns the name of the container ref points to.
+// We rely on ref.FieldPath to contain a reference to a container
+// including a container name (not an index) so we can get a container name
+// without making an extra API request.
+func (o LogsOptions) containerName(ref corev1.ObjectReference) string {
+	containerNameMatches := o.containerNameFromRefSpecRegexp.FindStringSubmatch(ref.FieldPath)
+	if len(containerNameMatches) == 2 {
+		return containerNameMatches[1]
+	}
+	return ""
+}
+
 // DefaultConsumeRequest reads the data from request and writes into
 // the out writer. It buffers data from requests until the newline or io.EOF
 // occurs in the data, so it doesn't interleave logs sub-line
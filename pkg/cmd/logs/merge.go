@@ -0,0 +1,269 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	defaultReorderWindow = 2 * time.Second
+)
+
+// timestampedLine is a single log line waiting in the merge buffer
+type timestampedLine struct {
+	timestamp time.Time
+	arrived   time.Time
+	stream    int
+	// seq is the stream in the high bits and the index of the line in the stream
+	seq uint64
+	// line is the log line with its timestamp, including the trailing newline
+	line []byte
+	// message is the log line without its timestamp
+	message []byte
+	out     io.Writer
+}
+
+// lineHeap orders buffered lines by timestamp. Equal timestamps are ordered by
+// stream, and by their order within the stream, which seq encodes.
+type lineHeap []*timestampedLine
+
+func (h lineHeap) Len() int { return len(h) }
+func (h lineHeap) Less(i, j int) bool {
+	if h[i].timestamp.Equal(h[j].timestamp) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].timestamp.Before(h[j].timestamp)
+}
+func (h lineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *lineHeap) Push(x interface{}) { *h = append(*h, x.(*timestampedLine)) }
+func (h *lineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// logMerger merges log streams requested with timestamps into a single stream
+// in timestamp order. A buffered line is written once every open stream has a
+// later line buffered, or once it has waited for the reorder window, so a quiet
+// stream delays the output by at most the window.
+type logMerger struct {
+	window          time.Duration
+	stripTimestamps bool
+	now             func() time.Time
+
+	lines chan *timestampedLine
+	done  chan int
+	errs  chan error
+	stop  chan struct{}
+}
+
+func newLogMerger(window time.Duration, stripTimestamps bool) *logMerger {
+	return &logMerger{
+		window:          window,
+		stripTimestamps: stripTimestamps,
+		now:             time.Now,
+		lines:           make(chan *timestampedLine),
+		done:            make(chan int),
+		errs:            make(chan error),
+		stop:            make(chan struct{}),
+	}
+}
+
+// mergeConsumeRequest consumes all requests concurrently and writes their lines
+// to o.Out in timestamp order.
+func (o LogsOptions) mergeConsumeRequest(requests []logRequest) error {
+	// the JSON writer parses the timestamps itself
+	merger := newLogMerger(o.ReorderWindow, !o.Timestamps && !o.jsonOutput())
+	defer close(merger.stop)
+
+	for i, r := range requests {
+		go func(stream int, r logRequest) {
+			w := &mergeWriter{merger: merger, stream: stream, out: r.source.logWriter(r.ref, o.Out)}
+			if err := o.ConsumeRequestFn(r.request, r.source.filterIfNeeded(w)); err != nil {
+				if !o.IgnoreLogErrors {
+					select {
+					case merger.errs <- err:
+					case <-merger.stop:
+					}
+					return
+				}
+
+				fmt.Fprintf(o.errorWriter(w), "error: %v\n", err)
+			}
+			w.flush()
+			select {
+			case merger.done <- stream:
+			case <-merger.stop:
+			}
+		}(i, r)
+	}
+
+	return merger.run(len(requests))
+}
+
+// run receives lines until all streams are done and writes them in order.
+func (m *logMerger) run(streams int) error {
+	pending := make([]int, streams)
+	finished := make([]bool, streams)
+	active := streams
+	buffered := &lineHeap{}
+
+	tick := m.window / 4
+	if tick <= 0 {
+		tick = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for active > 0 || buffered.Len() > 0 {
+		if active > 0 {
+			select {
+			case line := <-m.lines:
+				heap.Push(buffered, line)
+				pending[line.stream]++
+			case stream := <-m.done:
+				finished[stream] = true
+				active--
+			case err := <-m.errs:
+				return err
+			case <-ticker.C:
+			}
+		}
+
+		for buffered.Len() > 0 {
+			next := (*buffered)[0]
+			if !m.allBuffered(pending, finished) && m.now().Sub(next.arrived) < m.window {
+				break
+			}
+			heap.Pop(buffered)
+			pending[next.stream]--
+			if err := m.write(next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// allBuffered returns true if every stream that is still open has a line buffered,
+// so that no line older than the buffered ones can arrive anymore.
+func (m *logMerger) allBuffered(pending []int, finished []bool) bool {
+	for stream, count := range pending {
+		if !finished[stream] && count == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *logMerger) write(line *timestampedLine) error {
+	if m.stripTimestamps {
+		_, err := line.out.Write(line.message)
+		return err
+	}
+	_, err := line.out.Write(line.line)
+	return err
+}
+
+// mergeWriter splits the output of a single stream into timestamped lines and
+// hands them to the merger.
+type mergeWriter struct {
+	merger *logMerger
+	stream int
+	out    io.Writer
+
+	buf  []byte
+	last time.Time
+	seq  uint64
+}
+
+func (w *mergeWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := make([]byte, i+1)
+		copy(line, w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+		if err := w.send(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// flush hands over a trailing line without a newline.
+func (w *mergeWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.send(line)
+}
+
+func (w *mergeWriter) send(line []byte) error {
+	arrived := w.merger.now()
+	timestamp, message, ok := parseTimestamp(line)
+	if !ok {
+		// lines without a timestamp, e.g. errors, stay behind the previous line of the stream
+		timestamp, message = w.last, line
+		if timestamp.IsZero() {
+			timestamp = arrived
+		}
+	}
+	w.last = timestamp
+	w.seq++
+
+	select {
+	case w.merger.lines <- &timestampedLine{
+		timestamp: timestamp,
+		arrived:   arrived,
+		stream:    w.stream,
+		seq:       uint64(w.stream)<<40 | w.seq,
+		line:      line,
+		message:   message,
+		out:       w.out,
+	}:
+		return nil
+	case <-w.merger.stop:
+		return io.ErrClosedPipe
+	}
+}
+
+// parseTimestamp splits a line written with PodLogOptions.Timestamps into its
+// RFC3339 timestamp and the message.
+func parseTimestamp(line []byte) (time.Time, []byte, bool) {
+	i := bytes.IndexByte(line, ' ')
+	if i <= 0 {
+		return time.Time{}, nil, false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, string(line[:i]))
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return timestamp, line[i+1:], true
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	restclient "github.com/Angus-F/client-go/rest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func mergeTestRequests(o *LogsOptions, streams map[string]io.Reader) []logRequest {
+	requests := []logRequest{}
+	for pod, data := range streams {
+		requests = append(requests, logRequest{
+			source:  o,
+			ref:     corev1.ObjectReference{Kind: "Pod", Name: pod, FieldPath: "spec.containers{app}"},
+			request: &responseWrapperMock{data: data},
+		})
+	}
+	return requests
+}
+
+func TestMergeConsumeRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		timestamps bool
+		prefix     bool
+		streams    map[string]string
+		expected   string
+	}{
+		{
+			name: "strip timestamps",
+			streams: map[string]string{
+				"a": "2021-06-01T10:00:01Z a1\n2021-06-01T10:00:04Z a2\n",
+				"b": "2021-06-01T10:00:02Z b1\n2021-06-01T10:00:03Z b2\n2021-06-01T10:00:05Z b3",
+			},
+			expected: "a1\nb1\nb2\na2\nb3\n",
+		},
+		{
+			name:       "keep timestamps",
+			timestamps: true,
+			streams: map[string]string{
+				"a": "2021-06-01T10:00:01.5Z a1\n",
+				"b": "2021-06-01T10:00:01.25Z b1\n",
+			},
+			expected: "2021-06-01T10:00:01.25Z b1\n2021-06-01T10:00:01.5Z a1\n",
+		},
+		{
+			name:   "prefix",
+			prefix: true,
+			streams: map[string]string{
+				"a": "2021-06-01T10:00:02Z a1\n",
+				"b": "2021-06-01T10:00:01Z b1\n",
+			},
+			expected: "[pod/b/app] b1\n[pod/a/app] a1\n",
+		},
+		{
+			name: "lines without timestamps stay behind their predecessor",
+			streams: map[string]string{
+				"a": "2021-06-01T10:00:01Z a1\ncontinued\n2021-06-01T10:00:03Z a2\n",
+				"b": "2021-06-01T10:00:02Z b1\n",
+			},
+			expected: "a1\ncontinued\nb1\na2\n",
+		},
+		{
+			name: "single container",
+			streams: map[string]string{
+				"a": "2021-06-01T10:00:01Z a1\n2021-06-01T10:00:02Z a2\n",
+			},
+			expected: "a1\na2\n",
+		},
+		{
+			name:       "single container with timestamps",
+			timestamps: true,
+			streams: map[string]string{
+				"a": "2021-06-01T10:00:01Z a1\n",
+			},
+			expected: "2021-06-01T10:00:01Z a1\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+			o := NewLogsOptions(streams, false)
+			o.Timestamps = test.timestamps
+			o.Prefix = test.prefix
+			o.MergeByTimestamp = true
+			o.ConsumeRequestFn = DefaultConsumeRequest
+
+			readers := map[string]io.Reader{}
+			for pod, data := range test.streams {
+				readers[pod] = strings.NewReader(data)
+			}
+			requests := mergeTestRequests(o, readers)
+
+			if err := o.consumeRequests(requests); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != test.expected {
+				t.Errorf("expected:\n%q\ngot:\n%q", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+// channelWriter hands every write to a channel
+type channelWriter chan string
+
+func (w channelWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestMergeConsumeRequestReorderWindow(t *testing.T) {
+	out := make(channelWriter, 10)
+	o := NewLogsOptions(genericclioptions.IOStreams{Out: out, ErrOut: ioutil.Discard}, false)
+	o.MergeByTimestamp = true
+	o.ReorderWindow = 10 * time.Millisecond
+	o.ConsumeRequestFn = DefaultConsumeRequest
+
+	quietReader, quietWriter := io.Pipe()
+	activeReader, activeWriter := io.Pipe()
+	requests := mergeTestRequests(o, map[string]io.Reader{"quiet": quietReader, "active": activeReader})
+
+	errs := make(chan error)
+	go func() {
+		errs <- o.consumeRequests(requests)
+	}()
+
+	activeWriter.Write([]byte("2021-06-01T10:00:01Z first\n"))
+	select {
+	case line := <-out:
+		if line != "first\n" {
+			t.Errorf("unexpected line %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("a quiet stream held back the output for longer than the reorder window")
+	}
+
+	quietWriter.Close()
+	activeWriter.Close()
+	if err := <-errs; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMergeConsumeRequestError(t *testing.T) {
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+	o := NewLogsOptions(streams, false)
+	o.MergeByTimestamp = true
+	o.ConsumeRequestFn = func(request restclient.ResponseWrapper, out io.Writer) error {
+		return errors.New("stream failed")
+	}
+	requests := mergeTestRequests(o, map[string]io.Reader{"a": strings.NewReader(""), "b": strings.NewReader("")})
+
+	if err := o.consumeRequests(requests); err == nil || err.Error() != "stream failed" {
+		t.Errorf("expected the stream error, got %v", err)
+	}
+
+	ignoring := *o
+	ignoring.IgnoreLogErrors = true
+	requests = mergeTestRequests(&ignoring, map[string]io.Reader{"a": strings.NewReader(""), "b": strings.NewReader("")})
+	if err := ignoring.consumeRequests(requests); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "error: stream failed\n") {
+		t.Errorf("expected the error in the output, got %q", buf.String())
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		line            string
+		expectedMessage string
+		expectedOK      bool
+	}{
+		{line: "2021-06-01T10:00:01.123456789Z hello world\n", expectedMessage: "hello world\n", expectedOK: true},
+		{line: "2021-06-01T10:00:01+02:00 x\n", expectedMessage: "x\n", expectedOK: true},
+		{line: "hello world\n"},
+		{line: " leading space\n"},
+		{line: "\n"},
+	}
+	for _, test := range tests {
+		_, message, ok := parseTimestamp([]byte(test.line))
+		if ok != test.expectedOK || string(message) != test.expectedMessage {
+			t.Errorf("%q: expected (%q, %v), got (%q, %v)", test.line, test.expectedMessage, test.expectedOK, string(message), ok)
+		}
+	}
+}
+
+func TestMergeRequestsTimestamps(t *testing.T) {
+	o := NewLogsOptions(genericclioptions.NewTestIOStreamsDiscard(), false)
+	o.MergeByTimestamp = true
+	logOptions, err := o.ToLogOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !logOptions.Timestamps {
+		t.Errorf("expected --merge-by-timestamp to request timestamps")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/rest/fake"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func TestLogsPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		expectedErr string
+	}{
+		{
+			name:   "allowed",
+			policy: "clusters:\n- name: prod\n  commands: [logs]\n  namespaces:\n    allow: [test]\n",
+		},
+		{
+			name:        "command denied",
+			policy:      "clusters:\n- name: prod\n  commands: [exec, cp]\n",
+			expectedErr: `access denied by the access policy test for cluster "prod": logs is not allowed`,
+		},
+		{
+			name:        "namespace not allowed",
+			policy:      "clusters:\n- name: prod*\n  namespaces:\n    allow: [web]\n",
+			expectedErr: `logs is not allowed in namespace "test"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := configs.ParsePolicy([]byte(test.policy), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.PolicyVal = policy
+
+			requests := 0
+			codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+			tf.UnstructuredClient = &fake.RESTClient{
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					requests++
+					if req.URL.Path == "/namespaces/test/pods/foo" && req.Method == "GET" {
+						return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, testPod())}, nil
+					}
+					t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+					return nil, fmt.Errorf("unexpected request")
+				}),
+			}
+
+			streams := genericclioptions.NewTestIOStreamsDiscard()
+			cmd := NewCmdLogs(tf, streams)
+			o := NewLogsOptions(streams, false)
+			o.ClusterName = "prod"
+			err = o.Complete(tf, cmd, []string{"foo"})
+
+			if len(test.expectedErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if o.Object == nil {
+					t.Errorf("expected the pod to be looked up")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+			}
+			if requests != 0 {
+				t.Errorf("expected no request before the denial, got %d", requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package portforward
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/rest/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+// fakeAuditor keeps the entries, the targets of a spec log concurrently
+type fakeAuditor struct {
+	lock    sync.Mutex
+	entries []*audit.Entry
+}
+
+func (a *fakeAuditor) Log(entry *audit.Entry) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+func TestPortForwardAudit(t *testing.T) {
+	tests := []struct {
+		name         string
+		pfErr        error
+		expectedErr  string
+		expectedExit int
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:         "failure",
+			pfErr:        errors.New("pf error"),
+			expectedErr:  "pf error",
+			expectedExit: -1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+
+			codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+			tf.Client = &fake.RESTClient{
+				VersionedAPIPath:     "/api/v1",
+				GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					if req.URL.Path != "/api/v1/namespaces/test/pods/foo" || req.Method != "GET" {
+						t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+					}
+					return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, execPod())}, nil
+				}),
+			}
+			tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+
+			auditor := &fakeAuditor{}
+			opts := &PortForwardOptions{ClusterName: "dev"}
+			cmd := NewCmdPortForward(tf, genericclioptions.NewTestIOStreamsDiscard())
+			if err := opts.Complete(tf, cmd, []string{"foo", "8080:80"}); err != nil {
+				t.Fatal(err)
+			}
+			opts.PortForwarder = &fakePortForwarder{pfErr: test.pfErr}
+			opts.Auditor = auditor
+			if err := opts.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := opts.RunPortForward(); err != test.pfErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if len(auditor.entries) != 1 {
+				t.Fatalf("expected 1 audit entry, got %d", len(auditor.entries))
+			}
+			entry := auditor.entries[0]
+			if entry.Action != "port-forward" || entry.ClusterName != "dev" || entry.Namespace != "test" || entry.Pod != "foo" {
+				t.Errorf("unexpected entry %#v", entry)
+			}
+			if !reflect.DeepEqual(entry.Ports, []string{"8080:80"}) {
+				t.Errorf("expected the forwarded ports, got %v", entry.Ports)
+			}
+			if entry.Error != test.expectedErr || entry.ExitCode != test.expectedExit {
+				t.Errorf("expected error %q and exit code %d, got %q and %d", test.expectedErr, test.expectedExit, entry.Error, entry.ExitCode)
+			}
+			if entry.End.Before(entry.Start) {
+				t.Errorf("expected the entry to end after it started, got %v to %v", entry.Start, entry.End)
+			}
+		})
+	}
+}
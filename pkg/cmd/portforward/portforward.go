@@ -19,6 +19,7 @@ package portforward
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -38,15 +39,19 @@ import (
 	restclient "github.com/Angus-F/client-go/rest"
 	"github.com/Angus-F/client-go/tools/portforward"
 	"github.com/Angus-F/client-go/transport/spdy"
+	"github.com/Angus-F/kubectl/pkg/audit"
 	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
 	"github.com/Angus-F/kubectl/pkg/polymorphichelpers"
 	"github.com/Angus-F/kubectl/pkg/util"
+	"github.com/Angus-F/kubectl/pkg/util/completion"
 	"github.com/Angus-F/kubectl/pkg/util/i18n"
 	"github.com/Angus-F/kubectl/pkg/util/templates"
 )
 
 // PortForwardOptions contains all the options for running the port-forward cli command.
 type PortForwardOptions struct {
+	ClusterName   string
 	Namespace     string
 	PodName       string
 	RESTClient    *restclient.RESTClient
@@ -57,6 +62,22 @@ type PortForwardOptions struct {
 	PortForwarder portForwarder
 	StopChannel   chan struct{}
 	ReadyChannel  chan struct{}
+	GetPodTimeout time.Duration
+	// Auditor, if set, logs every forward to the audit log
+	Auditor audit.Logger
+
+	// Filename is a spec of several targets to forward at once
+	Filename string
+	// RetryInterval is the pause before a target of the spec reconnects
+	RetryInterval time.Duration
+	// PollInterval is how often the pods of the spec are checked for replacement
+	PollInterval time.Duration
+	// targets are the targets of the spec
+	targets []*forwardTarget
+	// out shows the status table of the spec
+	out io.Writer
+	// errOut is where a failure to write the audit log is reported
+	errOut io.Writer
 }
 
 var (
@@ -67,7 +88,12 @@ var (
 
                 If there are multiple pods matching the criteria, a pod will be selected automatically. The
                 forwarding session ends when the selected pod terminates, and rerun of the command is needed
-                to resume forwarding.`))
+                to resume forwarding.
+
+                With --filename, the targets of a YAML spec are forwarded at once, from any cluster. A target
+                reconnects when its pod is replaced, selecting a new pod, and a table shows the status of
+                every target. The spec lists the targets under "forwards", each with a resource and ports,
+                and optionally a name, clusterName, namespace and address.`))
 
 	portforwardExample = templates.Examples(i18n.T(`
 		# Listen on ports 5000 and 6000 locally, forwarding data to/from ports 5000 and 6000 in the pod
@@ -89,12 +115,33 @@ var (
 		kubectl port-forward --address localhost,10.19.21.23 pod/mypod 8888:5000
 
 		# Listen on a random port locally, forwarding to 5000 in the pod
-		kubectl port-forward pod/mypod :5000`))
+		kubectl port-forward pod/mypod :5000
+
+		# Listen on port 8888 locally, forwarding to 5000 in the pod of cluster prod
+		kubectl port-forward -C prod pod/mypod 8888:5000
+
+		# Forward the targets of a spec, reconnecting them when their pods are replaced
+		cat > forwards.yaml <<EOF
+		forwards:
+		- name: db
+		  clusterName: prod
+		  namespace: payments
+		  resource: service/postgres
+		  ports: ["5432"]
+		- clusterName: dev
+		  resource: deployment/web
+		  ports: ["8080:80", "8443:443"]
+		EOF
+		kubectl port-forward -f forwards.yaml`))
 )
 
 const (
 	// Amount of time to wait until at least one pod is running
 	defaultPodPortForwardWaitTimeout = 60 * time.Second
+	// Amount of time to wait before a target of a spec reconnects
+	defaultRetryInterval = 2 * time.Second
+	// How often the pods of a spec are checked for replacement
+	defaultPollInterval = 2 * time.Second
 )
 
 func NewCmdPortForward(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
@@ -102,13 +149,19 @@ func NewCmdPortForward(f cmdutil.Factory, streams genericclioptions.IOStreams) *
 		PortForwarder: &defaultPortForwarder{
 			IOStreams: streams,
 		},
+		Auditor:       audit.NewDefaultLogger(),
+		RetryInterval: defaultRetryInterval,
+		PollInterval:  defaultPollInterval,
+		out:           streams.Out,
+		errOut:        streams.ErrOut,
 	}
 	cmd := &cobra.Command{
-		Use:                   "port-forward TYPE/NAME [options] [LOCAL_PORT:]REMOTE_PORT [...[LOCAL_PORT_N:]REMOTE_PORT_N]",
+		Use:                   "port-forward (TYPE/NAME [options] [LOCAL_PORT:]REMOTE_PORT [...[LOCAL_PORT_N:]REMOTE_PORT_N] | -f FILENAME)",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Forward one or more local ports to a pod"),
 		Long:                  portforwardLong,
 		Example:               portforwardExample,
+		ValidArgsFunction:     completion.PodNameCompletionFunc(f, configs.CommandPortForward),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(opts.Complete(f, cmd, args))
 			cmdutil.CheckErr(opts.Validate())
@@ -117,6 +170,8 @@ func NewCmdPortForward(f cmdutil.Factory, streams genericclioptions.IOStreams) *
 	}
 	cmdutil.AddPodRunningTimeoutFlag(cmd, defaultPodPortForwardWaitTimeout)
 	cmd.Flags().StringSliceVar(&opts.Address, "address", []string{"localhost"}, "Addresses to listen on (comma separated). Only accepts IP addresses or localhost as a value. When localhost is supplied, kubectl will try to bind on both 127.0.0.1 and ::1 and will fail if neither of these addresses are available to bind.")
+	cmd.Flags().StringVarP(&opts.Filename, "filename", "f", opts.Filename, "A YAML spec of the targets to forward at once, which reconnect when their pods are replaced")
+	cmdutil.AddClusterVarFlags(cmd, &opts.ClusterName, opts.ClusterName)
 	// TODO support UID
 	return cmd
 }
@@ -287,77 +342,96 @@ func checkUDPPortInPod(ports []string, pod *corev1.Pod) error {
 	return checkUDPPorts(udpPorts.Difference(tcpPorts), ports, pod)
 }
 
-// Complete completes all the required options for port-forward cmd.
-func (o *PortForwardOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
-	var err error
-	if len(args) < 2 {
-		return cmdutil.UsageErrorf(cmd, "TYPE/NAME and list of ports are required for port-forward")
-	}
-
-	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
-	if err != nil {
-		return err
-	}
-
+// forwardablePod returns the pod selected by the resource TYPE/NAME in
+// namespace, and the ports translated to the container ports of the pod.
+func forwardablePod(f cmdutil.Factory, namespace, resourceName string, ports []string, getPodTimeout time.Duration) (*corev1.Pod, []string, error) {
 	builder := f.NewBuilder().
 		WithScheme(scheme.Scheme, scheme.Scheme.PrioritizedVersionsAllGroups()...).
 		ContinueOnError().
-		NamespaceParam(o.Namespace).DefaultNamespace()
+		NamespaceParam(namespace).DefaultNamespace()
 
-	getPodTimeout, err := cmdutil.GetPodRunningTimeoutFlag(cmd)
-	if err != nil {
-		return cmdutil.UsageErrorf(cmd, err.Error())
-	}
-
-	resourceName := args[0]
 	builder.ResourceNames("pods", resourceName)
 
 	obj, err := builder.Do().Object()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	forwardablePod, err := polymorphichelpers.AttachablePodForObjectFn(f, obj, getPodTimeout)
+	pod, err := polymorphichelpers.AttachablePodForObjectFn(f, obj, getPodTimeout)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	o.PodName = forwardablePod.Name
-
 	// handle service port mapping to target port if needed
 	switch t := obj.(type) {
 	case *corev1.Service:
-		err = checkUDPPortInService(args[1:], t)
+		err = checkUDPPortInService(ports, t)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		o.Ports, err = translateServicePortToTargetPort(args[1:], *t, *forwardablePod)
+		ports, err = translateServicePortToTargetPort(ports, *t, *pod)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 	default:
-		err = checkUDPPortInPod(args[1:], forwardablePod)
+		err = checkUDPPortInPod(ports, pod)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		o.Ports, err = convertPodNamedPortToNumber(args[1:], *forwardablePod)
+		ports, err = convertPodNamedPortToNumber(ports, *pod)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 	}
+	return pod, ports, nil
+}
 
-	clientset, err := f.KubernetesClientSet()
+// Complete completes all the required options for port-forward cmd.
+func (o *PortForwardOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+	o.GetPodTimeout, err = cmdutil.GetPodRunningTimeoutFlag(cmd)
+	if err != nil {
+		return cmdutil.UsageErrorf(cmd, err.Error())
+	}
+	if len(o.Filename) > 0 {
+		if len(args) > 0 {
+			return cmdutil.UsageErrorf(cmd, "TYPE/NAME and ports can not be given along with --filename")
+		}
+		return o.completeSpec(f)
+	}
+	if len(args) < 2 {
+		return cmdutil.UsageErrorf(cmd, "TYPE/NAME and list of ports are required for port-forward")
+	}
+
+	client, err := f.ClientForCluster(o.ClusterName)
+	if err != nil {
+		return err
+	}
+	o.ClusterName = client.Name
+	o.Namespace = client.Namespace
+	if err := client.CheckAccess(configs.Access{Command: configs.CommandPortForward, Namespace: o.Namespace}); err != nil {
+		return err
+	}
+
+	pod, ports, err := forwardablePod(client, o.Namespace, args[0], args[1:], o.GetPodTimeout)
+	if err != nil {
+		return err
+	}
+	o.PodName = pod.Name
+	o.Ports = ports
+
+	clientset, err := client.KubernetesClientSet()
 	if err != nil {
 		return err
 	}
 
 	o.PodClient = clientset.CoreV1()
 
-	o.Config, err = f.ToRESTConfig()
+	o.Config, err = client.ToRESTConfig()
 	if err != nil {
 		return err
 	}
-	o.RESTClient, err = f.RESTClient()
+	o.RESTClient, err = client.RESTClient()
 	if err != nil {
 		return err
 	}
@@ -369,6 +443,9 @@ func (o *PortForwardOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, arg
 
 // Validate validates all the required options for port-forward cmd.
 func (o PortForwardOptions) Validate() error {
+	if len(o.Filename) > 0 {
+		return o.validateSpec()
+	}
 	if len(o.PodName) == 0 {
 		return fmt.Errorf("pod name or resource type/name must be specified")
 	}
@@ -385,6 +462,21 @@ func (o PortForwardOptions) Validate() error {
 
 // RunPortForward implements all the necessary functionality for port-forward cmd.
 func (o PortForwardOptions) RunPortForward() error {
+	if len(o.Filename) > 0 {
+		return o.runSpec()
+	}
+	if o.Auditor == nil {
+		return o.runPortForward()
+	}
+	entry := audit.NewEntry("port-forward", o.ClusterName, o.Namespace)
+	entry.Pod, entry.Ports = o.PodName, o.Ports
+	err := o.runPortForward()
+	entry.Finish(err)
+	audit.Record(o.Auditor, o.errOut, entry)
+	return err
+}
+
+func (o PortForwardOptions) runPortForward() error {
 	pod, err := o.PodClient.Pods(o.Namespace).Get(context.TODO(), o.PodName, metav1.GetOptions{})
 	if err != nil {
 		return err
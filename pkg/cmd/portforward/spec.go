@@ -0,0 +1,369 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	corev1client "github.com/Angus-F/client-go/kubernetes/typed/core/v1"
+	restclient "github.com/Angus-F/client-go/rest"
+
+	"github.com/Angus-F/kubectl/pkg/audit"
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+// Spec lists the targets forwarded at once by port-forward --filename
+type Spec struct {
+	Forwards []Target `json:"forwards"`
+}
+
+// Target is a resource whose ports are forwarded
+type Target struct {
+	// Name identifies the target in the status table, it defaults to the resource
+	Name string `json:"name,omitempty"`
+	// ClusterName is the cluster of the resource, --clusterName or the default
+	// cluster if empty
+	ClusterName string `json:"clusterName,omitempty"`
+	// Namespace is the namespace of the resource, the namespace of the cluster if empty
+	Namespace string `json:"namespace,omitempty"`
+	// Resource is TYPE/NAME, or the name of a pod
+	Resource string `json:"resource"`
+	// Ports are [LOCAL_PORT:]REMOTE_PORT, like the arguments of port-forward
+	Ports []string `json:"ports"`
+	// Address are the addresses to listen on, --address if empty
+	Address []string `json:"address,omitempty"`
+}
+
+// LoadSpec loads the spec of the file at path
+func LoadSpec(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return spec, nil
+}
+
+// validate checks the targets and sets their default names. The local ports
+// must be fixed, a target reconnecting on another port would be lost to its
+// clients.
+func (s *Spec) validate() error {
+	if len(s.Forwards) == 0 {
+		return fmt.Errorf("no forwards are listed")
+	}
+	names := map[string]bool{}
+	localPorts := map[int]string{}
+	for i := range s.Forwards {
+		target := &s.Forwards[i]
+		if len(target.Resource) == 0 {
+			return fmt.Errorf("forward %d needs a resource", i+1)
+		}
+		if len(target.Name) == 0 {
+			target.Name = target.Resource
+		}
+		if names[target.Name] {
+			return fmt.Errorf("forward %q is listed twice", target.Name)
+		}
+		names[target.Name] = true
+		if len(target.Ports) == 0 {
+			return fmt.Errorf("forward %q needs at least 1 port", target.Name)
+		}
+		for _, port := range target.Ports {
+			localPort, _ := splitPort(port)
+			if len(localPort) == 0 || localPort == "0" {
+				return fmt.Errorf("forward %q can not listen on a random local port for %q", target.Name, port)
+			}
+			number, err := strconv.Atoi(localPort)
+			if err != nil {
+				// a named port, its number is known once the pod is found
+				continue
+			}
+			if other, found := localPorts[number]; found {
+				return fmt.Errorf("local port %d is forwarded by both %q and %q", number, other, target.Name)
+			}
+			localPorts[number] = target.Name
+		}
+	}
+	return nil
+}
+
+// forwardTarget is a target of the spec bound to the clients of its cluster
+type forwardTarget struct {
+	Target
+
+	factory    cmdutil.Factory
+	restClient *restclient.RESTClient
+	config     *restclient.Config
+	podClient  corev1client.PodsGetter
+}
+
+// completeSpec loads the spec and the clients of the clusters of its targets
+func (o *PortForwardOptions) completeSpec(f cmdutil.Factory) error {
+	spec, err := LoadSpec(o.Filename)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.PortForwarder.(*defaultPortForwarder); ok {
+		// the status table shows the state of the forwards instead of their messages
+		o.PortForwarder = &defaultPortForwarder{
+			IOStreams: genericclioptions.IOStreams{Out: ioutil.Discard, ErrOut: ioutil.Discard},
+		}
+	}
+	clients := map[string]*cmdutil.ClusterClient{}
+	for _, target := range spec.Forwards {
+		clusterName := target.ClusterName
+		if len(clusterName) == 0 {
+			clusterName = o.ClusterName
+		}
+		client, found := clients[clusterName]
+		if !found {
+			if client, err = f.ClientForCluster(clusterName); err != nil {
+				return fmt.Errorf("forward %q: %v", target.Name, err)
+			}
+			clients[clusterName] = client
+		}
+		target.ClusterName = client.Name
+		if len(target.Namespace) == 0 {
+			target.Namespace = client.Namespace
+		}
+		if len(target.Address) == 0 {
+			target.Address = o.Address
+		}
+		if err := client.CheckAccess(configs.Access{Command: configs.CommandPortForward, Namespace: target.Namespace}); err != nil {
+			return fmt.Errorf("forward %q: %v", target.Name, err)
+		}
+
+		t := &forwardTarget{Target: target, factory: client}
+		if t.config, err = client.ToRESTConfig(); err != nil {
+			return err
+		}
+		if t.restClient, err = client.RESTClient(); err != nil {
+			return err
+		}
+		clientset, err := client.KubernetesClientSet()
+		if err != nil {
+			return err
+		}
+		t.podClient = clientset.CoreV1()
+		o.targets = append(o.targets, t)
+	}
+	o.StopChannel = make(chan struct{}, 1)
+	return nil
+}
+
+// validateSpec validates the options of port-forward --filename
+func (o PortForwardOptions) validateSpec() error {
+	if len(o.targets) == 0 {
+		return fmt.Errorf("at least 1 forward is required for port-forward")
+	}
+	if o.PortForwarder == nil || o.out == nil {
+		return fmt.Errorf("portforwarder and output must be provided")
+	}
+	if o.RetryInterval <= 0 || o.PollInterval <= 0 {
+		return fmt.Errorf("retry and poll intervals must be positive")
+	}
+	return nil
+}
+
+// runSpec forwards every target of the spec until interrupted, reconnecting
+// the targets which lose their pod
+func (o PortForwardOptions) runSpec() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	stop := o.StopChannel
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	table := newStatusTable(o.out, o.targets)
+	var wg sync.WaitGroup
+	for i, target := range o.targets {
+		wg.Add(1)
+		go func(row int, target *forwardTarget) {
+			defer wg.Done()
+			o.runTarget(target, stop, func(update func(*forwardStatus)) {
+				table.update(row, update)
+			})
+		}(i, target)
+	}
+	wg.Wait()
+	return nil
+}
+
+// runTarget forwards a target until stop is closed. The audit entry of the
+// target lists the pods it was forwarded to, and the last error if the target
+// was not forwarding anymore when it was stopped.
+func (o PortForwardOptions) runTarget(target *forwardTarget, stop <-chan struct{}, update func(func(*forwardStatus))) {
+	entry := audit.NewEntry("port-forward", target.ClusterName, target.Namespace)
+	entry.Ports = target.Ports
+	pods := sets.NewString()
+	var lastErr error
+	track := func(fn func(*forwardStatus)) {
+		update(func(s *forwardStatus) {
+			fn(s)
+			if len(s.Pod) > 0 {
+				pods.Insert(s.Pod)
+			}
+			if s.State == stateForwarding {
+				lastErr = nil
+			}
+		})
+	}
+	defer func() {
+		update(func(s *forwardStatus) {
+			s.State = stateStopped
+			s.Message = ""
+		})
+		if o.Auditor != nil {
+			entry.Pod = strings.Join(pods.List(), ",")
+			entry.Finish(lastErr)
+			audit.Record(o.Auditor, o.errOut, entry)
+		}
+	}()
+
+	for {
+		err := o.forwardTarget(target, stop, track)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		lastErr = err
+		update(func(s *forwardStatus) {
+			s.State = stateReconnecting
+			s.Reconnects++
+			s.Message = err.Error()
+		})
+		select {
+		case <-stop:
+			return
+		case <-time.After(o.RetryInterval):
+		}
+	}
+}
+
+// forwardTarget forwards a target to its current pod, until stop is closed
+// or the pod is gone
+func (o PortForwardOptions) forwardTarget(target *forwardTarget, stop <-chan struct{}, update func(func(*forwardStatus))) error {
+	update(func(s *forwardStatus) {
+		s.State = stateConnecting
+	})
+	pod, ports, err := forwardablePod(target.factory, target.Namespace, target.Resource, target.Ports, o.GetPodTimeout)
+	if err != nil {
+		return err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return fmt.Errorf("pod %s is not running. Current status=%v", pod.Name, pod.Status.Phase)
+	}
+	update(func(s *forwardStatus) {
+		s.Pod = pod.Name
+		s.Ports = ports
+	})
+
+	req := target.restClient.Post().
+		Resource("pods").
+		Namespace(target.Namespace).
+		Name(pod.Name).
+		SubResource("portforward")
+	stopChannel, readyChannel := make(chan struct{}), make(chan struct{})
+	opts := PortForwardOptions{
+		Namespace:    target.Namespace,
+		PodName:      pod.Name,
+		Config:       target.config,
+		Address:      target.Address,
+		Ports:        ports,
+		StopChannel:  stopChannel,
+		ReadyChannel: readyChannel,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- o.PortForwarder.ForwardPorts("POST", req.URL(), opts)
+	}()
+
+	ticker := time.NewTicker(o.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-readyChannel:
+			readyChannel = nil
+			update(func(s *forwardStatus) {
+				s.State = stateForwarding
+				s.Message = ""
+			})
+		case err := <-done:
+			if err == nil {
+				err = fmt.Errorf("lost connection to pod %s", pod.Name)
+			}
+			return err
+		case <-stop:
+			close(stopChannel)
+			<-done
+			return nil
+		case <-ticker.C:
+			if err := checkPod(target.podClient, pod); err != nil {
+				close(stopChannel)
+				<-done
+				return err
+			}
+		}
+	}
+}
+
+// checkPod returns an error if pod was deleted, replaced or is not running
+// anymore. Failing to get the pod is not an error, the connection to the
+// pod may well be fine.
+func checkPod(podClient corev1client.PodsGetter, pod *corev1.Pod) error {
+	current, err := podClient.Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("pod %s was deleted", pod.Name)
+	case err != nil:
+		return nil
+	case current.UID != pod.UID:
+		return fmt.Errorf("pod %s was replaced", pod.Name)
+	case current.DeletionTimestamp != nil:
+		return fmt.Errorf("pod %s is terminating", pod.Name)
+	case current.Status.Phase != corev1.PodRunning:
+		return fmt.Errorf("pod %s is not running. Current status=%v", pod.Name, current.Status.Phase)
+	}
+	return nil
+}
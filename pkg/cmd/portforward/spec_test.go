@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package portforward
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/rest/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func writeSpec(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "forwards.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSpec(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		expected    *Spec
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			spec: "forwards:\n- clusterName: prod\n  namespace: payments\n  resource: service/postgres\n  ports: ['5432']\n- name: web\n  resource: deployment/web\n  ports: ['8080:80', 'https']\n",
+			expected: &Spec{Forwards: []Target{
+				{Name: "service/postgres", ClusterName: "prod", Namespace: "payments", Resource: "service/postgres", Ports: []string{"5432"}},
+				{Name: "web", Resource: "deployment/web", Ports: []string{"8080:80", "https"}},
+			}},
+		},
+		{
+			name:        "unknown field",
+			spec:        "forwards:\n- resource: web\n  port: ['80']\n",
+			expectedErr: `unknown field "port"`,
+		},
+		{
+			name:        "empty",
+			spec:        "forwards: []\n",
+			expectedErr: "no forwards are listed",
+		},
+		{
+			name:        "no resource",
+			spec:        "forwards:\n- ports: ['80']\n",
+			expectedErr: "forward 1 needs a resource",
+		},
+		{
+			name:        "no ports",
+			spec:        "forwards:\n- resource: web\n",
+			expectedErr: `forward "web" needs at least 1 port`,
+		},
+		{
+			name:        "random local port",
+			spec:        "forwards:\n- resource: web\n  ports: [':80']\n",
+			expectedErr: `forward "web" can not listen on a random local port for ":80"`,
+		},
+		{
+			name:        "same name",
+			spec:        "forwards:\n- resource: web\n  ports: ['80']\n- resource: web\n  ports: ['81']\n",
+			expectedErr: `forward "web" is listed twice`,
+		},
+		{
+			name:        "same local port",
+			spec:        "forwards:\n- resource: web\n  ports: ['8080:80']\n- resource: api\n  ports: ['8080']\n",
+			expectedErr: `local port 8080 is forwarded by both "web" and "api"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := LoadSpec(writeSpec(t, test.spec))
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Errorf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(spec, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, spec)
+			}
+		})
+	}
+}
+
+// blockingPortForwarder forwards until it is stopped
+type blockingPortForwarder struct {
+	lock  sync.Mutex
+	pods  []string
+	calls chan struct{}
+}
+
+func (f *blockingPortForwarder) ForwardPorts(method string, url *url.URL, opts PortForwardOptions) error {
+	f.lock.Lock()
+	f.pods = append(f.pods, opts.PodName)
+	f.lock.Unlock()
+	close(opts.ReadyChannel)
+	f.calls <- struct{}{}
+	<-opts.StopChannel
+	return nil
+}
+
+func TestRunSpecReconnects(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+
+	codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+	var lock sync.Mutex
+	uid := types.UID("first")
+	tf.Client = &fake.RESTClient{
+		VersionedAPIPath:     "/api/v1",
+		GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch p, m := req.URL.Path, req.Method; {
+			case p == "/api/v1/namespaces/test/pods/foo" && m == "GET":
+				lock.Lock()
+				pod := execPod()
+				pod.UID = uid
+				lock.Unlock()
+				return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, pod)}, nil
+			default:
+				t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+				return nil, fmt.Errorf("unexpected request")
+			}
+		}),
+	}
+	tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+
+	forwarder := &blockingPortForwarder{calls: make(chan struct{})}
+	auditor := &fakeAuditor{}
+	out := &bytes.Buffer{}
+	opts := &PortForwardOptions{
+		Auditor:       auditor,
+		Filename:      writeSpec(t, "forwards:\n- name: web\n  clusterName: dev\n  resource: pod/foo\n  ports: ['8080:80']\n"),
+		Address:       []string{"localhost"},
+		PortForwarder: forwarder,
+		RetryInterval: time.Millisecond,
+		PollInterval:  time.Millisecond,
+		out:           out,
+	}
+	cmd := NewCmdPortForward(tf, genericclioptions.NewTestIOStreamsDiscard())
+	if err := opts.Complete(tf, cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- opts.RunPortForward()
+	}()
+
+	<-forwarder.calls
+	lock.Lock()
+	uid = "second"
+	lock.Unlock()
+	select {
+	case <-forwarder.calls:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the target to reconnect to the replaced pod")
+	}
+	close(opts.StopChannel)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(forwarder.pods, []string{"foo", "foo"}) {
+		t.Errorf("expected pod foo to be forwarded twice, got %v", forwarder.pods)
+	}
+	if len(auditor.entries) != 1 {
+		t.Fatalf("expected 1 audit entry for the target, got %d", len(auditor.entries))
+	}
+	entry := auditor.entries[0]
+	if entry.Action != "port-forward" || entry.ClusterName != "dev" || entry.Namespace != "test" || entry.Pod != "foo" || len(entry.Error) > 0 {
+		t.Errorf("unexpected audit entry %#v", entry)
+	}
+	if !reflect.DeepEqual(entry.Ports, []string{"8080:80"}) {
+		t.Errorf("expected the ports of the target, got %v", entry.Ports)
+	}
+	tables := strings.Split(out.String(), "\n\n")
+	for _, expected := range []string{
+		`web +dev +test +foo +8080:80 +Forwarding +0 *$`,
+		`web +dev +test +foo +8080:80 +Reconnecting +1 +pod foo was replaced$`,
+		`web +dev +test +foo +8080:80 +Stopped +1 *$`,
+	} {
+		if !matchAny(tables, expected) {
+			t.Errorf("expected a status table matching %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func matchAny(tables []string, expected string) bool {
+	for _, table := range tables {
+		lines := strings.Split(strings.TrimSpace(table), "\n")
+		if len(lines) == 2 && regexp.MustCompile(expected).MatchString(lines[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompleteSpecPolicy(t *testing.T) {
+	policy, err := configs.ParsePolicy([]byte("clusters:\n- name: prod\n  commands: [exec]\n"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+	tf.PolicyVal = policy
+	tf.Client = &fake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+			return nil, fmt.Errorf("unexpected request")
+		}),
+	}
+	tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+
+	opts := &PortForwardOptions{
+		Filename:      writeSpec(t, "forwards:\n- clusterName: dev\n  resource: web\n  ports: ['80']\n- clusterName: prod\n  resource: db\n  ports: ['5432']\n"),
+		PortForwarder: &blockingPortForwarder{},
+	}
+	err = opts.Complete(tf, NewCmdPortForward(tf, genericclioptions.NewTestIOStreamsDiscard()), nil)
+	expected := `forward "db": access denied by the access policy test for cluster "prod": port-forward is not allowed`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
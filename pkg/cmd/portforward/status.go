@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package portforward
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Angus-F/cli-runtime/pkg/printers"
+
+	"github.com/Angus-F/kubectl/pkg/util/term"
+)
+
+// States of the targets of a spec
+const (
+	stateConnecting   = "Connecting"
+	stateForwarding   = "Forwarding"
+	stateReconnecting = "Reconnecting"
+	stateStopped      = "Stopped"
+)
+
+// forwardStatus is a row of the status table
+type forwardStatus struct {
+	Name        string
+	ClusterName string
+	Namespace   string
+	Pod         string
+	Ports       []string
+	State       string
+	Reconnects  int
+	Message     string
+}
+
+// statusTable shows the status of the targets of a spec. On a terminal the
+// table is redrawn in place whenever a status changes, otherwise it is
+// printed again.
+type statusTable struct {
+	lock sync.Mutex
+	out  io.Writer
+	tty  bool
+	rows []*forwardStatus
+	// lines is the number of lines printed last, which a redraw replaces
+	lines int
+}
+
+func newStatusTable(out io.Writer, targets []*forwardTarget) *statusTable {
+	table := &statusTable{out: out, tty: term.IsTerminal(out)}
+	for _, target := range targets {
+		table.rows = append(table.rows, &forwardStatus{
+			Name:        target.Name,
+			ClusterName: target.ClusterName,
+			Namespace:   target.Namespace,
+			Ports:       target.Ports,
+			State:       stateConnecting,
+		})
+	}
+	return table
+}
+
+// update changes the status of a row and prints the table if it changed
+func (t *statusTable) update(row int, update func(*forwardStatus)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status := *t.rows[row]
+	update(t.rows[row])
+	if fmt.Sprint(status) == fmt.Sprint(*t.rows[row]) {
+		return
+	}
+	t.print()
+}
+
+func (t *statusTable) print() {
+	buf := &bytes.Buffer{}
+	w := printers.GetNewTabWriter(buf)
+	fmt.Fprintln(w, "NAME\tCLUSTER\tNAMESPACE\tPOD\tPORTS\tSTATUS\tRECONNECTS\tMESSAGE")
+	for _, row := range t.rows {
+		pod := row.Pod
+		if len(pod) == 0 {
+			pod = "<none>"
+		}
+		// a message on several lines would break the redraw
+		message := strings.ReplaceAll(row.Message, "\n", " ")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", row.Name, row.ClusterName, row.Namespace, pod, strings.Join(row.Ports, ","), row.State, row.Reconnects, message)
+	}
+	w.Flush()
+
+	if t.tty && t.lines > 0 {
+		// move up to the previous table and clear it
+		fmt.Fprintf(t.out, "\x1b[%dA\x1b[J", t.lines)
+	} else if t.lines > 0 {
+		fmt.Fprintln(t.out)
+	}
+	t.lines = strings.Count(buf.String(), "\n")
+	t.out.Write(buf.Bytes())
+}
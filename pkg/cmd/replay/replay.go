@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var (
+	replayLong = templates.LongDesc(i18n.T(`
+		Play back a session recorded with 'kubectl exec --record'.
+
+		The output of the session is written to the terminal with the timing
+		it was recorded with. Recordings are in the asciicast v2 format, so
+		they can also be played with asciinema.`))
+
+	replayExample = templates.Examples(i18n.T(`
+		# Play back the session recorded to session.cast
+		kubectl replay session.cast
+
+		# Play back a session twice as fast, skipping pauses longer than 2 seconds
+		kubectl replay session.cast --speed=2 --idle-time-limit=2s`))
+)
+
+// ReplayOptions are the options of the replay command
+type ReplayOptions struct {
+	Filename      string
+	Speed         float64
+	IdleTimeLimit time.Duration
+
+	// sleep waits between events, time.Sleep by default
+	sleep func(time.Duration)
+
+	genericclioptions.IOStreams
+}
+
+// header is the first line of an asciicast v2 recording
+type header struct {
+	Version int `json:"version"`
+}
+
+// NewReplayOptions returns the default options of the replay command
+func NewReplayOptions(ioStreams genericclioptions.IOStreams) *ReplayOptions {
+	return &ReplayOptions{
+		Speed:     1,
+		sleep:     time.Sleep,
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdReplay returns the replay command
+func NewCmdReplay(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewReplayOptions(ioStreams)
+	cmd := &cobra.Command{
+		Use:                   "replay FILE",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Play back a session recorded with exec --record"),
+		Long:                  replayLong,
+		Example:               replayExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().Float64Var(&o.Speed, "speed", o.Speed, "Playback speed, 2 plays the session twice as fast.")
+	cmd.Flags().DurationVar(&o.IdleTimeLimit, "idle-time-limit", o.IdleTimeLimit, "If greater than 0, the longest pause between two events, longer pauses are shortened.")
+	return cmd
+}
+
+// Complete sets the recording to play back from the arguments
+func (o *ReplayOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmdutil.UsageErrorf(cmd, "exactly one recording is required, got %d", len(args))
+	}
+	o.Filename = args[0]
+	return nil
+}
+
+// Validate checks the playback options
+func (o *ReplayOptions) Validate() error {
+	if o.Speed <= 0 {
+		return fmt.Errorf("--speed must be greater than 0")
+	}
+	if o.IdleTimeLimit < 0 {
+		return fmt.Errorf("--idle-time-limit must not be negative")
+	}
+	return nil
+}
+
+// Run plays back the recording
+func (o *ReplayOptions) Run() error {
+	file, err := os.Open(o.Filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return o.replay(file)
+}
+
+// replay writes the output events of the recording read from r to Out,
+// waiting between them as long as they were apart when recorded
+func (o *ReplayOptions) replay(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	line, err := readLine(reader)
+	if err != nil {
+		return fmt.Errorf("unable to read the recording header: %v", err)
+	}
+	h := header{}
+	if err := json.Unmarshal(line, &h); err != nil {
+		return fmt.Errorf("invalid recording header: %v", err)
+	}
+	if h.Version != 2 {
+		return fmt.Errorf("unsupported recording version %d, only asciicast v2 recordings can be played back", h.Version)
+	}
+
+	last := 0.0
+	for number := 2; ; number++ {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		var elapsed float64
+		var code, data string
+		event := []interface{}{&elapsed, &code, &data}
+		if err := json.Unmarshal(line, &event); err != nil || len(event) != 3 {
+			return fmt.Errorf("invalid event on line %d of the recording", number)
+		}
+		if code != "o" {
+			// input and resizes are recorded for the record, the output shows their effect
+			continue
+		}
+
+		wait := time.Duration((elapsed - last) / o.Speed * float64(time.Second))
+		last = elapsed
+		if o.IdleTimeLimit > 0 && wait > o.IdleTimeLimit {
+			wait = o.IdleTimeLimit
+		}
+		if wait > 0 {
+			o.sleep(wait)
+		}
+		if _, err := io.WriteString(o.Out, data); err != nil {
+			return err
+		}
+	}
+}
+
+// readLine reads a line, with or without its newline
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return bytes.TrimSpace(line), err
+}
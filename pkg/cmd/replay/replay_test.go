@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+)
+
+const recording = `{"version":2,"width":80,"height":24,"timestamp":1600000000,"command":"bash"}
+[0.5,"o","$ "]
+[1,"i","ls\n"]
+[1.25,"o","ls\r\n"]
+[2,"r","120x40"]
+[11.25,"o","file\r\n"]
+`
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name           string
+		speed          float64
+		idleTimeLimit  time.Duration
+		recording      string
+		expectedSleeps []time.Duration
+		expectedErr    string
+	}{
+		{
+			name:           "recorded timing",
+			speed:          1,
+			recording:      recording,
+			expectedSleeps: []time.Duration{500 * time.Millisecond, 750 * time.Millisecond, 10 * time.Second},
+		},
+		{
+			name:           "faster with an idle time limit",
+			speed:          2,
+			idleTimeLimit:  2 * time.Second,
+			recording:      recording,
+			expectedSleeps: []time.Duration{250 * time.Millisecond, 375 * time.Millisecond, 2 * time.Second},
+		},
+		{
+			name:        "unsupported version",
+			speed:       1,
+			recording:   `{"version":1,"width":80,"height":24,"stdout":[]}`,
+			expectedErr: "unsupported recording version 1",
+		},
+		{
+			name:        "invalid event",
+			speed:       1,
+			recording:   "{\"version\":2}\n[0.5,\"o\"]\n",
+			expectedErr: "invalid event on line 2",
+		},
+		{
+			name:        "empty",
+			speed:       1,
+			expectedErr: "unable to read the recording header",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			o := NewReplayOptions(streams)
+			o.Speed = test.speed
+			o.IdleTimeLimit = test.idleTimeLimit
+			sleeps := []time.Duration{}
+			o.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			err := o.replay(strings.NewReader(test.recording))
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != "$ ls\r\nfile\r\n" {
+				t.Errorf("unexpected output %q", out.String())
+			}
+			if len(sleeps) != len(test.expectedSleeps) {
+				t.Fatalf("expected sleeps %v, got %v", test.expectedSleeps, sleeps)
+			}
+			for i := range sleeps {
+				if diff := sleeps[i] - test.expectedSleeps[i]; diff > time.Microsecond || diff < -time.Microsecond {
+					t.Errorf("expected sleeps %v, got %v", test.expectedSleeps, sleeps)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayValidate(t *testing.T) {
+	o := NewReplayOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.Speed = 0
+	if err := o.Validate(); err == nil {
+		t.Errorf("expected a speed of 0 to be rejected")
+	}
+}
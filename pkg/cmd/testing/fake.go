@@ -46,6 +46,7 @@ import (
 	"github.com/Angus-F/client-go/tools/clientcmd"
 	clientcmdapi "github.com/Angus-F/client-go/tools/clientcmd/api"
 	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
 	"github.com/Angus-F/kubectl/pkg/scheme"
 	"github.com/Angus-F/kubectl/pkg/util/openapi"
 	openapitesting "github.com/Angus-F/kubectl/pkg/util/openapi/testing"
@@ -395,6 +396,11 @@ type TestFactory struct {
 	UnstructuredClient RESTClient
 	ClientConfigVal    *restclient.Config
 	FakeDynamicClient  *fakedynamic.FakeDynamicClient
+	ClusterNamesVal    []string
+	// PolicyVal is the access policy of the clusters returned by ClientForCluster
+	PolicyVal *configs.Policy
+	// DefaultClusterNameVal is the cluster ClientForCluster binds an empty name to
+	DefaultClusterNameVal string
 
 	tempConfigFile *os.File
 
@@ -821,3 +827,32 @@ func (f *TestFactory)GetConfigFlags() *genericclioptions.ConfigFlags {
 func (f *TestFactory)NewClientConfigFromBytesWithConfigFlags(configBytes []byte) (clientcmd.ClientConfig, error) {
 	return nil, nil
 }
+
+// ClusterNames returns ClusterNamesVal as the registered clusters
+func (f *TestFactory) ClusterNames() ([]string, error) {
+	return f.ClusterNamesVal, nil
+}
+
+// DefaultClusterName returns DefaultClusterNameVal
+func (f *TestFactory) DefaultClusterName() (string, error) {
+	return f.DefaultClusterNameVal, nil
+}
+
+// ClientForCluster binds every cluster name to the TestFactory itself, so commands
+// resolving --clusterName talk to the fake clients.
+func (f *TestFactory) ClientForCluster(clusterName string) (*cmdutil.ClusterClient, error) {
+	if len(clusterName) == 0 {
+		clusterName = f.DefaultClusterNameVal
+	}
+	namespace, enforceNamespace, err := f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
+	return &cmdutil.ClusterClient{
+		Factory:          f,
+		Name:             clusterName,
+		Namespace:        namespace,
+		EnforceNamespace: enforceNamespace,
+		Policy:           f.PolicyVal,
+	}, nil
+}
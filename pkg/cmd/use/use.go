@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package use
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/util/completion"
+	"github.com/Angus-F/kubectl/pkg/util/i18n"
+	"github.com/Angus-F/kubectl/pkg/util/templates"
+)
+
+var (
+	useLong = templates.LongDesc(i18n.T(`
+		Set the default cluster, and optionally its default namespace.
+
+		exec, cp and logs run in the default cluster when --clusterName is not set,
+		and in the default namespace of the cluster when --namespace is not set.
+		The defaults are kept in ~/.kesctl/state.yaml, or in the file named by
+		$KESCTL_STATE_FILE. $KESCTL_CLUSTER and $KESCTL_NAMESPACE override them
+		in a single shell.`))
+
+	useExample = templates.Examples(i18n.T(`
+		# Run the commands in cluster prod by default
+		kubectl use prod
+
+		# Run the commands in namespace payments of cluster prod by default
+		kubectl use prod -n payments
+
+		# Use another cluster in the current shell only
+		export KESCTL_CLUSTER=dev`))
+)
+
+// UseOptions are the options of the use command
+type UseOptions struct {
+	ClusterName string
+	Namespace   string
+	// StateFile is the file the defaults are written to
+	StateFile string
+
+	factory cmdutil.Factory
+
+	genericclioptions.IOStreams
+}
+
+// NewUseOptions returns the options of the use command
+func NewUseOptions(streams genericclioptions.IOStreams) *UseOptions {
+	return &UseOptions{
+		StateFile: configs.StateFile(),
+		IOStreams: streams,
+	}
+}
+
+// NewCmdUse returns the use command
+func NewCmdUse(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewUseOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "use CLUSTER [-n NAMESPACE]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Set the default cluster and namespace"),
+		Long:                  useLong,
+		Example:               useExample,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return completion.ClusterNameCompletionFunc(f)(cmd, args, toComplete)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	return cmd
+}
+
+// Complete takes the cluster from the arguments and the namespace from the
+// global --namespace flag
+func (o *UseOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmdutil.UsageErrorf(cmd, "exactly one cluster name is required, got %d", len(args))
+	}
+	o.ClusterName = args[0]
+	if flag := cmd.Flag("namespace"); flag != nil {
+		o.Namespace = flag.Value.String()
+	}
+	o.factory = f
+	return nil
+}
+
+// Validate checks that the cluster is registered
+func (o *UseOptions) Validate() error {
+	if cmdutil.IsClusterList(o.ClusterName) {
+		return fmt.Errorf("the default cluster must be a single cluster, not %q", o.ClusterName)
+	}
+	_, err := o.factory.ClientForCluster(o.ClusterName)
+	return err
+}
+
+// Run writes the defaults to the state file
+func (o *UseOptions) Run() error {
+	state, err := configs.LoadStateFromFile(o.StateFile)
+	if err != nil {
+		return err
+	}
+	state.Use(o.ClusterName, o.Namespace)
+	if err := state.Save(o.StateFile); err != nil {
+		return err
+	}
+
+	if namespace := state.Namespaces[o.ClusterName]; len(namespace) > 0 {
+		fmt.Fprintf(o.Out, "Switched to cluster %q and namespace %q.\n", o.ClusterName, namespace)
+	} else {
+		fmt.Fprintf(o.Out, "Switched to cluster %q.\n", o.ClusterName)
+	}
+	if name := os.Getenv(configs.ClusterEnv); len(name) > 0 && name != o.ClusterName {
+		fmt.Fprintf(o.ErrOut, "warning: $%s overrides the default cluster with %q in this shell\n", configs.ClusterEnv, name)
+	}
+	if namespace := os.Getenv(configs.NamespaceEnv); len(namespace) > 0 && len(o.Namespace) > 0 && namespace != o.Namespace {
+		fmt.Fprintf(o.ErrOut, "warning: $%s overrides the default namespace with %q in this shell\n", configs.NamespaceEnv, namespace)
+	}
+	return nil
+}
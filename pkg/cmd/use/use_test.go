@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package use
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+func TestUse(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.yaml")
+	tests := []struct {
+		name          string
+		args          []string
+		namespace     string
+		expectedOut   string
+		expectedErr   string
+		expectedState *configs.State
+	}{
+		{
+			name:          "cluster and namespace",
+			args:          []string{"prod"},
+			namespace:     "payments",
+			expectedOut:   "Switched to cluster \"prod\" and namespace \"payments\".\n",
+			expectedState: &configs.State{ClusterName: "prod", Namespaces: map[string]string{"prod": "payments"}},
+		},
+		{
+			name:          "another cluster",
+			args:          []string{"dev"},
+			expectedOut:   "Switched to cluster \"dev\".\n",
+			expectedState: &configs.State{ClusterName: "dev", Namespaces: map[string]string{"prod": "payments"}},
+		},
+		{
+			name:          "back to a cluster keeps its namespace",
+			args:          []string{"prod"},
+			expectedOut:   "Switched to cluster \"prod\" and namespace \"payments\".\n",
+			expectedState: &configs.State{ClusterName: "prod", Namespaces: map[string]string{"prod": "payments"}},
+		},
+		{
+			name:          "list of clusters",
+			args:          []string{"prod-*"},
+			expectedErr:   `the default cluster must be a single cluster, not "prod-*"`,
+			expectedState: &configs.State{ClusterName: "prod", Namespaces: map[string]string{"prod": "payments"}},
+		},
+	}
+	// the cases run in order against the same state file
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			o := NewUseOptions(streams)
+			o.StateFile = stateFile
+			cmd := NewCmdUse(tf, streams)
+			cmd.Flags().StringP("namespace", "n", test.namespace, "")
+			err := o.Complete(tf, cmd, test.args)
+			if err == nil {
+				err = o.Validate()
+			}
+			if err == nil {
+				err = o.Run()
+			}
+			if len(test.expectedErr) > 0 {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("expected error %q, got %v", test.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != test.expectedOut {
+				t.Errorf("expected output %q, got %q", test.expectedOut, out.String())
+			}
+
+			state, err := configs.LoadStateFromFile(stateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(state, test.expectedState) {
+				t.Errorf("expected state %#v, got %#v", test.expectedState, state)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"github.com/Angus-F/client-go/discovery"
+	"github.com/Angus-F/client-go/discovery/cached/memory"
+	"github.com/Angus-F/client-go/rest"
+	"github.com/Angus-F/client-go/restmapper"
+	"github.com/Angus-F/client-go/tools/clientcmd"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+// ClusterClient is a Factory bound to a single cluster of the cluster registry.
+// Every client obtained from it talks to that cluster only, so several
+// ClusterClients can be used side by side.
+type ClusterClient struct {
+	Factory
+
+	// Name is the --clusterName the client was resolved from.
+	Name string
+	// Namespace is taken from --namespace, or else from the cluster's kubeconfig context.
+	// It is "default" when neither sets one.
+	Namespace string
+	// EnforceNamespace is true when the namespace was set explicitly rather than defaulted.
+	EnforceNamespace bool
+	// Policy is the access policy of the clusters, nil if there is none.
+	Policy *configs.Policy
+}
+
+// CheckAccess returns an error if the access policy denies access in the
+// cluster. Commands call it before sending any request to the cluster.
+func (c *ClusterClient) CheckAccess(access configs.Access) error {
+	return c.Policy.Check(c.Name, access)
+}
+
+// loadRegistry loads the cluster registry once per factory.
+func (f *factoryImpl) loadRegistry() (*configs.Registry, error) {
+	f.registryOnce.Do(func() {
+		f.registry, f.registryErr = configs.LoadRegistry()
+	})
+	return f.registry, f.registryErr
+}
+
+// loadState loads the defaults set by kesctl use once per factory.
+func (f *factoryImpl) loadState() (*configs.State, error) {
+	f.stateOnce.Do(func() {
+		f.state, f.stateErr = configs.LoadState()
+	})
+	return f.state, f.stateErr
+}
+
+func (f *factoryImpl) DefaultClusterName() (string, error) {
+	state, err := f.loadState()
+	if err != nil {
+		return "", err
+	}
+	return state.DefaultClusterName(), nil
+}
+
+func (f *factoryImpl) ClusterNames() ([]string, error) {
+	registry, err := f.loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	return registry.Names(), nil
+}
+
+func (f *factoryImpl) ClientForCluster(clusterName string) (*ClusterClient, error) {
+	registry, err := f.loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	defaulted := len(clusterName) == 0
+	if defaulted {
+		if clusterName, err = f.DefaultClusterName(); err != nil {
+			return nil, err
+		}
+	}
+	cluster, err := registry.Lookup(clusterName)
+	if err != nil {
+		if defaulted && len(clusterName) > 0 && registry.SealedError() != nil {
+			return nil, fmt.Errorf("the default cluster %q can not be found, the sealed configs could not be loaded: %v", clusterName, registry.SealedError())
+		}
+		if defaulted && len(clusterName) > 0 {
+			return nil, fmt.Errorf("the default cluster %q can not be found, set another one with 'kesctl use' or $%s", clusterName, configs.ClusterEnv)
+		}
+		return nil, err
+	}
+
+	clientConfig, err := f.clientGetter.NewClientConfigFromBytesWithConfigFlags(cluster.Content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the kubeconfig of cluster %q from %s: %v", cluster.Name, cluster.Source, err)
+	}
+	namespace, enforceNamespace, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine the namespace for cluster %q: %v", cluster.Name, err)
+	}
+	if !enforceNamespace {
+		// --namespace wins over the default, which wins over the kubeconfig
+		state, err := f.loadState()
+		if err != nil {
+			return nil, err
+		}
+		if defaultNamespace := state.DefaultNamespace(cluster.Name); len(defaultNamespace) > 0 {
+			namespace = defaultNamespace
+		}
+	}
+
+	getter := NewMatchVersionFlags(&clusterClientGetter{
+		clientConfig: clientConfig,
+		delegate:     f.clientGetter,
+	})
+	if parent, ok := f.clientGetter.(*MatchVersionFlags); ok {
+		getter.RequireMatchedServerVersion = parent.RequireMatchedServerVersion
+	}
+
+	return &ClusterClient{
+		Factory:          NewFactory(getter),
+		Name:             cluster.Name,
+		Namespace:        namespace,
+		EnforceNamespace: enforceNamespace,
+		Policy:           registry.Policy(),
+	}, nil
+}
+
+// ResolveClusterNames expands a --clusterName value into the names of registered clusters.
+// The value is a comma separated list whose items are cluster names or glob patterns
+// such as "prod-*". With all set, every registered cluster is returned instead.
+// Plain names are returned as given and are validated by ClientForCluster.
+func ResolveClusterNames(f Factory, value string, all bool) ([]string, error) {
+	if all {
+		if len(value) > 0 {
+			return nil, fmt.Errorf("--all-clusters can not be used together with --clusterName")
+		}
+		return f.ClusterNames()
+	}
+	if len(value) == 0 {
+		return nil, fmt.Errorf("Please set the clusterName")
+	}
+
+	var registered []string
+	names := []string{}
+	seen := sets.NewString()
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		if !strings.ContainsAny(item, "*?[") {
+			if !seen.Has(item) {
+				seen.Insert(item)
+				names = append(names, item)
+			}
+			continue
+		}
+
+		if registered == nil {
+			var err error
+			if registered, err = f.ClusterNames(); err != nil {
+				return nil, err
+			}
+		}
+		matched := false
+		for _, name := range registered {
+			ok, err := path.Match(item, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid clusterName pattern %q: %v", item, err)
+			}
+			if !ok {
+				continue
+			}
+			matched = true
+			if !seen.Has(name) {
+				seen.Insert(name)
+				names = append(names, name)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no cluster matches the clusterName pattern %q", item)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Please set the clusterName")
+	}
+	return names, nil
+}
+
+// IsClusterList returns true if a --clusterName value may select more than one cluster.
+func IsClusterList(value string) bool {
+	return strings.ContainsAny(value, ",*?[")
+}
+
+// clusterClientGetter is a RESTClientGetter over a single in-memory kubeconfig.
+// Unlike ConfigFlags it holds no state shared with other clusters.
+type clusterClientGetter struct {
+	delegate genericclioptions.RESTClientGetter
+
+	lock            sync.Mutex
+	clientConfig    clientcmd.ClientConfig
+	discoveryClient discovery.CachedDiscoveryInterface
+}
+
+var _ genericclioptions.RESTClientGetter = &clusterClientGetter{}
+
+func (g *clusterClientGetter) ToRESTConfig() (*rest.Config, error) {
+	config, err := g.ToRawKubeConfigLoader().ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	// honor the wrappers installed on the global flags, e.g. the command header round tripper
+	if flags := g.delegate.GetConfigFlags(); flags != nil && flags.WrapConfigFn != nil {
+		return flags.WrapConfigFn(config), nil
+	}
+	return config, nil
+}
+
+func (g *clusterClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	config, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.discoveryClient != nil {
+		return g.discoveryClient, nil
+	}
+	// match the discovery burst of ConfigFlags
+	config.Burst = 100
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	g.discoveryClient = memory.NewMemCacheClient(discoveryClient)
+	return g.discoveryClient, nil
+}
+
+func (g *clusterClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	discoveryClient, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	return restmapper.NewShortcutExpander(mapper, discoveryClient), nil
+}
+
+func (g *clusterClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.clientConfig
+}
+
+func (g *clusterClientGetter) SetClientConfig(clientConfig *clientcmd.ClientConfig) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.clientConfig = *clientConfig
+	g.discoveryClient = nil
+}
+
+func (g *clusterClientGetter) GetConfigFlags() *genericclioptions.ConfigFlags {
+	return g.delegate.GetConfigFlags()
+}
+
+func (g *clusterClientGetter) NewClientConfigFromBytesWithConfigFlags(configBytes []byte) (clientcmd.ClientConfig, error) {
+	return g.delegate.NewClientConfigFromBytesWithConfigFlags(configBytes)
+}
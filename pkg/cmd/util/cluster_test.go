@@ -0,0 +1,354 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Angus-F/cli-runtime/pkg/genericclioptions"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: admin
+    namespace: %[2]s
+current-context: %[1]s
+users:
+- name: admin
+  user:
+    token: secret
+`
+
+func newClusterTestFactory(t *testing.T, kubeconfigs map[string]string) Factory {
+	dir, err := ioutil.TempDir("", "kesctl-clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range kubeconfigs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setenv(t, configs.ClustersDirEnv, dir)
+	// the defaults of the user must not leak into the tests
+	setenv(t, configs.StateFileEnv, filepath.Join(dir, "state", "state.yaml"))
+	setenv(t, configs.ClusterEnv, "")
+	setenv(t, configs.NamespaceEnv, "")
+
+	return NewFactory(NewMatchVersionFlags(genericclioptions.NewConfigFlags(true)))
+}
+
+func setenv(t *testing.T, name, value string) {
+	old, set := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestClientForCluster(t *testing.T) {
+	f := newClusterTestFactory(t, map[string]string{
+		"east":   fmt.Sprintf(testKubeconfig, "east", "payments"),
+		"west":   fmt.Sprintf(testKubeconfig, "west", ""),
+		"broken": "not: [a kubeconfig",
+	})
+
+	tests := []struct {
+		clusterName       string
+		expectedHost      string
+		expectedNamespace string
+		expectedErr       string
+	}{
+		{
+			clusterName:       "east",
+			expectedHost:      "https://east.example.com",
+			expectedNamespace: "payments",
+		},
+		{
+			clusterName:       "west",
+			expectedHost:      "https://west.example.com",
+			expectedNamespace: "default",
+		},
+		{
+			clusterName: "",
+			expectedErr: "Please set the clusterName",
+		},
+		{
+			clusterName: "north",
+			expectedErr: "the clusterName can not be found",
+		},
+		{
+			clusterName: "broken",
+			expectedErr: `unable to load the kubeconfig of cluster "broken"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.clusterName, func(t *testing.T) {
+			client, err := f.ClientForCluster(test.clusterName)
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if client.Name != test.clusterName {
+				t.Errorf("expected name %q, got %q", test.clusterName, client.Name)
+			}
+			if client.Namespace != test.expectedNamespace {
+				t.Errorf("expected namespace %q, got %q", test.expectedNamespace, client.Namespace)
+			}
+			config, err := client.ToRESTConfig()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if config.Host != test.expectedHost {
+				t.Errorf("expected host %q, got %q", test.expectedHost, config.Host)
+			}
+			if config.GroupVersion == nil || config.NegotiatedSerializer == nil {
+				t.Errorf("expected kubernetes defaults to be set on the rest config")
+			}
+		})
+	}
+}
+
+func TestClientForClusterIsIndependent(t *testing.T) {
+	f := newClusterTestFactory(t, map[string]string{
+		"east": fmt.Sprintf(testKubeconfig, "east", "a"),
+		"west": fmt.Sprintf(testKubeconfig, "west", "b"),
+	})
+
+	east, err := f.ClientForCluster("east")
+	if err != nil {
+		t.Fatal(err)
+	}
+	west, err := f.ClientForCluster("west")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eastConfig, err := east.ToRESTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	westConfig, err := west.ToRESTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eastConfig.Host == westConfig.Host {
+		t.Errorf("expected clients of different clusters to use different servers, both use %q", eastConfig.Host)
+	}
+
+	names, err := f.ClusterNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := sets.NewString(names...)
+	if !found.HasAll("east", "west") {
+		t.Errorf("expected registered clusters in %v", names)
+	}
+}
+
+func TestClientForClusterDefaults(t *testing.T) {
+	tests := []struct {
+		name              string
+		state             *configs.State
+		clusterEnv        string
+		namespaceEnv      string
+		clusterName       string
+		expectedName      string
+		expectedNamespace string
+		expectedErr       string
+	}{
+		{
+			name:              "default cluster",
+			state:             &configs.State{ClusterName: "west"},
+			expectedName:      "west",
+			expectedNamespace: "default",
+		},
+		{
+			name:              "default namespace over the kubeconfig",
+			state:             &configs.State{ClusterName: "east", Namespaces: map[string]string{"east": "billing"}},
+			expectedName:      "east",
+			expectedNamespace: "billing",
+		},
+		{
+			name:              "clusterName over the default",
+			state:             &configs.State{ClusterName: "west"},
+			clusterName:       "east",
+			expectedName:      "east",
+			expectedNamespace: "payments",
+		},
+		{
+			name:              "env over the state",
+			state:             &configs.State{ClusterName: "west", Namespaces: map[string]string{"east": "billing"}},
+			clusterEnv:        "east",
+			namespaceEnv:      "web",
+			expectedName:      "east",
+			expectedNamespace: "web",
+		},
+		{
+			name:        "no default",
+			state:       &configs.State{},
+			expectedErr: "Please set the clusterName",
+		},
+		{
+			name:        "unknown default",
+			state:       &configs.State{ClusterName: "north"},
+			expectedErr: `the default cluster "north" can not be found`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newClusterTestFactory(t, map[string]string{
+				"east": fmt.Sprintf(testKubeconfig, "east", "payments"),
+				"west": fmt.Sprintf(testKubeconfig, "west", ""),
+			})
+			if err := test.state.Save(os.Getenv(configs.StateFileEnv)); err != nil {
+				t.Fatal(err)
+			}
+			setenv(t, configs.ClusterEnv, test.clusterEnv)
+			setenv(t, configs.NamespaceEnv, test.namespaceEnv)
+
+			client, err := f.ClientForCluster(test.clusterName)
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if client.Name != test.expectedName || client.Namespace != test.expectedNamespace {
+				t.Errorf("expected %s/%s, got %s/%s", test.expectedName, test.expectedNamespace, client.Name, client.Namespace)
+			}
+		})
+	}
+}
+
+func TestResolveClusterNames(t *testing.T) {
+	f := newClusterTestFactory(t, map[string]string{
+		"dev":       fmt.Sprintf(testKubeconfig, "dev", ""),
+		"prod-east": fmt.Sprintf(testKubeconfig, "prod-east", ""),
+		"prod-west": fmt.Sprintf(testKubeconfig, "prod-west", ""),
+	})
+
+	tests := []struct {
+		name        string
+		value       string
+		all         bool
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name:     "single name",
+			value:    "dev",
+			expected: []string{"dev"},
+		},
+		{
+			name:     "unknown names are passed through",
+			value:    "north",
+			expected: []string{"north"},
+		},
+		{
+			name:     "list with duplicates",
+			value:    "prod-west, dev,prod-west",
+			expected: []string{"prod-west", "dev"},
+		},
+		{
+			name:     "glob",
+			value:    "prod-*",
+			expected: []string{"prod-east", "prod-west"},
+		},
+		{
+			name:     "glob and name",
+			value:    "dev,prod-?ast",
+			expected: []string{"dev", "prod-east"},
+		},
+		{
+			name:        "glob without match",
+			value:       "qa-*",
+			expectedErr: `no cluster matches the clusterName pattern "qa-*"`,
+		},
+		{
+			name:        "invalid glob",
+			value:       "prod-[",
+			expectedErr: `invalid clusterName pattern "prod-["`,
+		},
+		{
+			name:        "empty",
+			value:       " , ",
+			expectedErr: "Please set the clusterName",
+		},
+		{
+			name:        "all with name",
+			value:       "dev",
+			all:         true,
+			expectedErr: "--all-clusters can not be used together with --clusterName",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names, err := ResolveClusterNames(f, test.value, test.all)
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, names)
+			}
+		})
+	}
+
+	all, err := ResolveClusterNames(f, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sets.NewString(all...).HasAll("dev", "prod-east", "prod-west") {
+		t.Errorf("expected every registered cluster, got %v", all)
+	}
+}
@@ -66,4 +66,14 @@ type Factory interface {
 	OpenAPISchema() (openapi.Resources, error)
 	// OpenAPIGetter returns a getter for the openapi schema document
 	OpenAPIGetter() discovery.OpenAPISchemaInterface
+
+	// ClusterNames returns the names of all clusters in the cluster registry.
+	ClusterNames() ([]string, error)
+	// ClientForCluster resolves a --clusterName through the cluster registry and returns
+	// a client bound to that cluster. Commands that act on a cluster should get all of
+	// their clients from it.
+	ClientForCluster(clusterName string) (*ClusterClient, error)
+	// DefaultClusterName returns the cluster used when --clusterName is not set,
+	// empty if there is none. ClientForCluster resolves an empty name to it.
+	DefaultClusterName() (string, error)
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/Angus-F/client-go/kubernetes"
 	restclient "github.com/Angus-F/client-go/rest"
 	"github.com/Angus-F/client-go/tools/clientcmd"
+	"github.com/Angus-F/kubectl/pkg/configs"
 	"github.com/Angus-F/kubectl/pkg/util/openapi"
 	openapivalidation "github.com/Angus-F/kubectl/pkg/util/openapi/validation"
 	"github.com/Angus-F/kubectl/pkg/validation"
@@ -44,6 +45,15 @@ type factoryImpl struct {
 	openAPIGetter *openapi.CachedOpenAPIGetter
 	parser        sync.Once
 	getter        sync.Once
+
+	// Caches the cluster registry
+	registry     *configs.Registry
+	registryErr  error
+	registryOnce sync.Once
+	// Caches the defaults set by kesctl use
+	state     *configs.State
+	stateErr  error
+	stateOnce sync.Once
 }
 
 func NewFactory(clientGetter genericclioptions.RESTClientGetter) Factory {
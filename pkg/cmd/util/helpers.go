@@ -439,7 +439,13 @@ func AddContainerVarFlags(cmd *cobra.Command, p *string, containerName string) {
 	cmd.Flags().StringVarP(p, "container", "c", containerName, "Container name. If omitted, use the kubectl.kubernetes.io/default-container annotation for selecting the container to be attached or the first container in the pod will be chosen")
 }
 func AddClusterVarFlags(cmd *cobra.Command, p *string, clusterName string) {
-	cmd.Flags().StringVarP(p, "clusterName", "C", clusterName, "choose the specific cluster to exec the command")
+	cmd.Flags().StringVarP(p, "clusterName", "C", clusterName, "choose the specific cluster to exec the command, the default cluster set by 'kesctl use' if omitted")
+}
+
+// AddClusterListVarFlags adds --clusterName and --all-clusters for commands that can run against several clusters.
+func AddClusterListVarFlags(cmd *cobra.Command, p *string, clusterName string, all *bool) {
+	cmd.Flags().StringVarP(p, "clusterName", "C", clusterName, "choose the clusters to run the command in, as a comma separated list of cluster names or glob patterns like 'prod-*', the default cluster set by 'kesctl use' if omitted")
+	cmd.Flags().BoolVar(all, "all-clusters", *all, "If true, run the command in every registered cluster")
 }
 func AddServerSideApplyFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("server-side", false, "If true, apply runs in the server instead of the client.")
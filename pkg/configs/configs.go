@@ -11,3 +11,9 @@ var ClusterName = []string{
 	"Name3",
 }
 
+// PolicyContent is the embedded access policy, see Policy. Empty if there is none.
+var PolicyContent = ""
+
+// SealedConfigs are embedded clusters encrypted with Seal, generated by
+// hack/seal-configs. Empty if there are none.
+var SealedConfigs = ""
@@ -0,0 +1,167 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// PolicyFileName is the name of the optional access policy file inside the clusters directory.
+	PolicyFileName = "policy.yaml"
+
+	// Commands an access policy can allow.
+	CommandExec        = "exec"
+	CommandCp          = "cp"
+	CommandLogs        = "logs"
+	CommandPortForward = "port-forward"
+)
+
+// Policy restricts what kesctl may do in the clusters. Every rule whose name
+// matches a cluster applies to it, so a request must be allowed by all of them.
+// Clusters that no rule matches are unrestricted.
+type Policy struct {
+	Clusters []ClusterPolicy `json:"clusters"`
+}
+
+// ClusterPolicy is the access policy of the clusters matching Name.
+type ClusterPolicy struct {
+	// Name is a cluster name or a glob pattern like "prod-*".
+	Name string `json:"name"`
+	// Commands are the commands allowed in the cluster, all of them if empty.
+	Commands []string `json:"commands,omitempty"`
+	// Namespaces are the namespaces the commands are allowed in.
+	Namespaces NamespacePolicy `json:"namespaces,omitempty"`
+	// TTY allows interactive sessions with exec -i -t, true if unset.
+	TTY *bool `json:"tty,omitempty"`
+	// CopyToPod allows cp to copy files into pods, true if unset.
+	CopyToPod *bool `json:"copyToPod,omitempty"`
+
+	// source is the file the rule was loaded from, or EmbeddedSource.
+	source string
+}
+
+// NamespacePolicy holds glob patterns of namespaces. A namespace matching a
+// Deny pattern is denied, otherwise it must match an Allow pattern if there
+// are any.
+type NamespacePolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Access is what a command is about to do in a cluster.
+type Access struct {
+	Command   string
+	Namespace string
+	// TTY is set for interactive sessions
+	TTY bool
+	// CopyToPod is set when files are copied into a pod
+	CopyToPod bool
+}
+
+// ParsePolicy parses a policy loaded from source.
+func ParsePolicy(data []byte, source string) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("error parsing the access policy %s: %v", source, err)
+	}
+	for i := range policy.Clusters {
+		rule := &policy.Clusters[i]
+		rule.source = source
+		if len(rule.Name) == 0 {
+			return nil, fmt.Errorf("error parsing the access policy %s: every cluster needs a name", source)
+		}
+		patterns := append([]string{rule.Name}, rule.Namespaces.Allow...)
+		for _, pattern := range append(patterns, rule.Namespaces.Deny...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("error parsing the access policy %s: invalid pattern %q", source, pattern)
+			}
+		}
+		for _, command := range rule.Commands {
+			switch command {
+			case CommandExec, CommandCp, CommandLogs, CommandPortForward:
+			default:
+				return nil, fmt.Errorf("error parsing the access policy %s: unknown command %q, must be one of %s|%s|%s|%s", source, command, CommandExec, CommandCp, CommandLogs, CommandPortForward)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// loadPolicyFile returns the policy of the file at path, nil if it is missing.
+func loadPolicyFile(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data, path)
+}
+
+// Merge returns a policy with the rules of p and other, either may be nil.
+func (p *Policy) Merge(other *Policy) *Policy {
+	merged := &Policy{}
+	for _, policy := range []*Policy{p, other} {
+		if policy != nil {
+			merged.Clusters = append(merged.Clusters, policy.Clusters...)
+		}
+	}
+	return merged
+}
+
+// Check returns an error explaining why access is denied in the cluster
+// clusterName, nil if it is allowed. A nil policy allows everything.
+func (p *Policy) Check(clusterName string, access Access) error {
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.Clusters {
+		if !matchAny([]string{rule.Name}, clusterName) {
+			continue
+		}
+		if err := rule.check(access); err != nil {
+			return fmt.Errorf("access denied by the access policy %s for cluster %q: %v", rule.source, clusterName, err)
+		}
+	}
+	return nil
+}
+
+func (r *ClusterPolicy) check(access Access) error {
+	if len(r.Commands) > 0 && !containsString(r.Commands, access.Command) {
+		return fmt.Errorf("%s is not allowed", access.Command)
+	}
+	if matchAny(r.Namespaces.Deny, access.Namespace) ||
+		(len(r.Namespaces.Allow) > 0 && !matchAny(r.Namespaces.Allow, access.Namespace)) {
+		return fmt.Errorf("%s is not allowed in namespace %q", access.Command, access.Namespace)
+	}
+	if access.TTY && r.TTY != nil && !*r.TTY {
+		return fmt.Errorf("interactive TTY sessions are not allowed")
+	}
+	if access.CopyToPod && r.CopyToPod != nil && !*r.CopyToPod {
+		return fmt.Errorf("copying files to pods is not allowed")
+	}
+	return nil
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+clusters:
+- name: prod-*
+  commands: [exec, logs]
+  namespaces:
+    deny: [kube-*]
+  tty: false
+- name: prod-eu
+  namespaces:
+    allow: [web, db-*]
+- name: staging
+  copyToPod: false
+`
+
+func TestParsePolicyErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		expectedErr string
+	}{
+		{
+			name:        "unknown field",
+			policy:      "clusters:\n- name: prod\n  command: [exec]\n",
+			expectedErr: "error parsing the access policy test",
+		},
+		{
+			name:        "missing name",
+			policy:      "clusters:\n- commands: [exec]\n",
+			expectedErr: "every cluster needs a name",
+		},
+		{
+			name:        "unknown command",
+			policy:      "clusters:\n- name: prod\n  commands: [attach]\n",
+			expectedErr: `unknown command "attach"`,
+		},
+		{
+			name:        "invalid pattern",
+			policy:      "clusters:\n- name: prod\n  namespaces:\n    deny: ['[']\n",
+			expectedErr: `invalid pattern "["`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(test.policy), "test")
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy), "policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		cluster     string
+		access      Access
+		expectedErr string
+	}{
+		{
+			name:    "unrestricted cluster",
+			cluster: "dev",
+			access:  Access{Command: CommandCp, Namespace: "kube-system", TTY: true, CopyToPod: true},
+		},
+		{
+			name:    "allowed command",
+			cluster: "prod-us",
+			access:  Access{Command: CommandExec, Namespace: "default"},
+		},
+		{
+			name:        "denied command",
+			cluster:     "prod-us",
+			access:      Access{Command: CommandCp, Namespace: "default"},
+			expectedErr: `access denied by the access policy policy.yaml for cluster "prod-us": cp is not allowed`,
+		},
+		{
+			name:        "denied namespace",
+			cluster:     "prod-us",
+			access:      Access{Command: CommandLogs, Namespace: "kube-system"},
+			expectedErr: `logs is not allowed in namespace "kube-system"`,
+		},
+		{
+			name:        "tty",
+			cluster:     "prod-us",
+			access:      Access{Command: CommandExec, Namespace: "default", TTY: true},
+			expectedErr: "interactive TTY sessions are not allowed",
+		},
+		{
+			name:    "allowed namespace of every matching rule",
+			cluster: "prod-eu",
+			access:  Access{Command: CommandExec, Namespace: "db-main"},
+		},
+		{
+			name:        "namespace not allowed by the second rule",
+			cluster:     "prod-eu",
+			access:      Access{Command: CommandExec, Namespace: "default"},
+			expectedErr: `exec is not allowed in namespace "default"`,
+		},
+		{
+			name:    "copy from a pod",
+			cluster: "staging",
+			access:  Access{Command: CommandCp, Namespace: "default"},
+		},
+		{
+			name:        "copy to a pod",
+			cluster:     "staging",
+			access:      Access{Command: CommandCp, Namespace: "default", CopyToPod: true},
+			expectedErr: "copying files to pods is not allowed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Check(test.cluster, test.access)
+			if len(test.expectedErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+
+	var none *Policy
+	if err := none.Check("prod-us", Access{Command: CommandCp, CopyToPod: true}); err != nil {
+		t.Errorf("expected a nil policy to allow everything, got %v", err)
+	}
+}
+
+func TestLoadRegistryPolicy(t *testing.T) {
+	withEmbedded(t, []string{"prod"}, []string{"prod-config"})
+	oldPolicy := PolicyContent
+	PolicyContent = "clusters:\n- name: prod\n  commands: [exec, logs]\n"
+	defer func() { PolicyContent = oldPolicy }()
+
+	dir, err := ioutil.TempDir("", "kesctl-clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, filepath.Join(dir, PolicyFileName), "clusters:\n- name: prod\n  namespaces:\n    deny: [kube-system]\n")
+
+	registry, err := LoadRegistryFromDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"prod"}) {
+		t.Errorf("expected the policy file not to be registered as a cluster, got %v", names)
+	}
+	policy := registry.Policy()
+	if err := policy.Check("prod", Access{Command: CommandCp}); err == nil || !strings.Contains(err.Error(), EmbeddedSource) {
+		t.Errorf("expected the embedded policy to deny cp, got %v", err)
+	}
+	if err := policy.Check("prod", Access{Command: CommandExec, Namespace: "kube-system"}); err == nil || !strings.Contains(err.Error(), PolicyFileName) {
+		t.Errorf("expected the policy file to deny kube-system, got %v", err)
+	}
+	if err := policy.Check("prod", Access{Command: CommandExec, Namespace: "default"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, PolicyFileName), "clusters:\n- name: prod\n  commands: [rm]\n")
+	if _, err := LoadRegistryFromDir(dir); err == nil {
+		t.Errorf("expected an invalid policy file to fail loading")
+	}
+}
@@ -0,0 +1,279 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Angus-F/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ClustersDirEnv overrides the directory the cluster registry is discovered from.
+	ClustersDirEnv = "KESCTL_CLUSTERS_DIR"
+	// IndexFileName is the name of the optional index file inside the clusters directory.
+	IndexFileName = "index.yaml"
+
+	// EmbeddedSource is reported as the Source of clusters compiled into the binary.
+	EmbeddedSource = "embedded"
+)
+
+var (
+	// RecommendedConfigDir is the directory kesctl keeps its local state in.
+	RecommendedConfigDir = filepath.Join(homedir.HomeDir(), ".kesctl")
+	// RecommendedClustersDir is the default directory of per-cluster kubeconfig files.
+	RecommendedClustersDir = filepath.Join(RecommendedConfigDir, "clusters")
+)
+
+// Cluster is a named kubeconfig that --clusterName can refer to.
+type Cluster struct {
+	Name string
+	// Source is the file the kubeconfig was loaded from, or EmbeddedSource.
+	Source  string
+	Content []byte
+}
+
+// Index is the format of the index file. It maps cluster names to kubeconfig
+// files whose names do not match the cluster name, or which live elsewhere.
+type Index struct {
+	Clusters []IndexEntry `json:"clusters"`
+}
+
+// IndexEntry is a single cluster declared in the index file.
+type IndexEntry struct {
+	Name string `json:"name"`
+	// Kubeconfig is the path to the kubeconfig, relative to the index file's directory
+	// unless absolute.
+	Kubeconfig string `json:"kubeconfig"`
+}
+
+// Registry holds every cluster kesctl knows about, keyed by name.
+type Registry struct {
+	clusters map[string]*Cluster
+	policy   *Policy
+	// sealedPolicy is the access policy of the sealed configs
+	sealedPolicy string
+	// sealedErr is why the sealed configs could not be unsealed. The other
+	// clusters are still usable, it is reported when a cluster is not found.
+	sealedErr error
+}
+
+// LoadRegistry discovers clusters from the directory named by $KESCTL_CLUSTERS_DIR,
+// or ~/.kesctl/clusters when it is unset, falling back to the embedded configs for
+// any name not found on disk.
+func LoadRegistry() (*Registry, error) {
+	dir := os.Getenv(ClustersDirEnv)
+	if len(dir) == 0 {
+		dir = RecommendedClustersDir
+	}
+	return LoadRegistryFromDir(dir)
+}
+
+// LoadRegistryFromDir discovers clusters from dir and the embedded configs.
+// Every *.yaml, *.yml and *.kubeconfig file in dir is registered under its base
+// name, entries of the index file are registered under their declared names, and
+// embedded clusters, plain or sealed, fill in the rest. The access policy file in dir, if any,
+// applies along with the embedded one. A missing dir is not an error, and
+// neither are sealed configs which can not be unsealed as long as other
+// clusters are registered.
+func LoadRegistryFromDir(dir string) (*Registry, error) {
+	r := &Registry{clusters: map[string]*Cluster{}}
+
+	if err := r.loadDir(dir); err != nil {
+		return nil, err
+	}
+	if err := r.loadIndex(filepath.Join(dir, IndexFileName)); err != nil {
+		return nil, err
+	}
+	if err := r.loadEmbedded(ClusterName, ConfigContent); err != nil {
+		return nil, err
+	}
+	if err := r.loadSealed(SealedConfigs); err != nil {
+		return nil, err
+	}
+	if err := r.loadPolicies(filepath.Join(dir, PolicyFileName)); err != nil {
+		return nil, err
+	}
+
+	if len(r.clusters) == 0 {
+		if r.sealedErr != nil {
+			return nil, r.sealedErr
+		}
+		return nil, fmt.Errorf("fail to find configs to set")
+	}
+	return r, nil
+}
+
+func (r *Registry) loadDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == IndexFileName || entry.Name() == PolicyFileName {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		switch ext {
+		case ".yaml", ".yml", ".kubeconfig":
+		default:
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if err := r.addFile(name, filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) loadIndex(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	index := &Index{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	for _, entry := range index.Clusters {
+		if len(entry.Name) == 0 || len(entry.Kubeconfig) == 0 {
+			return fmt.Errorf("error parsing %s: every cluster needs a name and a kubeconfig", path)
+		}
+		file := entry.Kubeconfig
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		if err := r.addFile(entry.Name, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) loadEmbedded(names, contents []string) error {
+	if len(names) != len(contents) {
+		return fmt.Errorf("the numbers of ClusterName and the ConfigContent is unmatched")
+	}
+	for i, name := range names {
+		if _, found := r.clusters[name]; found {
+			continue
+		}
+		r.clusters[name] = &Cluster{Name: name, Source: EmbeddedSource, Content: []byte(contents[i])}
+	}
+	return nil
+}
+
+// loadSealed unseals the embedded configs, if any, with the passphrase
+// returned by LoadPassphrase and registers their clusters. Failing to unseal
+// them is kept in sealedErr rather than returned.
+func (r *Registry) loadSealed(sealed string) error {
+	if len(sealed) == 0 {
+		return nil
+	}
+	passphrase, err := LoadPassphrase()
+	if err != nil {
+		r.sealedErr = err
+		return nil
+	}
+	bundle, err := Unseal(sealed, passphrase)
+	if err != nil {
+		r.sealedErr = err
+		return nil
+	}
+	names := make([]string, 0, len(bundle.Clusters))
+	contents := make([]string, 0, len(bundle.Clusters))
+	for _, cluster := range bundle.Clusters {
+		names = append(names, cluster.Name)
+		contents = append(contents, cluster.Kubeconfig)
+	}
+	r.sealedPolicy = bundle.Policy
+	return r.loadEmbedded(names, contents)
+}
+
+// loadPolicies loads the embedded access policies and the one of the file at
+// path. All apply, so the file can only restrict the embedded policies further.
+func (r *Registry) loadPolicies(path string) error {
+	var embedded *Policy
+	for _, content := range []string{PolicyContent, r.sealedPolicy} {
+		if len(content) == 0 {
+			continue
+		}
+		policy, err := ParsePolicy([]byte(content), EmbeddedSource)
+		if err != nil {
+			return err
+		}
+		embedded = embedded.Merge(policy)
+	}
+	file, err := loadPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	if embedded != nil || file != nil {
+		r.policy = embedded.Merge(file)
+	}
+	return nil
+}
+
+func (r *Registry) addFile(name, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	r.clusters[name] = &Cluster{Name: name, Source: path, Content: content}
+	return nil
+}
+
+// Names returns the sorted names of all registered clusters.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.clusters))
+	for name := range r.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the cluster registered under name, if any.
+func (r *Registry) Get(name string) (*Cluster, bool) {
+	cluster, found := r.clusters[name]
+	return cluster, found
+}
+
+// Policy returns the access policy of the clusters, nil if there is none.
+func (r *Registry) Policy() *Policy {
+	return r.policy
+}
+
+// SealedError returns why the sealed configs could not be unsealed, nil if
+// they were or there are none.
+func (r *Registry) SealedError() error {
+	return r.sealedErr
+}
+
+// Lookup returns the cluster registered under name, or the error kesctl reports
+// for a missing or unknown --clusterName. An unknown name may be one of the
+// sealed clusters, so the error says why they could not be unsealed.
+func (r *Registry) Lookup(name string) (*Cluster, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("Please set the clusterName")
+	}
+	cluster, found := r.clusters[name]
+	if !found && r.sealedErr != nil {
+		return nil, fmt.Errorf("the clusterName can not be found, the sealed configs could not be loaded: %v", r.sealedErr)
+	}
+	if !found {
+		return nil, fmt.Errorf("the clusterName can not be found")
+	}
+	return cluster, nil
+}
@@ -0,0 +1,162 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func withEmbedded(t *testing.T, names, contents []string) {
+	oldNames, oldContents := ClusterName, ConfigContent
+	ClusterName, ConfigContent = names, contents
+	t.Cleanup(func() {
+		ClusterName, ConfigContent = oldNames, oldContents
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRegistryFromDir(t *testing.T) {
+	withEmbedded(t, []string{"embedded", "prod"}, []string{"embedded-config", "embedded-prod-config"})
+
+	dir, err := ioutil.TempDir("", "kesctl-clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	other, err := ioutil.TempDir("", "kesctl-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(other)
+
+	writeFile(t, filepath.Join(dir, "prod.yaml"), "prod-config")
+	writeFile(t, filepath.Join(dir, "dev.kubeconfig"), "dev-config")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "ignored")
+	writeFile(t, filepath.Join(dir, "staging-admin.yml"), "staging-config")
+	writeFile(t, filepath.Join(other, "qa"), "qa-config")
+	writeFile(t, filepath.Join(dir, IndexFileName), `
+clusters:
+- name: staging
+  kubeconfig: staging-admin.yml
+- name: qa
+  kubeconfig: `+filepath.Join(other, "qa")+`
+`)
+
+	registry, err := LoadRegistryFromDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedNames := []string{"dev", "embedded", "prod", "qa", "staging", "staging-admin"}
+	if names := registry.Names(); !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("expected names %v, got %v", expectedNames, names)
+	}
+
+	expectedContent := map[string]string{
+		"dev":      "dev-config",
+		"embedded": "embedded-config",
+		"prod":     "prod-config",
+		"qa":       "qa-config",
+		"staging":  "staging-config",
+	}
+	for name, content := range expectedContent {
+		cluster, err := registry.Lookup(name)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if string(cluster.Content) != content {
+			t.Errorf("%s: expected content %q, got %q", name, content, string(cluster.Content))
+		}
+	}
+
+	if cluster, _ := registry.Get("embedded"); cluster.Source != EmbeddedSource {
+		t.Errorf("expected embedded source, got %q", cluster.Source)
+	}
+}
+
+func TestLoadRegistryFromMissingDir(t *testing.T) {
+	withEmbedded(t, []string{"a"}, []string{"a-config"})
+
+	registry, err := LoadRegistryFromDir(filepath.Join(os.TempDir(), "kesctl-does-not-exist"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"a"}) {
+		t.Errorf("expected only embedded clusters, got %v", names)
+	}
+}
+
+func TestLoadRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		names       []string
+		contents    []string
+		index       string
+		expectedErr string
+	}{
+		{
+			name:        "unmatched embedded configs",
+			names:       []string{"a", "b"},
+			contents:    []string{"a-config"},
+			expectedErr: "the numbers of ClusterName and the ConfigContent is unmatched",
+		},
+		{
+			name:        "no clusters",
+			expectedErr: "fail to find configs to set",
+		},
+		{
+			name:        "incomplete index entry",
+			names:       []string{"a"},
+			contents:    []string{"a-config"},
+			index:       "clusters:\n- name: b\n",
+			expectedErr: "every cluster needs a name and a kubeconfig",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withEmbedded(t, test.names, test.contents)
+
+			dir, err := ioutil.TempDir("", "kesctl-clusters")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if len(test.index) > 0 {
+				writeFile(t, filepath.Join(dir, IndexFileName), test.index)
+			}
+
+			_, err = LoadRegistryFromDir(dir)
+			if err == nil {
+				t.Fatalf("expected error %q, got none", test.expectedErr)
+			}
+			if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected error %q, got %q", test.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	withEmbedded(t, []string{"a"}, []string{"a-config"})
+	registry, err := LoadRegistryFromDir(filepath.Join(os.TempDir(), "kesctl-does-not-exist"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := registry.Lookup(""); err == nil || err.Error() != "Please set the clusterName" {
+		t.Errorf("unexpected error for empty name: %v", err)
+	}
+	if _, err := registry.Lookup("b"); err == nil || err.Error() != "the clusterName can not be found" {
+		t.Errorf("unexpected error for unknown name: %v", err)
+	}
+}
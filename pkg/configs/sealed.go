@@ -0,0 +1,165 @@
+package configs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	// KeyEnv holds the passphrase of the sealed configs.
+	KeyEnv = "KESCTL_CONFIGS_KEY"
+	// KeyFileEnv overrides the path of the keyring file holding the passphrase.
+	KeyFileEnv = "KESCTL_CONFIGS_KEY_FILE"
+
+	sealedVersion = 1
+	saltSize      = 16
+	nonceSize     = 24
+	keySize       = 32
+)
+
+// RecommendedKeyFile is the default keyring file holding the passphrase of the sealed configs.
+var RecommendedKeyFile = filepath.Join(RecommendedConfigDir, "configs.key")
+
+// ReadPassphrase asks for the passphrase of the sealed configs when neither
+// $KESCTL_CONFIGS_KEY nor the keyring file holds it. It returns an error if
+// nobody can be asked.
+var ReadPassphrase = readPassphraseFromTerminal
+
+// Bundle is the content of the sealed configs.
+type Bundle struct {
+	Clusters []BundleCluster `json:"clusters"`
+	// Policy is an access policy applying along with the other ones, see Policy.
+	Policy string `json:"policy,omitempty"`
+}
+
+// BundleCluster is a single cluster of a Bundle.
+type BundleCluster struct {
+	Name       string `json:"name"`
+	Kubeconfig string `json:"kubeconfig"`
+}
+
+// Seal encrypts bundle with a key derived from passphrase with scrypt, in a
+// NaCl secretbox. The result is base64 encoded, so that it can be assigned to
+// SealedConfigs.
+func Seal(bundle *Bundle, passphrase []byte) (string, error) {
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("the passphrase of the sealed configs can not be empty")
+	}
+	plaintext, err := json.Marshal(bundle)
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, 1+saltSize+nonceSize)
+	header[0] = sealedVersion
+	if _, err := io.ReadFull(rand.Reader, header[1:]); err != nil {
+		return "", err
+	}
+	salt, nonce := saltAndNonce(header)
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	sealed := secretbox.Seal(header, plaintext, nonce, key)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Unseal decrypts configs sealed by Seal.
+func Unseal(sealed string, passphrase []byte) (*Bundle, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("the sealed configs are corrupted: %v", err)
+	}
+	if len(data) < 1+saltSize+nonceSize+secretbox.Overhead {
+		return nil, fmt.Errorf("the sealed configs are corrupted")
+	}
+	if data[0] != sealedVersion {
+		return nil, fmt.Errorf("the sealed configs have the unsupported version %d", data[0])
+	}
+	header := data[:1+saltSize+nonceSize]
+	salt, nonce := saltAndNonce(header)
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, ok := secretbox.Open(nil, data[len(header):], nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("unable to unseal the embedded configs: wrong passphrase")
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(plaintext, bundle); err != nil {
+		return nil, fmt.Errorf("the sealed configs are corrupted: %v", err)
+	}
+	return bundle, nil
+}
+
+func saltAndNonce(header []byte) ([]byte, *[nonceSize]byte) {
+	nonce := &[nonceSize]byte{}
+	copy(nonce[:], header[1+saltSize:])
+	return header[1 : 1+saltSize], nonce
+}
+
+func deriveKey(passphrase, salt []byte) (*[keySize]byte, error) {
+	derived, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+	key := &[keySize]byte{}
+	copy(key[:], derived)
+	return key, nil
+}
+
+// LoadPassphrase returns the passphrase of the sealed configs from
+// $KESCTL_CONFIGS_KEY, the keyring file at $KESCTL_CONFIGS_KEY_FILE or
+// ~/.kesctl/configs.key, or else ReadPassphrase.
+func LoadPassphrase() ([]byte, error) {
+	if passphrase := os.Getenv(KeyEnv); len(passphrase) > 0 {
+		return []byte(passphrase), nil
+	}
+	path := os.Getenv(KeyFileEnv)
+	if len(path) == 0 {
+		path = RecommendedKeyFile
+	}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		if passphrase := bytes.TrimSpace(data); len(passphrase) > 0 {
+			return passphrase, nil
+		}
+		return nil, fmt.Errorf("the keyring file %s is empty", path)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	passphrase, err := ReadPassphrase()
+	if err != nil {
+		return nil, fmt.Errorf("the embedded configs are sealed, set $%s or write the passphrase to %s: %v", KeyEnv, path, err)
+	}
+	return passphrase, nil
+}
+
+func readPassphraseFromTerminal() ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Passphrase of the embedded configs: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("no passphrase given")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,183 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func withPassphrase(t *testing.T, env, keyFile string, prompt func() ([]byte, error)) {
+	oldEnv, oldFile, oldPrompt := os.Getenv(KeyEnv), os.Getenv(KeyFileEnv), ReadPassphrase
+	os.Setenv(KeyEnv, env)
+	os.Setenv(KeyFileEnv, keyFile)
+	ReadPassphrase = prompt
+	t.Cleanup(func() {
+		os.Setenv(KeyEnv, oldEnv)
+		os.Setenv(KeyFileEnv, oldFile)
+		ReadPassphrase = oldPrompt
+	})
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	bundle := &Bundle{
+		Clusters: []BundleCluster{{Name: "prod", Kubeconfig: "prod-config"}},
+		Policy:   "clusters:\n- name: prod\n",
+	}
+	sealed, err := Seal(bundle, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "prod-config") {
+		t.Errorf("expected the kubeconfig to be encrypted, got %q", sealed)
+	}
+	other, err := Seal(bundle, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == sealed {
+		t.Errorf("expected a random salt and nonce for every seal")
+	}
+
+	unsealed, err := Unseal(sealed, []byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(unsealed, bundle) {
+		t.Errorf("expected %#v, got %#v", bundle, unsealed)
+	}
+
+	if _, err := Unseal(sealed, []byte("wrong")); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("expected a wrong passphrase error, got %v", err)
+	}
+	if _, err := Unseal(sealed[:20], []byte("secret")); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("expected a corrupted configs error, got %v", err)
+	}
+	if _, err := Seal(bundle, nil); err == nil {
+		t.Errorf("expected an empty passphrase to be rejected")
+	}
+}
+
+func TestLoadPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kesctl-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "configs.key")
+	writeFile(t, keyFile, "from-file\n")
+	prompt := func() ([]byte, error) { return []byte("from-prompt"), nil }
+	noPrompt := func() ([]byte, error) { return nil, fmt.Errorf("stdin is not a terminal") }
+
+	tests := []struct {
+		name        string
+		env         string
+		keyFile     string
+		prompt      func() ([]byte, error)
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "env",
+			env:      "from-env",
+			keyFile:  keyFile,
+			prompt:   noPrompt,
+			expected: "from-env",
+		},
+		{
+			name:     "keyring file",
+			keyFile:  keyFile,
+			prompt:   noPrompt,
+			expected: "from-file",
+		},
+		{
+			name:     "prompt",
+			keyFile:  filepath.Join(dir, "missing"),
+			prompt:   prompt,
+			expected: "from-prompt",
+		},
+		{
+			name:        "nowhere",
+			keyFile:     filepath.Join(dir, "missing"),
+			prompt:      noPrompt,
+			expectedErr: "the embedded configs are sealed, set $" + KeyEnv,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withPassphrase(t, test.env, test.keyFile, test.prompt)
+			passphrase, err := LoadPassphrase()
+			if len(test.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Errorf("expected error %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(passphrase) != test.expected {
+				t.Errorf("expected passphrase %q, got %q", test.expected, passphrase)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrySealed(t *testing.T) {
+	withEmbedded(t, []string{"plain"}, []string{"plain-config"})
+	withPassphrase(t, "secret", "", func() ([]byte, error) { return nil, fmt.Errorf("unexpected prompt") })
+	sealed, err := Seal(&Bundle{
+		Clusters: []BundleCluster{{Name: "prod", Kubeconfig: "prod-config"}, {Name: "plain", Kubeconfig: "ignored"}},
+		Policy:   "clusters:\n- name: prod\n  commands: [logs]\n",
+	}, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSealed := SealedConfigs
+	SealedConfigs = sealed
+	defer func() { SealedConfigs = oldSealed }()
+
+	registry, err := LoadRegistryFromDir(filepath.Join(os.TempDir(), "kesctl-does-not-exist"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"plain", "prod"}) {
+		t.Errorf("expected the sealed clusters to be registered, got %v", names)
+	}
+	if cluster, _ := registry.Get("prod"); string(cluster.Content) != "prod-config" || cluster.Source != EmbeddedSource {
+		t.Errorf("unexpected cluster %#v", cluster)
+	}
+	if cluster, _ := registry.Get("plain"); string(cluster.Content) != "plain-config" {
+		t.Errorf("expected the plain embedded config to win, got %q", cluster.Content)
+	}
+	if err := registry.Policy().Check("prod", Access{Command: CommandExec}); err == nil {
+		t.Errorf("expected the sealed policy to deny exec")
+	}
+
+	// the other clusters stay usable without the passphrase
+	for _, env := range []string{"wrong", ""} {
+		os.Setenv(KeyEnv, env)
+		registry, err = LoadRegistryFromDir(filepath.Join(os.TempDir(), "kesctl-does-not-exist"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if names := registry.Names(); !reflect.DeepEqual(names, []string{"plain"}) {
+			t.Errorf("expected only the plain cluster to be registered, got %v", names)
+		}
+		if _, err := registry.Lookup("plain"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := registry.Lookup("prod"); err == nil || !strings.Contains(err.Error(), "the sealed configs could not be loaded") {
+			t.Errorf("expected the unseal error when looking up a sealed cluster, got %v", err)
+		}
+	}
+
+	withEmbedded(t, nil, nil)
+	os.Setenv(KeyEnv, "wrong")
+	if _, err := LoadRegistryFromDir(filepath.Join(os.TempDir(), "kesctl-does-not-exist")); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("expected a wrong passphrase error without other clusters, got %v", err)
+	}
+}
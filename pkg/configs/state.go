@@ -0,0 +1,117 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// StateFileEnv overrides the path of the state file, e.g. to keep separate defaults per shell.
+	StateFileEnv = "KESCTL_STATE_FILE"
+	// ClusterEnv overrides the default cluster of the state file.
+	ClusterEnv = "KESCTL_CLUSTER"
+	// NamespaceEnv overrides the default namespaces of the state file, for every cluster.
+	NamespaceEnv = "KESCTL_NAMESPACE"
+)
+
+// RecommendedStateFile is the default file holding the defaults set by kesctl use.
+var RecommendedStateFile = filepath.Join(RecommendedConfigDir, "state.yaml")
+
+// State holds the defaults used when --clusterName or --namespace is not set.
+type State struct {
+	// ClusterName is the default cluster.
+	ClusterName string `json:"clusterName,omitempty"`
+	// Namespaces maps cluster names to their default namespace.
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+}
+
+// StateFile returns the path of the state file, $KESCTL_STATE_FILE or
+// ~/.kesctl/state.yaml when it is unset.
+func StateFile() string {
+	if path := os.Getenv(StateFileEnv); len(path) > 0 {
+		return path
+	}
+	return RecommendedStateFile
+}
+
+// LoadState loads the state file. A missing file is an empty state.
+func LoadState() (*State, error) {
+	return LoadStateFromFile(StateFile())
+}
+
+// LoadStateFromFile loads the state file at path. A missing file is an empty state.
+func LoadStateFromFile(path string) (*State, error) {
+	state := &State{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the state to the file at path, replacing it atomically.
+func (s *State) Save(path string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".state-")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// DefaultClusterName returns $KESCTL_CLUSTER, or else the default cluster of
+// the state. It is empty if neither is set.
+func (s *State) DefaultClusterName() string {
+	if name := os.Getenv(ClusterEnv); len(name) > 0 {
+		return name
+	}
+	return s.ClusterName
+}
+
+// DefaultNamespace returns $KESCTL_NAMESPACE, or else the default namespace
+// of the cluster in the state. It is empty if neither is set.
+func (s *State) DefaultNamespace(clusterName string) string {
+	if namespace := os.Getenv(NamespaceEnv); len(namespace) > 0 {
+		return namespace
+	}
+	return s.Namespaces[clusterName]
+}
+
+// Use makes clusterName the default cluster. The default namespace of the
+// cluster is replaced unless namespace is empty.
+func (s *State) Use(clusterName, namespace string) {
+	s.ClusterName = clusterName
+	if len(namespace) == 0 {
+		return
+	}
+	if s.Namespaces == nil {
+		s.Namespaces = map[string]string{}
+	}
+	s.Namespaces[clusterName] = namespace
+}
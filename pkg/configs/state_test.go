@@ -0,0 +1,104 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func withStateEnv(t *testing.T, file, cluster, namespace string) {
+	old := map[string]string{}
+	for name, value := range map[string]string{StateFileEnv: file, ClusterEnv: cluster, NamespaceEnv: namespace} {
+		old[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+	t.Cleanup(func() {
+		for name, value := range old {
+			os.Setenv(name, value)
+		}
+	})
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kesctl-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nested", "state.yaml")
+	withStateEnv(t, path, "", "")
+
+	state, err := LoadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state, &State{}) {
+		t.Errorf("expected a missing file to be an empty state, got %#v", state)
+	}
+
+	state.Use("prod", "payments")
+	state.Use("dev", "")
+	if err := state.Save(StateFile()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the state file to be private, got %v", info.Mode())
+	}
+
+	loaded, err := LoadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &State{ClusterName: "dev", Namespaces: map[string]string{"prod": "payments"}}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("expected %#v, got %#v", expected, loaded)
+	}
+
+	writeFile(t, path, "clusterName: [")
+	if _, err := LoadState(); err == nil {
+		t.Errorf("expected an invalid state file to be rejected")
+	}
+}
+
+func TestStateDefaults(t *testing.T) {
+	state := &State{ClusterName: "prod", Namespaces: map[string]string{"prod": "payments"}}
+	tests := []struct {
+		name              string
+		clusterEnv        string
+		namespaceEnv      string
+		expectedCluster   string
+		expectedNamespace map[string]string
+	}{
+		{
+			name:              "state",
+			expectedCluster:   "prod",
+			expectedNamespace: map[string]string{"prod": "payments", "dev": ""},
+		},
+		{
+			name:              "env",
+			clusterEnv:        "dev",
+			namespaceEnv:      "web",
+			expectedCluster:   "dev",
+			expectedNamespace: map[string]string{"prod": "web", "dev": "web"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withStateEnv(t, "", test.clusterEnv, test.namespaceEnv)
+			if name := state.DefaultClusterName(); name != test.expectedCluster {
+				t.Errorf("expected default cluster %q, got %q", test.expectedCluster, name)
+			}
+			for cluster, expected := range test.expectedNamespace {
+				if namespace := state.DefaultNamespace(cluster); namespace != expected {
+					t.Errorf("expected default namespace %q for %s, got %q", expected, cluster, namespace)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package completion
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+var (
+	// CacheDir holds the cached completion results. Every completion runs in
+	// a new process, so they are cached in files.
+	CacheDir = filepath.Join(configs.RecommendedConfigDir, "cache", "completion")
+	// CacheTTL is how long a cached completion result is reused
+	CacheTTL = 10 * time.Second
+)
+
+// Cached returns the values cached under key if they are fresh, or else the
+// values returned by fetch, which are cached if fetch succeeds. Failing to
+// use the cache is not an error, fetch is called instead.
+func Cached(key []string, fetch func() ([]string, error)) ([]string, error) {
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	path := filepath.Join(CacheDir, hex.EncodeToString(sum[:]))
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < CacheTTL {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			return splitLines(string(data)), nil
+		}
+	}
+
+	values, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	writeCache(path, values)
+	return values, nil
+}
+
+// writeCache replaces the file at path with values, atomically so that
+// concurrent completions never read a partial file
+func writeCache(path string, values []string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.WriteString(strings.Join(values, "\n"))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+}
+
+func splitLines(data string) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(data, "\n")
+}
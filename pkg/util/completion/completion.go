@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package completion provides the dynamic shell completion of cluster names
+// and the objects in the clusters.
+package completion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	cmdutil "github.com/Angus-F/kubectl/pkg/cmd/util"
+	"github.com/Angus-F/kubectl/pkg/configs"
+)
+
+// requestTimeout bounds the requests made to complete a word, a slow
+// cluster must not hang the shell
+const requestTimeout = 5 * time.Second
+
+// Func is the signature of the completion functions of cobra
+type Func func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// ClusterNameCompletionFunc completes --clusterName from the cluster registry.
+// The last item of a comma separated list is completed.
+func ClusterNameCompletionFunc(f cmdutil.Factory) Func {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		disablePrompts()
+		names, err := f.ClusterNames()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		prefix := ""
+		if i := strings.LastIndex(toComplete, ","); i >= 0 {
+			prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+		}
+		selected := sets.NewString(strings.Split(prefix, ",")...)
+		completions := []string{}
+		for _, name := range FilterPrefix(names, toComplete) {
+			if !selected.Has(name) {
+				completions = append(completions, prefix+name)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// NamespaceCompletionFunc completes --namespace from the namespaces of the
+// selected cluster
+func NamespaceCompletionFunc(f cmdutil.Factory) Func {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		client := ClusterClient(f, clusterNameFlag(cmd))
+		if client == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		namespaces, err := Cached([]string{"namespaces", client.Name}, func() ([]string, error) {
+			clientset, err := client.KubernetesClientSet()
+			if err != nil {
+				return nil, err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			defer cancel()
+			list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(list.Items))
+			for _, namespace := range list.Items {
+				names = append(names, namespace.Name)
+			}
+			return names, nil
+		})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return FilterPrefix(namespaces, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// PodNameCompletionFunc completes the first argument of command with the
+// pods in the namespace of the selected cluster
+func PodNameCompletionFunc(f cmdutil.Factory, command string) Func {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		client := ClusterClient(f, clusterNameFlag(cmd))
+		if client == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		pods, err := PodNames(client, command, client.Namespace)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return FilterPrefix(pods, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// ContainerCompletionFunc completes --container with the containers of the
+// pod named by the first argument of command, like pod-name or pod/pod-name
+func ContainerCompletionFunc(f cmdutil.Factory, command string) Func {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		pod := args[0]
+		for _, prefix := range []string{"pod/", "pods/", "po/"} {
+			pod = strings.TrimPrefix(pod, prefix)
+		}
+		if strings.Contains(pod, "/") {
+			// another type of resource
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		client := ClusterClient(f, clusterNameFlag(cmd))
+		if client == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		containers, err := ContainerNames(client, command, client.Namespace, pod)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return FilterPrefix(containers, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// ClusterClient returns the client of the cluster clusterName, nil if it
+// selects several clusters or can not be resolved. It never prompts, which
+// would break the shell.
+func ClusterClient(f cmdutil.Factory, clusterName string) *cmdutil.ClusterClient {
+	disablePrompts()
+	if cmdutil.IsClusterList(clusterName) {
+		return nil
+	}
+	client, err := f.ClientForCluster(clusterName)
+	if err != nil {
+		return nil
+	}
+	return client
+}
+
+// PodNames returns the names of the pods in namespace, if the access policy
+// lets command access it
+func PodNames(client *cmdutil.ClusterClient, command, namespace string) ([]string, error) {
+	if err := client.CheckAccess(configs.Access{Command: command, Namespace: namespace}); err != nil {
+		return nil, err
+	}
+	return Cached([]string{"pods", client.Name, namespace}, func() ([]string, error) {
+		clientset, err := client.KubernetesClientSet()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		list, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(list.Items))
+		for _, pod := range list.Items {
+			names = append(names, pod.Name)
+		}
+		return names, nil
+	})
+}
+
+// ContainerNames returns the names of the init containers and containers of
+// a pod, if the access policy lets command access its namespace
+func ContainerNames(client *cmdutil.ClusterClient, command, namespace, podName string) ([]string, error) {
+	if err := client.CheckAccess(configs.Access{Command: command, Namespace: namespace}); err != nil {
+		return nil, err
+	}
+	return Cached([]string{"containers", client.Name, namespace, podName}, func() ([]string, error) {
+		clientset, err := client.KubernetesClientSet()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, container := range pod.Spec.InitContainers {
+			names = append(names, container.Name)
+		}
+		for _, container := range pod.Spec.Containers {
+			names = append(names, container.Name)
+		}
+		return names, nil
+	})
+}
+
+// FilterPrefix returns the values starting with prefix
+func FilterPrefix(values []string, prefix string) []string {
+	filtered := []string{}
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
+
+// clusterNameFlag returns the --clusterName of cmd, empty if it has none
+func clusterNameFlag(cmd *cobra.Command) string {
+	if flag := cmd.Flags().Lookup("clusterName"); flag != nil {
+		return flag.Value.String()
+	}
+	return ""
+}
+
+// disablePrompts makes loading sealed configs fail rather than prompt for
+// their passphrase, which the shell running the completion would swallow
+func disablePrompts() {
+	configs.ReadPassphrase = func() ([]byte, error) {
+		return nil, fmt.Errorf("can not prompt for the passphrase during shell completion")
+	}
+}
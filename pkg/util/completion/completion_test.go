@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package completion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/Angus-F/client-go/rest/fake"
+
+	cmdtesting "github.com/Angus-F/kubectl/pkg/cmd/testing"
+	"github.com/Angus-F/kubectl/pkg/configs"
+	"github.com/Angus-F/kubectl/pkg/scheme"
+)
+
+func withCacheDir(t *testing.T) {
+	dir, ttl := CacheDir, CacheTTL
+	CacheDir = t.TempDir()
+	t.Cleanup(func() {
+		CacheDir, CacheTTL = dir, ttl
+	})
+}
+
+func newCommand(clusterName string) *cobra.Command {
+	cmd := &cobra.Command{Use: "exec"}
+	cmd.Flags().StringP("clusterName", "C", clusterName, "")
+	cmd.Flags().StringP("container", "c", "", "")
+	return cmd
+}
+
+func TestClusterNameCompletion(t *testing.T) {
+	tests := []struct {
+		name       string
+		toComplete string
+		expected   []string
+	}{
+		{
+			name:     "all",
+			expected: []string{"dev", "prod-east", "prod-west"},
+		},
+		{
+			name:       "prefix",
+			toComplete: "prod",
+			expected:   []string{"prod-east", "prod-west"},
+		},
+		{
+			name:       "last item of a list",
+			toComplete: "prod-east,",
+			expected:   []string{"prod-east,dev", "prod-east,prod-west"},
+		},
+		{
+			name:       "no match",
+			toComplete: "staging",
+			expected:   []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := cmdtesting.NewTestFactory().WithNamespace("test")
+			defer tf.Cleanup()
+			tf.ClusterNamesVal = []string{"dev", "prod-east", "prod-west"}
+
+			completions, directive := ClusterNameCompletionFunc(tf)(newCommand(""), nil, test.toComplete)
+			if !reflect.DeepEqual(completions, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, completions)
+			}
+			if directive != cobra.ShellCompDirectiveNoFileComp {
+				t.Errorf("unexpected directive %v", directive)
+			}
+		})
+	}
+}
+
+func TestPodAndContainerCompletion(t *testing.T) {
+	withCacheDir(t)
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+
+	codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+	pods := &corev1.PodList{Items: []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "db-1", Namespace: "test"}},
+	}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "test"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers:     []corev1.Container{{Name: "nginx"}, {Name: "sidecar"}},
+		},
+	}
+	requests := 0
+	tf.Client = &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			requests++
+			switch p, m := req.URL.Path, req.Method; {
+			case p == "/api/v1/namespaces/test/pods" && m == "GET":
+				return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, pods)}, nil
+			case p == "/api/v1/namespaces/test/pods/web-1" && m == "GET":
+				return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, pod)}, nil
+			default:
+				t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+				return nil, fmt.Errorf("unexpected request")
+			}
+		}),
+	}
+	tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+
+	cmd := newCommand("prod")
+	completions, _ := PodNameCompletionFunc(tf, configs.CommandExec)(cmd, nil, "web")
+	if expected := []string{"web-1", "web-2"}; !reflect.DeepEqual(completions, expected) {
+		t.Errorf("expected pods %v, got %v", expected, completions)
+	}
+	completions, _ = PodNameCompletionFunc(tf, configs.CommandExec)(cmd, nil, "")
+	if expected := []string{"web-1", "web-2", "db-1"}; !reflect.DeepEqual(completions, expected) {
+		t.Errorf("expected pods %v, got %v", expected, completions)
+	}
+	if requests != 1 {
+		t.Errorf("expected the pods to be listed once, got %d requests", requests)
+	}
+	if completions, _ := PodNameCompletionFunc(tf, configs.CommandExec)(cmd, []string{"web-1"}, ""); len(completions) != 0 {
+		t.Errorf("expected only the first argument to be completed, got %v", completions)
+	}
+
+	completions, _ = ContainerCompletionFunc(tf, configs.CommandExec)(cmd, []string{"pod/web-1"}, "")
+	if expected := []string{"init", "nginx", "sidecar"}; !reflect.DeepEqual(completions, expected) {
+		t.Errorf("expected containers %v, got %v", expected, completions)
+	}
+	if completions, _ := ContainerCompletionFunc(tf, configs.CommandExec)(cmd, []string{"deployment/web"}, ""); len(completions) != 0 {
+		t.Errorf("expected no containers for other resources, got %v", completions)
+	}
+
+	if completions, _ := PodNameCompletionFunc(tf, configs.CommandExec)(newCommand("dev,prod"), nil, ""); len(completions) != 0 {
+		t.Errorf("expected no pods for a list of clusters, got %v", completions)
+	}
+
+	policy, err := configs.ParsePolicy([]byte("clusters:\n- name: prod\n  commands: [exec]\n  namespaces:\n    deny: [test]\n"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf.PolicyVal = policy
+	requests = 0
+	if completions, _ := PodNameCompletionFunc(tf, configs.CommandExec)(cmd, nil, ""); len(completions) != 0 {
+		t.Errorf("expected no pods in a denied namespace, got %v", completions)
+	}
+	if completions, _ := PodNameCompletionFunc(tf, configs.CommandLogs)(cmd, nil, ""); len(completions) != 0 {
+		t.Errorf("expected no pods for a denied command, got %v", completions)
+	}
+	if completions, _ := ContainerCompletionFunc(tf, configs.CommandExec)(cmd, []string{"web-1"}, ""); len(completions) != 0 {
+		t.Errorf("expected no containers in a denied namespace, got %v", completions)
+	}
+	if requests != 0 {
+		t.Errorf("expected no request once access is denied, got %d", requests)
+	}
+}
+
+func TestCached(t *testing.T) {
+	withCacheDir(t)
+
+	calls := 0
+	fetch := func() ([]string, error) {
+		calls++
+		return []string{"a", "b"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		values, err := Cached([]string{"pods", "dev", "test"}, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(values, []string{"a", "b"}) {
+			t.Errorf("unexpected values %v", values)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the cached values to be reused, fetched %d times", calls)
+	}
+
+	if _, err := Cached([]string{"pods", "dev", "other"}, fetch); err != nil || calls != 2 {
+		t.Errorf("expected another key to be fetched, fetched %d times: %v", calls, err)
+	}
+
+	CacheTTL = -time.Second
+	if _, err := Cached([]string{"pods", "dev", "test"}, fetch); err != nil || calls != 3 {
+		t.Errorf("expected expired values to be fetched again, fetched %d times: %v", calls, err)
+	}
+
+	failed := errors.New("unreachable")
+	if _, err := Cached([]string{"pods", "prod", "test"}, func() ([]string, error) { return nil, failed }); err != failed {
+		t.Errorf("expected the fetch error, got %v", err)
+	}
+}